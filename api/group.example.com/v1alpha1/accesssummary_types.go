@@ -0,0 +1,58 @@
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&AccessSummary{}, &AccessSummaryList{})
+}
+
+// AccessSummaryName is the name of the AccessSummary maintained in every namespace targeted by an AccessToken.
+const AccessSummaryName = "access-summary"
+
+// AccessSummary lists the AccessTokens granted access to its namespace. It's maintained by the controller in every
+// namespace targeted by the NamespacedPermissions of an AccessToken, and in every namespace while an AccessToken is
+// granted ClusterPermissions.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:subresource:status
+type AccessSummary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status AccessSummaryStatus `json:"status,omitempty"`
+}
+
+// AccessSummaryList contains a list of AccessSummary
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced
+type AccessSummaryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessSummary `json:"items"`
+}
+
+// AccessSummaryStatus defines the observed state of AccessSummary
+type AccessSummaryStatus struct {
+	// Grants lists the AccessTokens granted access to the namespace.
+	Grants []InboundGrant `json:"grants,omitempty"`
+}
+
+type InboundGrant struct {
+	// AccessTokenNamespace is the namespace of the AccessToken granted access.
+	AccessTokenNamespace string `json:"accessTokenNamespace"`
+
+	// AccessTokenName is the name of the AccessToken granted access.
+	AccessTokenName string `json:"accessTokenName"`
+
+	// ServiceAccount the token authenticates as, in "namespace/name" form.
+	ServiceAccount string `json:"serviceAccount"`
+
+	// Rules granted to the token in the namespace.
+	Rules []rbacv1.PolicyRule `json:"rules"`
+
+	// ClusterWide is set for grants bound by a ClusterRoleBinding, they apply in every namespace.
+	ClusterWide bool `json:"clusterWide,omitempty"`
+}
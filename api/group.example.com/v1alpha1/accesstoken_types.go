@@ -16,6 +16,121 @@ const (
 
 	// TypeStalePermissionsRemoved is a condition type that indicates stale permissions have been removed.
 	TypeStalePermissionsRemoved api.ConditionType = "StalePermissionsRemoved"
+
+	// TypePermissionsVerified is a condition type that indicates the API server grants the ServiceAccount every declared rule.
+	TypePermissionsVerified api.ConditionType = "PermissionsVerified"
+
+	// TypeAssertionsSatisfied is a condition type that indicates all access assertions hold.
+	TypeAssertionsSatisfied api.ConditionType = "AssertionsSatisfied"
+
+	// TypeHighRiskPermissions is an informational condition type that indicates the token is granted permissions
+	// classified as high or critical risk. It does not block provisioning.
+	TypeHighRiskPermissions api.ConditionType = "HighRiskPermissions"
+
+	// TypeNamespacesConsented is a condition type that indicates every namespace targeted by the token's
+	// NamespacedPermissions has consented to the grant. Entries targeting namespaces that haven't consented are skipped.
+	TypeNamespacesConsented api.ConditionType = "NamespacesConsented"
+
+	// TypePermissionsAllowed is a condition type that indicates none of the token's namespaces and rules are refused by
+	// the controller's deny list. Refused namespaces and rules are never granted.
+	TypePermissionsAllowed api.ConditionType = "PermissionsAllowed"
+
+	// TypeRemoteTokensProvisioned is a condition type that indicates the token has been provisioned in every target
+	// cluster and collected locally (see `status.clusters`).
+	TypeRemoteTokensProvisioned api.ConditionType = "RemoteTokensProvisioned"
+
+	// TypeSecretsMirrored is a condition type that indicates the token Secret has been mirrored to every one of
+	// `spec.secretTargets`. Targets in namespaces that haven't opted in are skipped.
+	TypeSecretsMirrored api.ConditionType = "SecretsMirrored"
+
+	// TypeTokenDelivered is a condition type that indicates the token has been encrypted to `spec.delivery.publicKey`
+	// (see `status.encryptedToken`).
+	TypeTokenDelivered api.ConditionType = "TokenDelivered"
+)
+
+const (
+	// ReasonPermissionsGranted indicates the API server grants every declared rule.
+	ReasonPermissionsGranted api.ConditionReason = "PermissionsGranted"
+
+	// ReasonPermissionsNotGranted indicates the API server doesn't grant some declared rules, e.g. because an
+	// authorization webhook denies them or its RBAC cache hasn't caught up yet.
+	ReasonPermissionsNotGranted api.ConditionReason = "PermissionsNotGranted"
+
+	// ReasonHighRiskPermissionsGranted indicates the token is granted high or critical risk permissions.
+	ReasonHighRiskPermissionsGranted api.ConditionReason = "HighRiskPermissionsGranted"
+
+	// ReasonNoHighRiskPermissions indicates the token isn't granted any high or critical risk permissions.
+	ReasonNoHighRiskPermissions api.ConditionReason = "NoHighRiskPermissions"
+
+	// ReasonConsentGranted indicates every targeted namespace has consented to the grant.
+	ReasonConsentGranted api.ConditionReason = "ConsentGranted"
+
+	// ReasonConsentMissing indicates some targeted namespaces haven't consented to the grant.
+	ReasonConsentMissing api.ConditionReason = "ConsentMissing"
+
+	// ReasonNoDeniedPermissions indicates no namespace or rule is refused by the deny list.
+	ReasonNoDeniedPermissions api.ConditionReason = "NoDeniedPermissions"
+
+	// ReasonPermissionsDenied indicates some namespaces or rules are refused by the deny list.
+	ReasonPermissionsDenied api.ConditionReason = "PermissionsDenied"
+
+	// ReasonSecretsInSync indicates the token Secret is mirrored to every secret target.
+	ReasonSecretsInSync api.ConditionReason = "SecretsInSync"
+
+	// ReasonSecretTargetsRefused indicates some secret target namespaces haven't opted in to receiving the token.
+	ReasonSecretTargetsRefused api.ConditionReason = "SecretTargetsRefused"
+
+	// ReasonSecretTargetsConflict indicates some secret targets name Secrets that aren't managed for the AccessToken, or
+	// its token Secret.
+	ReasonSecretTargetsConflict api.ConditionReason = "SecretTargetsConflict"
+
+	// ReasonTokenNotIssued indicates the token hasn't been issued yet, so there's nothing to mirror.
+	ReasonTokenNotIssued api.ConditionReason = "TokenNotIssued"
+
+	// ReasonDeliveryConflict indicates spec.delivery is combined with features that require the token Secret, so the
+	// token isn't delivered.
+	ReasonDeliveryConflict api.ConditionReason = "DeliveryConflict"
+
+	// ReasonRemoteTokensRevoked indicates everything provisioned in target clusters has been deleted.
+	ReasonRemoteTokensRevoked api.ConditionReason = "RemoteTokensRevoked"
+
+	// ReasonRemoteTokensNotRevoked indicates a target cluster couldn't be reached to delete what was provisioned in it.
+	ReasonRemoteTokensNotRevoked api.ConditionReason = "RemoteTokensNotRevoked"
+
+	// ReasonRemoteTokensOrphaned indicates objects provisioned in some target clusters were left behind because their
+	// kubeconfig Secret is gone.
+	ReasonRemoteTokensOrphaned api.ConditionReason = "RemoteTokensOrphaned"
+)
+
+const (
+	// LabelAccessTokenName is set on every object provisioned for an AccessToken and holds the AccessToken's name.
+	LabelAccessTokenName = "accesstoken.group.example.com/name"
+
+	// LabelAccessTokenNamespace is set on every object provisioned for an AccessToken and holds the AccessToken's namespace.
+	LabelAccessTokenNamespace = "accesstoken.group.example.com/namespace"
+
+	// LabelTargetCluster is set on the local Secrets holding tokens issued by remote clusters and holds the cluster's
+	// name.
+	LabelTargetCluster = "accesstoken.group.example.com/target-cluster"
+)
+
+const (
+	// AnnotationRiskFindings is set by the controller on AccessTokens and lists, as JSON, the rules matching the risk
+	// catalog.
+	AnnotationRiskFindings = "accesstoken.group.example.com/risk-findings"
+
+	// AnnotationInject is set on a Pod to inject the credentials of an AccessToken into its containers. The value is the
+	// name of an AccessToken in the Pod's namespace, or "<namespace>/<name>" for an AccessToken in another namespace
+	// listing the Pod's namespace in its SecretTargets.
+	AnnotationInject = "accesstoken.group.example.com/inject"
+
+	// AnnotationInjectContainers is set on a Pod along with AnnotationInject and holds a comma separated list of the
+	// containers to inject into. All containers are injected into if it's not set.
+	AnnotationInjectContainers = "accesstoken.group.example.com/inject-containers"
+
+	// AnnotationInjectedKubeconfig is set by the injection webhook on Pods and holds the kubeconfig projected into
+	// their containers.
+	AnnotationInjectedKubeconfig = "accesstoken.group.example.com/injected-kubeconfig"
 )
 
 // AccessToken is the Schema for the AccessToken API
@@ -46,21 +161,162 @@ type AccessTokenSpec struct {
 
 	// ClusterPermissions defines cluster scoped permissions. Optional
 	ClusterPermissions *ClusterPermissions `json:"clusterPermissions,omitempty"`
+
+	// Assertions about the effective access of the token, evaluated after every reconcile. Optional
+	Assertions *Assertions `json:"assertions,omitempty"`
+
+	// TemplateRef references an AccessTokenTemplate whose expanded permissions are added to NamespacedPermissions.
+	// Optional
+	TemplateRef *TemplateRef `json:"templateRef,omitempty"`
+
+	// TargetClusters lists remote clusters the token is also provisioned in. Optional
+	TargetClusters []TargetCluster `json:"targetClusters,omitempty"`
+
+	// SecretTargets lists Secrets the token Secret is mirrored to and kept in sync with, e.g. in the namespaces of the
+	// token's consumers. Optional
+	SecretTargets []SecretTarget `json:"secretTargets,omitempty"`
+
+	// Delivery encrypts the token to a public key instead of storing it in a Secret, so that only the holder of the
+	// private key can read it. It can't be combined with SecretTargets and TargetClusters. Optional
+	Delivery *Delivery `json:"delivery,omitempty"`
+
+	// AllowedConsumers lists the ServiceAccounts that may fetch the token from the controller's vending endpoint by
+	// presenting their own ServiceAccount token. Optional
+	AllowedConsumers []AllowedConsumer `json:"allowedConsumers,omitempty"`
+}
+
+type AllowedConsumer struct {
+	// Namespace of the ServiceAccount. Required
+	Namespace string `json:"namespace"`
+
+	// Name of the ServiceAccount. Required
+	Name string `json:"name"`
+}
+
+type Delivery struct {
+	// PublicKey is the base64 encoded X25519 public key the token is encrypted to, see the `keygen` command. Required
+	PublicKey string `json:"publicKey"`
+
+	// ExpirationSeconds is the requested lifetime of the token, it's reissued once half of the lifetime it's issued
+	// with has passed. The API server may issue shorter lived tokens. Optional, defaults to a day, at least 10 minutes
+	// +kubebuilder:default=86400
+	// +kubebuilder:validation:Minimum=600
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty"`
+}
+
+type SecretTarget struct {
+	// Namespace of the mirrored Secret, it must opt in through the
+	// accesstoken.group.example.com/allowed-secret-source-namespaces annotation unless it's the AccessToken's namespace.
+	// Required
+	Namespace string `json:"namespace"`
+
+	// Name of the mirrored Secret. Optional, defaults to the name of the AccessToken. Existing Secrets that aren't
+	// mirrors of the AccessToken are never overwritten, neither is the token Secret in the AccessToken's namespace
+	Name string `json:"name,omitempty"`
+}
+
+type TargetCluster struct {
+	// Name identifies the cluster, it must be unique within the AccessToken. Required
+	Name string `json:"name"`
+
+	// KubeconfigSecretRef references a Secret in the namespace of the AccessToken holding a kubeconfig for the cluster.
+	// Required
+	KubeconfigSecretRef SecretKeyRef `json:"kubeconfigSecretRef"`
+}
+
+type SecretKeyRef struct {
+	// Name of the Secret. Required
+	Name string `json:"name"`
+
+	// Key of the Secret. Optional, defaults to "kubeconfig"
+	// +kubebuilder:default=kubeconfig
+	Key string `json:"key,omitempty"`
+}
+
+type TemplateRef struct {
+	// Name of the AccessTokenTemplate. Required
+	Name string `json:"name"`
+
+	// Parameters supplied to the template, by name. Optional
+	Parameters map[string]string `json:"parameters,omitempty"`
 }
 
 type NamespacedPermissions struct {
 	// Namespace the role applies to. Required
 	Namespace string `json:"namespace"`
 
-	// Rules for the role. Required
-	Rules []rbacv1.PolicyRule `json:"rules"`
+	// Rules for the role. Required unless PermissionSetRefs is set
+	Rules []rbacv1.PolicyRule `json:"rules,omitempty"`
+
+	// PermissionSetRefs references permission sets whose rules are added to Rules. Optional
+	PermissionSetRefs []PermissionSetRef `json:"permissionSetRefs,omitempty"`
 }
 
 type ClusterPermissions struct {
-	// Rules for the role. Required
-	Rules []rbacv1.PolicyRule `json:"rules"`
+	// Rules for the role. Required unless PermissionSetRefs is set
+	Rules []rbacv1.PolicyRule `json:"rules,omitempty"`
+
+	// PermissionSetRefs references permission sets whose rules are added to Rules. Optional
+	PermissionSetRefs []PermissionSetRef `json:"permissionSetRefs,omitempty"`
+}
+
+type PermissionSetRef struct {
+	// Kind of the permission set, either a PermissionSet in the namespace of the AccessToken or a ClusterPermissionSet.
+	// Optional, defaults to PermissionSet
+	// +kubebuilder:validation:Enum=PermissionSet;ClusterPermissionSet
+	// +kubebuilder:default=PermissionSet
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the permission set. Required
+	Name string `json:"name"`
+}
+
+type Assertions struct {
+	// Allow lists requests the token must be able to perform. Optional
+	Allow []AccessAssertion `json:"allow,omitempty"`
+
+	// Deny lists requests the token must not be able to perform. Optional
+	Deny []AccessAssertion `json:"deny,omitempty"`
 }
 
+type AccessAssertion struct {
+	// Verb of the request, e.g. "list". Required
+	Verb string `json:"verb"`
+
+	// APIGroup of the resource, empty for the core API group. Optional
+	APIGroup string `json:"apiGroup,omitempty"`
+
+	// Resource of the request, optionally qualified with a subresource, e.g. "pods" or "pods/exec". Required
+	Resource string `json:"resource"`
+
+	// Namespace of the request. If empty, allow assertions are evaluated cluster-wide, and deny assertions are evaluated
+	// cluster-wide and in every namespace the token has NamespacedPermissions for. Optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type AssertionResult struct {
+	AccessAssertion `json:",inline"`
+
+	// Expectation is either "Allow" or "Deny".
+	Expectation AssertionExpectation `json:"expectation"`
+
+	// Satisfied is true if the API server's authorization decision matches the expectation.
+	Satisfied bool `json:"satisfied"`
+
+	// Message explains why the assertion isn't satisfied.
+	Message string `json:"message,omitempty"`
+}
+
+type AssertionExpectation string
+
+const (
+	// AssertionExpectationAllow indicates the request must be allowed.
+	AssertionExpectationAllow AssertionExpectation = "Allow"
+
+	// AssertionExpectationDeny indicates the request must be denied.
+	AssertionExpectationDeny AssertionExpectation = "Deny"
+)
+
 // AccessTokenStatus defines the observed state of AccessToken
 type AccessTokenStatus struct {
 	api.ConditionedStatus `json:",inline"`
@@ -70,6 +326,43 @@ type AccessTokenStatus struct {
 
 	// TokenSecretRef is a reference to the Secret containing the access token.
 	TokenSecretRef *string `json:"tokenSecretRef,omitempty"`
+
+	// EncryptedToken is the token encrypted to `spec.delivery.publicKey`, see the `decrypt` command.
+	EncryptedToken string `json:"encryptedToken,omitempty"`
+
+	// EncryptedTokenPublicKey is the public key EncryptedToken is encrypted to.
+	EncryptedTokenPublicKey string `json:"encryptedTokenPublicKey,omitempty"`
+
+	// EncryptedTokenIssueTimestamp is when EncryptedToken was issued.
+	EncryptedTokenIssueTimestamp *metav1.Time `json:"encryptedTokenIssueTimestamp,omitempty"`
+
+	// EncryptedTokenExpirationTimestamp is when EncryptedToken expires.
+	EncryptedTokenExpirationTimestamp *metav1.Time `json:"encryptedTokenExpirationTimestamp,omitempty"`
+
+	// AssertionResults are the results of evaluating `spec.assertions`.
+	AssertionResults []AssertionResult `json:"assertionResults,omitempty"`
+
+	// Clusters reports the state of the token in each of `spec.targetClusters`.
+	Clusters []ClusterStatus `json:"clusters,omitempty"`
+}
+
+type ClusterStatus struct {
+	// Name of the target cluster.
+	Name string `json:"name"`
+
+	// KubeconfigSecretRef the cluster was reached with, used to clean up the cluster once it's removed from
+	// `spec.targetClusters`.
+	KubeconfigSecretRef SecretKeyRef `json:"kubeconfigSecretRef"`
+
+	// Ready is true if the token has been provisioned in the cluster and collected into TokenSecretRef.
+	Ready bool `json:"ready"`
+
+	// Message explains why the token isn't ready in the cluster, or reports the namespaces whose permissions aren't
+	// granted in it.
+	Message string `json:"message,omitempty"`
+
+	// TokenSecretRef is the name of the local Secret holding the token issued by the cluster.
+	TokenSecretRef *string `json:"tokenSecretRef,omitempty"`
 }
 
 func (c *AccessToken) GetConditions() []api.Condition {
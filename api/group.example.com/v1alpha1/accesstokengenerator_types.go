@@ -0,0 +1,115 @@
+package v1alpha1
+
+import (
+	"github.com/reddit/achilles-sdk-api/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&AccessTokenGenerator{}, &AccessTokenGeneratorList{})
+}
+
+const (
+	// TypeChildrenProvisioned is a condition type that indicates an AccessToken has been applied in every selected
+	// namespace.
+	TypeChildrenProvisioned api.ConditionType = "ChildrenProvisioned"
+
+	// TypeStaleChildrenRemoved is a condition type that indicates the AccessTokens of namespaces that are no longer
+	// selected have been deleted.
+	TypeStaleChildrenRemoved api.ConditionType = "StaleChildrenRemoved"
+)
+
+const (
+	// LabelAccessTokenGenerator is set on every AccessToken generated by an AccessTokenGenerator and holds the
+	// generator's name.
+	LabelAccessTokenGenerator = "accesstoken.group.example.com/generator"
+)
+
+// AccessTokenGenerator generates an AccessToken in every namespace matching a label selector. Occurrences of
+// ${namespace} in the namespaces of the template's namespacedPermissions are replaced with the generated token's
+// namespace.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+type AccessTokenGenerator struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AccessTokenGeneratorSpec   `json:"spec,omitempty"`
+	Status AccessTokenGeneratorStatus `json:"status,omitempty"`
+}
+
+// AccessTokenGeneratorList contains a list of AccessTokenGenerator
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+type AccessTokenGeneratorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessTokenGenerator `json:"items"`
+}
+
+// AccessTokenGeneratorSpec defines the desired state of AccessTokenGenerator
+type AccessTokenGeneratorSpec struct {
+	// NamespaceSelector selects the namespaces an AccessToken is generated in. Required
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+
+	// Template of the generated AccessTokens. Required
+	Template GeneratedAccessToken `json:"template"`
+}
+
+type GeneratedAccessToken struct {
+	// Metadata of the generated AccessTokens. Optional
+	Metadata GeneratedObjectMeta `json:"metadata,omitempty"`
+
+	// Spec of the generated AccessTokens. Required
+	Spec AccessTokenSpec `json:"spec"`
+}
+
+type GeneratedObjectMeta struct {
+	// Name of the generated AccessTokens. Optional, defaults to the name of the AccessTokenGenerator
+	Name string `json:"name,omitempty"`
+
+	// Labels of the generated AccessTokens. Optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations of the generated AccessTokens. Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// AccessTokenGeneratorStatus defines the observed state of AccessTokenGenerator
+type AccessTokenGeneratorStatus struct {
+	api.ConditionedStatus `json:",inline"`
+
+	// ResourceRefs is a list of all resources managed by this object.
+	ResourceRefs []api.TypedObjectRef `json:"resourceRefs,omitempty"`
+
+	// Children is the number of generated AccessTokens.
+	Children int32 `json:"children,omitempty"`
+
+	// ReadyChildren is the number of generated AccessTokens that are ready.
+	ReadyChildren int32 `json:"readyChildren,omitempty"`
+
+	// Conflicts lists the AccessTokens, as namespace/name, that would be generated but already exist without being
+	// generated by this generator. They're left untouched.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+func (c *AccessTokenGenerator) GetConditions() []api.Condition {
+	return c.Status.Conditions
+}
+
+func (c *AccessTokenGenerator) SetConditions(cond ...api.Condition) {
+	c.Status.SetConditions(cond...)
+}
+
+func (c *AccessTokenGenerator) GetCondition(t api.ConditionType) api.Condition {
+	return c.Status.GetCondition(t)
+}
+
+func (c *AccessTokenGenerator) SetManagedResources(refs []api.TypedObjectRef) {
+	c.Status.ResourceRefs = refs
+}
+
+func (c *AccessTokenGenerator) GetManagedResources() []api.TypedObjectRef {
+	return c.Status.ResourceRefs
+}
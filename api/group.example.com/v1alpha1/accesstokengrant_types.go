@@ -0,0 +1,57 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&AccessTokenGrant{}, &AccessTokenGrantList{})
+}
+
+const (
+	// AnnotationAllowedSourceNamespaces is set on a Namespace to consent to AccessTokens from the listed namespaces being
+	// granted access to it. The value is a comma separated list of namespaces, or "*" for all namespaces.
+	AnnotationAllowedSourceNamespaces = "accesstoken.group.example.com/allowed-source-namespaces"
+
+	// LabelPrefixAllowedSourceNamespace prefixes the labels set on a Namespace to consent to AccessTokens from the
+	// namespace named by the rest of the label key being granted access to it, e.g.
+	// "allowed-source.accesstoken.group.example.com/team-a: true". Unlike the annotation, the labels can select the
+	// namespaces that consent.
+	LabelPrefixAllowedSourceNamespace = "allowed-source.accesstoken.group.example.com/"
+
+	// AnnotationAllowedSecretSourceNamespaces is set on a Namespace to opt in to receiving mirrored token Secrets of
+	// AccessTokens from the listed namespaces. The value is a comma separated list of namespaces, or "*" for all
+	// namespaces.
+	AnnotationAllowedSecretSourceNamespaces = "accesstoken.group.example.com/allowed-secret-source-namespaces"
+)
+
+// AccessTokenGrant consents to AccessTokens from other namespaces being granted access to its namespace, similar to the
+// Gateway API's ReferenceGrant.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced
+type AccessTokenGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AccessTokenGrantSpec `json:"spec,omitempty"`
+}
+
+// AccessTokenGrantList contains a list of AccessTokenGrant
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced
+type AccessTokenGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessTokenGrant `json:"items"`
+}
+
+// AccessTokenGrantSpec defines the desired state of AccessTokenGrant
+type AccessTokenGrantSpec struct {
+	// From lists the namespaces whose AccessTokens may be granted access to the namespace of the AccessTokenGrant. Required
+	From []AccessTokenGrantFrom `json:"from"`
+}
+
+type AccessTokenGrantFrom struct {
+	// Namespace of the AccessTokens. Required
+	Namespace string `json:"namespace"`
+}
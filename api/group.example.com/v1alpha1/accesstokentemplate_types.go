@@ -0,0 +1,65 @@
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&AccessTokenTemplate{}, &AccessTokenTemplateList{})
+}
+
+// AccessTokenTemplate is a parameterized set of namespaced permissions that AccessTokens in any namespace can
+// reference. Namespaces and rules may contain ${namespace} and ${name}, the namespace and name of the referencing
+// AccessToken, and ${<parameter>} for the declared parameters. Namespaces can't contain ${name}.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+type AccessTokenTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AccessTokenTemplateSpec `json:"spec,omitempty"`
+}
+
+// AccessTokenTemplateList contains a list of AccessTokenTemplate
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+type AccessTokenTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessTokenTemplate `json:"items"`
+}
+
+// AccessTokenTemplateSpec defines the desired state of AccessTokenTemplate
+type AccessTokenTemplateSpec struct {
+	// Parameters the referencing AccessTokens may supply. Optional
+	Parameters []TemplateParameter `json:"parameters,omitempty"`
+
+	// NamespacedPermissions defines a list of namespaced scoped permissions, which may contain variables. Required
+	NamespacedPermissions []TemplatedPermissions `json:"namespacedPermissions"`
+}
+
+type TemplateParameter struct {
+	// Name of the parameter, referenced as ${<name>}. Required
+	Name string `json:"name"`
+
+	// Description of the parameter. Optional
+	Description string `json:"description,omitempty"`
+
+	// Default value of the parameter, the parameter must be supplied if unset. Optional
+	Default *string `json:"default,omitempty"`
+
+	// Pattern is a regular expression the whole value must match. Optional
+	Pattern string `json:"pattern,omitempty"`
+
+	// AllowedValues lists the values the parameter may take. Optional
+	AllowedValues []string `json:"allowedValues,omitempty"`
+}
+
+type TemplatedPermissions struct {
+	// Namespace the role applies to. Parameters used in the namespace must declare a Pattern or AllowedValues. Required
+	Namespace string `json:"namespace"`
+
+	// Rules for the role. Required
+	Rules []rbacv1.PolicyRule `json:"rules"`
+}
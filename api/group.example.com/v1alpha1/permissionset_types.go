@@ -0,0 +1,62 @@
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&PermissionSet{}, &PermissionSetList{}, &ClusterPermissionSet{}, &ClusterPermissionSetList{})
+}
+
+const (
+	// PermissionSetKind is the kind of PermissionSet, as referenced by a PermissionSetRef.
+	PermissionSetKind = "PermissionSet"
+
+	// ClusterPermissionSetKind is the kind of ClusterPermissionSet, as referenced by a PermissionSetRef.
+	ClusterPermissionSetKind = "ClusterPermissionSet"
+)
+
+// PermissionSet is a named bundle of rules that AccessTokens in its namespace can reference.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced
+type PermissionSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PermissionSetSpec `json:"spec,omitempty"`
+}
+
+// PermissionSetList contains a list of PermissionSet
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced
+type PermissionSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PermissionSet `json:"items"`
+}
+
+// ClusterPermissionSet is a named bundle of rules that AccessTokens in any namespace can reference.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+type ClusterPermissionSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PermissionSetSpec `json:"spec,omitempty"`
+}
+
+// ClusterPermissionSetList contains a list of ClusterPermissionSet
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+type ClusterPermissionSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterPermissionSet `json:"items"`
+}
+
+// PermissionSetSpec defines the rules of a PermissionSet or ClusterPermissionSet
+type PermissionSetSpec struct {
+	// Rules of the permission set. Required
+	Rules []rbacv1.PolicyRule `json:"rules"`
+}
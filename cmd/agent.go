@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/agent"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// syncBackoff spaces out the retries of failed syncs, e.g. while the API server is unreachable.
+var syncBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    10,
+	Cap:      2 * time.Minute,
+}
+
+func agentCommand() *cobra.Command {
+	var (
+		cluster           clusterOpts
+		namespace         string
+		dir               string
+		server            string
+		contextName       string
+		healthBindAddress string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "agent <name> --dir <path>",
+		Short: "Keep an AccessToken's token, CA and kubeconfig fresh on disk",
+		Long: `Agent watches the AccessToken and its token Secret and atomically writes the token, CA bundle and a kubeconfig
+to --dir whenever they change, e.g. after rotation. Failed writes are retried with backoff. Point KUBECONFIG at
+<dir>/kubeconfig. Run it as a sidecar or next to a CI runner. /healthz reports whether the files are written. The agent
+removes the files and exits non-zero once the token is revoked by deleting the AccessToken or its token Secret, or
+once the AccessToken stops being ready, e.g. because its permissions were denied. Until the AccessToken is ready
+nothing is written.
+
+The agent's own identity needs get, list and watch on the AccessToken and its Secret.`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			restConfig, err := cluster.restConfig()
+			if err != nil {
+				return err
+			}
+			// populate CAData from CAFile, the CA bundle is used if the token Secret doesn't hold one
+			if err := rest.LoadTLSFiles(restConfig); err != nil {
+				return fmt.Errorf("loading TLS files: %w", err)
+			}
+			if server == "" {
+				server = restConfig.Host
+			}
+
+			key := client.ObjectKey{Namespace: namespace, Name: args[0]}
+			if contextName == "" {
+				contextName = fmt.Sprintf("%s-%s", key.Namespace, key.Name)
+			}
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return fmt.Errorf("creating %s: %w", dir, err)
+			}
+
+			log, err := zap.NewProduction()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = log.Sync() }()
+
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+
+			c, changed, err := watchToken(ctx, restConfig, key)
+			if err != nil {
+				return err
+			}
+
+			a := agent.New(c, key, agent.Options{
+				Dir:         dir,
+				Server:      server,
+				CAData:      restConfig.CAData,
+				ContextName: contextName,
+			}, log.Sugar())
+
+			errs := make(chan error, 1)
+			if healthBindAddress != "" {
+				mux := http.NewServeMux()
+				mux.Handle("/healthz", a.HealthHandler())
+				srv := &http.Server{Addr: healthBindAddress, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+				go func() {
+					errs <- fmt.Errorf("serving health endpoint: %w", srv.ListenAndServe())
+				}()
+				defer func() { _ = srv.Close() }()
+			}
+
+			return syncLoop(ctx, a.Sync, changed, errs, syncBackoff, log.Sugar())
+		},
+	}
+
+	cluster.addToFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "namespace of the AccessToken")
+	cmd.Flags().StringVar(&dir, "dir", "", "directory to write the token, ca.crt and kubeconfig files to")
+	cmd.Flags().StringVar(&server, "server", "", "API server URL written to the kubeconfig (default: the URL of the agent's kubeconfig)")
+	cmd.Flags().StringVar(&contextName, "context-name", "", "name of the kubeconfig's cluster, user and context (default: <namespace>-<name>)")
+	cmd.Flags().StringVar(&healthBindAddress, "health-bind-address", ":8081", "address the /healthz endpoint listens on, empty to disable")
+	_ = cmd.MarkFlagRequired("dir")
+
+	return cmd
+}
+
+// syncLoop syncs whenever changed receives a value. Failed syncs are retried with backoff, since nothing may change
+// until the token is rotated. It returns once the token is revoked, errs receives an error or the context is cancelled.
+func syncLoop(
+	ctx context.Context,
+	sync func(context.Context) error,
+	changed <-chan struct{},
+	errs <-chan error,
+	backoff wait.Backoff,
+	log *zap.SugaredLogger,
+) error {
+	retry := backoff
+	for {
+		// a nil channel never receives, there's nothing to retry after a successful sync
+		var retryAfter <-chan time.Time
+		var timer *time.Timer
+		if err := sync(ctx); err != nil {
+			if errors.Is(err, agent.ErrRevoked) {
+				return err
+			}
+			delay := retry.Step()
+			log.Errorw("syncing token", "error", err, "retryAfter", delay.String())
+			timer = time.NewTimer(delay)
+			retryAfter = timer.C
+		} else {
+			retry = backoff
+		}
+
+		select {
+		case <-changed:
+		case <-retryAfter:
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// watchToken starts informers for the AccessToken and the Secrets of its namespace, and returns a reader served from
+// them along with a channel receiving a value whenever either changes. The informers stop when the context is
+// cancelled.
+func watchToken(ctx context.Context, restConfig *rest.Config, key client.ObjectKey) (client.Reader, <-chan struct{}, error) {
+	scheme, err := intscheme.NewScheme()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byName := cache.ByObject{Field: fields.OneTermEqualSelector("metadata.name", key.Name)}
+	c, err := cache.New(restConfig, cache.Options{
+		Scheme:            scheme,
+		DefaultNamespaces: map[string]cache.Config{key.Namespace: {}},
+		ByObject: map[client.Object]cache.ByObject{
+			&v1alpha1.AccessToken{}: byName,
+			// the token Secret is named after the AccessToken
+			&corev1.Secret{}: byName,
+		},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating cache: %w", err)
+	}
+
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	handler := toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { notify() },
+		UpdateFunc: func(any, any) { notify() },
+		DeleteFunc: func(any) { notify() },
+	}
+	for _, o := range []client.Object{&v1alpha1.AccessToken{}, &corev1.Secret{}} {
+		informer, err := c.GetInformer(ctx, o)
+		if err != nil {
+			return nil, nil, fmt.Errorf("getting informer for %T: %w", o, err)
+		}
+		if _, err := informer.AddEventHandler(handler); err != nil {
+			return nil, nil, fmt.Errorf("watching %T: %w", o, err)
+		}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Start(ctx)
+	}()
+	if !c.WaitForCacheSync(ctx) {
+		select {
+		case err := <-errs:
+			return nil, nil, fmt.Errorf("starting cache: %w", err)
+		default:
+			return nil, nil, fmt.Errorf("waiting for cache to sync: %w", ctx.Err())
+		}
+	}
+
+	return c, changed, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/internal/agent"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var _ = Describe("syncLoop", func() {
+	backoff := wait.Backoff{Duration: 10 * time.Millisecond, Factor: 2, Steps: 3}
+
+	It("should retry failed syncs without changes", func() {
+		var calls int
+		sync := func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return errors.New("connection refused")
+			}
+			return fmt.Errorf("%w: AccessToken default/test was deleted", agent.ErrRevoked)
+		}
+
+		// nothing changes, only retries sync again
+		err := syncLoop(context.Background(), sync, make(chan struct{}), make(chan error), backoff, zap.NewNop().Sugar())
+		Expect(err).To(MatchError(agent.ErrRevoked))
+		Expect(calls).To(Equal(3))
+	})
+
+	It("should wait for changes after successful syncs", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		changed := make(chan struct{})
+		var calls int
+		sync := func(context.Context) error {
+			calls++
+			if calls == 2 {
+				cancel()
+			}
+			return nil
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- syncLoop(ctx, sync, changed, make(chan error), backoff, zap.NewNop().Sugar())
+		}()
+
+		Consistently(done, 5*backoff.Duration).ShouldNot(Receive())
+		changed <- struct{}{}
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(calls).To(Equal(2))
+	})
+})
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/spf13/pflag"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clusterOpts select the cluster that subcommands talk to.
+type clusterOpts struct {
+	kubeconfig string
+	context    string
+}
+
+func (o *clusterOpts) addToFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.kubeconfig, "kubeconfig", "", "path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config)")
+	flags.StringVar(&o.context, "context", "", "kubeconfig context to use (default: the current context)")
+}
+
+func (o *clusterOpts) restConfig() (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.kubeconfig
+
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		rules,
+		&clientcmd.ConfigOverrides{CurrentContext: o.context},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	return cfg, nil
+}
+
+func (o *clusterOpts) client() (client.Client, *rest.Config, error) {
+	cfg, err := o.restConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scheme, err := intscheme.NewScheme()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating client: %w", err)
+	}
+	return c, cfg, nil
+}
+
+// managedCache starts a cache of the RBAC objects managed for AccessTokens, serving whocan.Lookup. The cache stops
+// when the context is cancelled.
+func (o *clusterOpts) managedCache(ctx context.Context) (client.Reader, error) {
+	cfg, err := o.restConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	scheme, err := intscheme.NewScheme()
+	if err != nil {
+		return nil, err
+	}
+
+	managed, err := labels.NewRequirement(v1alpha1.LabelAccessTokenName, selection.Exists, nil)
+	if err != nil {
+		return nil, err
+	}
+	byManaged := cache.ByObject{Label: labels.NewSelector().Add(*managed)}
+
+	c, err := cache.New(cfg, cache.Options{
+		Scheme: scheme,
+		ByObject: map[client.Object]cache.ByObject{
+			&rbacv1.Role{}:               byManaged,
+			&rbacv1.RoleBinding{}:        byManaged,
+			&rbacv1.ClusterRole{}:        byManaged,
+			&rbacv1.ClusterRoleBinding{}: byManaged,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating cache: %w", err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Start(ctx)
+	}()
+	if !c.WaitForCacheSync(ctx) {
+		select {
+		case err := <-errs:
+			return nil, fmt.Errorf("starting cache: %w", err)
+		default:
+			return nil, fmt.Errorf("waiting for cache to sync: %w", ctx.Err())
+		}
+	}
+
+	return c, nil
+}
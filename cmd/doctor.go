@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/reddit/achilles-token-controller/internal/doctor"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func doctorCommand() *cobra.Command {
+	var (
+		cluster   clusterOpts
+		namespace string
+		output    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "doctor <name>",
+		Short: "Diagnose an AccessToken that doesn't grant the expected access",
+		Long: `Doctor inspects an AccessToken, its conditions and every object provisioned for it, runs SubjectAccessReviews
+for its rules, and prints each problem found along with a suggested fix. It exits non-zero if any problem is found.`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, _, err := cluster.client()
+			if err != nil {
+				return err
+			}
+
+			key := client.ObjectKey{Namespace: namespace, Name: args[0]}
+			problems, err := doctor.New(c).Diagnose(cmd.Context(), key)
+			if err != nil {
+				return err
+			}
+
+			switch output {
+			case outputText:
+				err = writeDoctorText(cmd.OutOrStdout(), key, problems)
+			case outputJSON:
+				err = writeJSON(cmd.OutOrStdout(), problems)
+			default:
+				return fmt.Errorf("unknown output format %q", output)
+			}
+			if err != nil {
+				return err
+			}
+
+			if len(problems) > 0 {
+				return fmt.Errorf("found %d problem(s) with AccessToken %s", len(problems), key)
+			}
+			return nil
+		},
+	}
+
+	cluster.addToFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "namespace of the AccessToken")
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "output format, one of: text, json")
+
+	return cmd
+}
+
+func writeDoctorText(w io.Writer, key client.ObjectKey, problems []doctor.Problem) error {
+	if len(problems) == 0 {
+		_, err := fmt.Fprintf(w, "AccessToken %s: no problems found\n", key)
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "AccessToken %s: %d problem(s) found\n", key, len(problems)); err != nil {
+		return err
+	}
+	for _, p := range problems {
+		heading := fmt.Sprintf("[%s]", p.Check)
+		if p.Object != "" {
+			heading = fmt.Sprintf("%s %s", heading, p.Object)
+		}
+		if _, err := fmt.Fprintf(w, "\n%s\n  problem: %s\n  fix:     %s\n", heading, p.Message, p.Fix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
+	"github.com/reddit/achilles-token-controller/internal/inventory"
+	"github.com/reddit/achilles-token-controller/internal/risk"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const outputCSV = "csv"
+
+func inventoryCommand() *cobra.Command {
+	var (
+		cluster   clusterOpts
+		namespace string
+		output    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "List every AccessToken along with where it grants access and how risky its permissions are",
+		Long: `Inventory lists the AccessTokens of the cluster with their ServiceAccount, the namespaces they grant access
+to, whether they grant cluster scoped access, their risk findings, age and expiry.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, _, err := cluster.client()
+			if err != nil {
+				return err
+			}
+
+			accessTokens := &v1alpha1.AccessTokenList{}
+			if err := c.List(cmd.Context(), accessTokens, client.InNamespace(namespace)); err != nil {
+				return fmt.Errorf("listing AccessTokens: %w", err)
+			}
+
+			// the reach of a token includes the permissions of the template and permission sets it references
+			resolver := accesstoken.ClientResolver(cmd.Context(), c)
+			for i := range accessTokens.Items {
+				resolved, err := accesstoken.Resolve(&accessTokens.Items[i], resolver)
+				if err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "warning: AccessToken %s: %s\n", client.ObjectKeyFromObject(&accessTokens.Items[i]), err)
+					continue
+				}
+				accessTokens.Items[i] = *resolved
+			}
+
+			entries := inventory.Build(accessTokens.Items)
+
+			switch output {
+			case outputText:
+				return writeInventoryText(cmd.OutOrStdout(), entries, time.Now())
+			case outputJSON:
+				return writeJSON(cmd.OutOrStdout(), entries)
+			case outputCSV:
+				return writeInventoryCSV(cmd.OutOrStdout(), entries, time.Now())
+			default:
+				return fmt.Errorf("unknown output format %q", output)
+			}
+		},
+	}
+
+	cluster.addToFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "only list AccessTokens in this namespace (default: all namespaces)")
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "output format, one of: text, json, csv")
+
+	return cmd
+}
+
+// expiry formats the expiry of an entry.
+func expiry(e inventory.Entry) string {
+	if e.Expires == nil {
+		return "never"
+	}
+	return e.Expires.UTC().Format(time.RFC3339)
+}
+
+// riskSummary formats the findings of an entry as their highest severity and the IDs of the checks matched.
+func riskSummary(e inventory.Entry) string {
+	if len(e.Findings) == 0 {
+		return risk.SeverityNone.String()
+	}
+	return fmt.Sprintf("%s (%s)", e.MaxSeverity, strings.Join(findingIDs(e.Findings), ","))
+}
+
+func findingIDs(findings []risk.Finding) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, f := range findings {
+		if !seen[f.ID] {
+			seen[f.ID] = true
+			ids = append(ids, f.ID)
+		}
+	}
+	return ids
+}
+
+func writeInventoryText(w io.Writer, entries []inventory.Entry, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tNAME\tSERVICEACCOUNT\tSTATE\tNAMESPACES\tCLUSTER-SCOPED\tRISK\tAGE\tEXPIRES")
+	for _, e := range entries {
+		namespaces := strings.Join(e.Namespaces, ",")
+		if namespaces == "" {
+			namespaces = "<none>"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\t%s\t%s\t%s\n",
+			e.Namespace, e.Name, e.ServiceAccount, e.State, namespaces, e.ClusterScoped, riskSummary(e),
+			duration.HumanDuration(now.Sub(e.Created.Time)), expiry(e))
+	}
+	return tw.Flush()
+}
+
+func writeInventoryCSV(w io.Writer, entries []inventory.Entry, now time.Time) error {
+	cw := csv.NewWriter(w)
+	records := [][]string{{
+		"namespace", "name", "serviceAccount", "state", "namespaces", "clusterScoped", "maxSeverity", "findings",
+		"created", "ageSeconds", "expires",
+	}}
+	for _, e := range entries {
+		records = append(records, []string{
+			e.Namespace,
+			e.Name,
+			e.ServiceAccount,
+			e.State,
+			strings.Join(e.Namespaces, ";"),
+			strconv.FormatBool(e.ClusterScoped),
+			e.MaxSeverity.String(),
+			strings.Join(findingIDs(e.Findings), ";"),
+			e.Created.UTC().Format(time.RFC3339),
+			strconv.FormatInt(int64(now.Sub(e.Created.Time).Seconds()), 10),
+			expiry(e),
+		})
+	}
+	return cw.WriteAll(records)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/kubeconfig"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func kubeconfigCommand() *cobra.Command {
+	var (
+		cluster          clusterOpts
+		namespace        string
+		output           string
+		contextName      string
+		setCurrent       bool
+		defaultNamespace bool
+		timeout          time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "kubeconfig <name>",
+		Short: "Write a kubeconfig context authenticating with an AccessToken's token",
+		Long: `Kubeconfig waits for the AccessToken to become ready, reads its token Secret and prints a kubeconfig that
+authenticates with the token. With --output, the context is merged into the given kubeconfig file instead.`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, restConfig, err := cluster.client()
+			if err != nil {
+				return err
+			}
+			// populate CAData from CAFile, the CA bundle is used if the token Secret doesn't hold one
+			if err := rest.LoadTLSFiles(restConfig); err != nil {
+				return fmt.Errorf("loading TLS files: %w", err)
+			}
+
+			key := client.ObjectKey{Namespace: namespace, Name: args[0]}
+			accessToken, secret, err := waitForToken(cmd.Context(), c, key, timeout)
+			if err != nil {
+				return err
+			}
+
+			token, err := kubeconfig.TokenFromSecret(secret, restConfig.Host, restConfig.CAData)
+			if err != nil {
+				return err
+			}
+			if defaultNamespace && len(accessToken.Spec.NamespacedPermissions) > 0 {
+				token.Namespace = accessToken.Spec.NamespacedPermissions[0].Namespace
+			}
+
+			if contextName == "" {
+				contextName = fmt.Sprintf("%s-%s", key.Namespace, key.Name)
+			}
+			cfg := kubeconfig.New(contextName, token)
+
+			if output == "-" {
+				b, err := clientcmd.Write(*cfg)
+				if err != nil {
+					return err
+				}
+				_, err = cmd.OutOrStdout().Write(b)
+				return err
+			}
+
+			if err := kubeconfig.MergeIntoFile(output, cfg, setCurrent); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Context %q written to %s\n", contextName, output)
+			return nil
+		},
+	}
+
+	cluster.addToFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "namespace of the AccessToken")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "kubeconfig file to merge the context into, \"-\" to print a standalone kubeconfig")
+	cmd.Flags().StringVar(&contextName, "context-name", "", "name of the cluster, user and context (default: <namespace>-<name>)")
+	cmd.Flags().BoolVar(&setCurrent, "set-current", false, "make the context the current context of the --output file")
+	cmd.Flags().BoolVar(&defaultNamespace, "default-namespace", true, "default the context's namespace to the first namespace of spec.namespacedPermissions")
+	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "how long to wait for the AccessToken to become ready")
+
+	return cmd
+}
+
+// waitForToken waits until the AccessToken is ready and its token Secret holds a token.
+func waitForToken(
+	ctx context.Context,
+	c client.Client,
+	key client.ObjectKey,
+	timeout time.Duration,
+) (*v1alpha1.AccessToken, *corev1.Secret, error) {
+	accessToken := &v1alpha1.AccessToken{}
+	secret := &corev1.Secret{}
+
+	// notReady records why the token isn't ready yet, so that a timeout can be explained
+	var notReady error
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		if err := c.Get(ctx, key, accessToken); err != nil {
+			if kerrors.IsNotFound(err) {
+				notReady = err
+				return false, nil
+			}
+			return false, err
+		}
+
+		if ready := accessToken.GetCondition(api.TypeReady); ready.Status != corev1.ConditionTrue {
+			notReady = fmt.Errorf("%T %s is not ready: %s", accessToken, key, ready.Message)
+			return false, nil
+		}
+		if accessToken.Spec.Delivery != nil {
+			return false, fmt.Errorf("%T %s delivers its token encrypted, use the decrypt command", accessToken, key)
+		}
+		if accessToken.Status.TokenSecretRef == nil {
+			notReady = fmt.Errorf("%T %s has no status.tokenSecretRef", accessToken, key)
+			return false, nil
+		}
+
+		secretKey := client.ObjectKey{Namespace: key.Namespace, Name: *accessToken.Status.TokenSecretRef}
+		if err := c.Get(ctx, secretKey, secret); err != nil {
+			if kerrors.IsNotFound(err) {
+				notReady = err
+				return false, nil
+			}
+			return false, err
+		}
+		if len(secret.Data[corev1.ServiceAccountTokenKey]) == 0 {
+			notReady = fmt.Errorf("%T %s has not been populated with a token yet", secret, secretKey)
+			return false, nil
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		if notReady != nil && wait.Interrupted(err) {
+			err = errors.Join(err, notReady)
+		}
+		return nil, nil, fmt.Errorf("waiting for %T %s: %w", accessToken, key, err)
+	}
+
+	return accessToken, secret, nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
+	"github.com/reddit/achilles-token-controller/internal/manifest"
+	"github.com/reddit/achilles-token-controller/internal/risk"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputSARIF = "sarif"
+)
+
+// lintFinding is a risk finding located within a manifest.
+type lintFinding struct {
+	risk.Finding
+
+	// File the AccessToken was read from.
+	File string `json:"file"`
+
+	// AccessToken is the namespace/name of the AccessToken granting the rule.
+	AccessToken string `json:"accessToken"`
+}
+
+func lintCommand() *cobra.Command {
+	var (
+		files     []string
+		namespace string
+		output    string
+		failOn    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "lint -f <file>",
+		Short: "Report high-risk permissions granted by the AccessTokens in the given manifests",
+		Long: `Lint reads AccessTokens from manifests and classifies every rule they grant, including the rules of the
+AccessTokenTemplates, PermissionSets and ClusterPermissionSets they reference, against a built-in catalog of dangerous
+permissions. Referenced objects are looked up in the same manifests. It exits non-zero if any finding is at or above the --fail-on severity.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			threshold, err := risk.ParseSeverity(failOn)
+			if err != nil {
+				return err
+			}
+
+			scheme, err := intscheme.NewScheme()
+			if err != nil {
+				return err
+			}
+
+			sources, err := manifest.ReadFiles(scheme, cmd.InOrStdin(), files...)
+			if err != nil {
+				return err
+			}
+
+			resolver := manifestResolver(sources, namespace, files)
+
+			var findings []lintFinding
+			for _, src := range sources {
+				accessToken, ok := src.Object.(*v1alpha1.AccessToken)
+				if !ok {
+					continue
+				}
+				if accessToken.GetNamespace() == "" {
+					accessToken.SetNamespace(namespace)
+				}
+				resolved, err := accesstoken.Resolve(accessToken, resolver)
+				if err != nil {
+					return fmt.Errorf("%s: %w", src.Path, err)
+				}
+				for _, f := range risk.Classify(resolved.Spec) {
+					findings = append(findings, lintFinding{
+						Finding:     f,
+						File:        src.Path,
+						AccessToken: client.ObjectKeyFromObject(accessToken).String(),
+					})
+				}
+			}
+
+			switch output {
+			case outputText:
+				err = writeLintText(cmd.OutOrStdout(), findings)
+			case outputJSON:
+				err = writeJSON(cmd.OutOrStdout(), findings)
+			case outputSARIF:
+				err = writeJSON(cmd.OutOrStdout(), newSARIFReport(findings))
+			default:
+				err = fmt.Errorf("unknown output format %q", output)
+			}
+			if err != nil {
+				return err
+			}
+
+			if threshold == risk.SeverityNone {
+				return nil
+			}
+			var failed int
+			for _, f := range findings {
+				if f.Severity >= threshold {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("found %d findings with severity %s or higher", failed, threshold)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&files, "filename", "f", nil, "files or directories containing AccessTokens, \"-\" for stdin")
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "namespace of AccessTokens that don't specify one")
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "output format, one of: text, json, sarif")
+	cmd.Flags().StringVar(&failOn, "fail-on", risk.SeverityHigh.String(), "exit non-zero if any finding has this severity or higher, one of: none, low, medium, high, critical")
+	_ = cmd.MarkFlagRequired("filename")
+
+	return cmd
+}
+
+func writeLintText(w io.Writer, findings []lintFinding) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEVERITY\tCHECK\tACCESSTOKEN\tNAMESPACE\tPATH\tFILE")
+	for _, f := range findings {
+		namespace := f.Namespace
+		if namespace == "" {
+			namespace = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", f.Severity, f.ID, f.AccessToken, namespace, f.Path, f.File)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// The following types are the subset of SARIF 2.1.0 (https://docs.oasis-open.org/sarif/sarif/v2.1.0/) needed to
+// report findings to code scanning tools.
+
+type sarifReport struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           map[string]string  `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+func sarifLevel(s risk.Severity) string {
+	switch {
+	case s >= risk.SeverityHigh:
+		return "error"
+	case s == risk.SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+func newSARIFReport(findings []lintFinding) sarifReport {
+	rules := make([]sarifRule, 0, len(risk.Catalog))
+	for _, check := range risk.Catalog {
+		rules = append(rules, sarifRule{
+			ID:                   check.ID,
+			ShortDescription:     sarifMessage{Text: check.Description},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(check.Severity)},
+			Properties:           map[string]string{"severity": check.Severity.String()},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		results = append(results, sarifResult{
+			RuleID:  f.ID,
+			Level:   sarifLevel(f.Severity),
+			Message: sarifMessage{Text: fmt.Sprintf("AccessToken %s %s", f.AccessToken, f.Finding)},
+			Locations: []sarifLocation{
+				{
+					PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: f.File}},
+					LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: fmt.Sprintf("%s.%s", f.AccessToken, f.Path)}},
+				},
+			},
+		})
+	}
+
+	return sarifReport{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{Driver: sarifDriver{
+					Name:           ApplicationName,
+					InformationURI: "https://github.com/reddit/achilles-token-controller",
+					Rules:          rules,
+				}},
+				Results: results,
+			},
+		},
+	}
+}
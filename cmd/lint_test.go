@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("lint", func() {
+	const permissionSet = `apiVersion: group.example.com/v1alpha1
+kind: ClusterPermissionSet
+metadata:
+  name: secrets-reader
+spec:
+  rules:
+  - apiGroups: [""]
+    resources: [secrets]
+    verbs: [get, list]
+`
+	const accessToken = `apiVersion: group.example.com/v1alpha1
+kind: AccessToken
+metadata:
+  name: deployer
+spec:
+  namespacedPermissions:
+  - namespace: team-a
+    rules:
+    - apiGroups: [""]
+      resources: [configmaps]
+      verbs: [get]
+    permissionSetRefs:
+    - kind: ClusterPermissionSet
+      name: secrets-reader
+`
+
+	// write writes the manifests to a file and returns its path.
+	write := func(manifests ...string) string {
+		file := filepath.Join(GinkgoT().TempDir(), "accesstoken.yaml")
+		Expect(os.WriteFile(file, []byte(strings.Join(manifests, "---\n")), 0o600)).To(Succeed())
+		return file
+	}
+
+	run := func(args ...string) (*bytes.Buffer, error) {
+		out := &bytes.Buffer{}
+		cmd := lintCommand()
+		cmd.SetOut(out)
+		cmd.SetArgs(args)
+		return out, cmd.Execute()
+	}
+
+	It("reports the rules of referenced permission sets as SARIF", func() {
+		file := write(permissionSet, accessToken)
+		out, err := run("-f", file, "-o", outputSARIF)
+		Expect(err).To(MatchError("found 1 findings with severity high or higher"))
+
+		report := sarifReport{}
+		Expect(json.Unmarshal(out.Bytes(), &report)).To(Succeed())
+		Expect(report.Runs).To(HaveLen(1))
+		Expect(report.Runs[0].Results).To(HaveExactElements(HaveField("RuleID", "secrets-read")))
+
+		result := report.Runs[0].Results[0]
+		Expect(result.Level).To(Equal("error"))
+		Expect(result.Locations).To(HaveExactElements(And(
+			HaveField("PhysicalLocation.ArtifactLocation.URI", file),
+			HaveField("LogicalLocations", HaveExactElements(HaveField("FullyQualifiedName", "default/deployer.spec.namespacedPermissions[0].rules[1]"))),
+		)))
+	})
+
+	It("refuses AccessTokens referencing permission sets missing from the manifests", func() {
+		_, err := run("-f", write(accessToken))
+		Expect(err).To(MatchError(ContainSubstring("referenced ClusterPermissionSet secrets-reader not found")))
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/reddit/achilles-sdk/pkg/bootstrap"
@@ -12,20 +13,47 @@ import (
 	"github.com/reddit/achilles-sdk/pkg/logging"
 	"github.com/reddit/achilles-sdk/pkg/meta"
 	"github.com/reddit/achilles-sdk/pkg/ratelimiter"
+	"github.com/reddit/achilles-token-controller/internal/admissionpolicy"
+	"github.com/reddit/achilles-token-controller/internal/audit"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesssummary"
 	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesstokengenerator"
 	"github.com/reddit/achilles-token-controller/internal/controlplane"
+	"github.com/reddit/achilles-token-controller/internal/denylist"
 	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/reddit/achilles-token-controller/internal/tokenmetrics"
+	"github.com/reddit/achilles-token-controller/internal/vending"
+	"github.com/reddit/achilles-token-controller/internal/webhook"
+	"github.com/reddit/achilles-token-controller/internal/webhookcert"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 // opts store any optional settings that instruct how the manager and
 // controllers should run. Typically these are fed values from CLI flags or
 // environment variables.
 type opts struct {
-	bootstrap   bootstrap.Options
-	disableSync bool
+	bootstrap                bootstrap.Options
+	disableSync              bool
+	disableAdmissionPolicy   bool
+	enableWebhooks           bool
+	webhookCertSecret        string
+	webhookService           string
+	requireNamespaceConsent  bool
+	deniedNamespaces         []string
+	deniedResources          []string
+	controllerNamespace      string
+	controllerServiceAccount string
+	auditLogPath             string
+	auditWebhookURL          string
+	auditWebhookTimeout      time.Duration
+	vendingBindAddress       string
+	vendingCertFile          string
+	vendingKeyFile           string
+	vendingServer            string
 }
 
 const (
@@ -60,6 +88,18 @@ func rootCommand(ctx context.Context) *cobra.Command {
 	}
 	o.addToFlags(cmd.Flags())
 
+	cmd.AddCommand(
+		renderCommand(),
+		lintCommand(),
+		kubeconfigCommand(),
+		doctorCommand(),
+		inventoryCommand(),
+		whoCanCommand(),
+		keygenCommand(),
+		decryptCommand(),
+		agentCommand(),
+	)
+
 	return cmd
 }
 
@@ -67,6 +107,22 @@ func (o *opts) addToFlags(flags *pflag.FlagSet) {
 	o.bootstrap.AddToFlags(flags)
 
 	flags.BoolVar(&o.disableSync, "disable-sync", false, "run controllers in a dry-run mode (default: false)")
+	flags.BoolVar(&o.disableAdmissionPolicy, "disable-admission-policy", false, "do not install the ValidatingAdmissionPolicy that locks managed objects against manual edits (default: false)")
+	flags.BoolVar(&o.enableWebhooks, "enable-webhooks", false, "serve the admission webhooks (default: false)")
+	flags.StringVar(&o.webhookCertSecret, "webhook-cert-secret", "achilles-token-controller-webhook-cert", "Secret in the controller namespace to store the self-signed webhook serving certificates in, empty to provide the certificates yourself, e.g. with cert-manager")
+	flags.StringVar(&o.webhookService, "webhook-service", "achilles-token-controller-webhook", "name of the Service in the controller namespace in front of the webhook server")
+	flags.BoolVar(&o.requireNamespaceConsent, "require-namespace-consent", false, "only grant permissions in namespaces that consent to grants from the AccessToken's namespace through an AccessTokenGrant, label or annotation, otherwise namespaces that don't consent are only reported (default: false)")
+	flags.StringSliceVar(&o.deniedNamespaces, "denied-namespaces", nil, "namespaces no AccessToken may be granted permissions in, e.g. kube-system")
+	flags.StringSliceVar(&o.deniedResources, "denied-resources", nil, "resources no AccessToken may be granted access to, as resource or resource.group, e.g. secrets,nodes")
+	flags.StringVar(&o.controllerNamespace, "controller-namespace", "achilles-system", "namespace the controller runs in")
+	flags.StringVar(&o.controllerServiceAccount, "controller-service-account", "achilles-token-controller-manager", "name of the ServiceAccount the controller runs as")
+	flags.StringVar(&o.auditLogPath, "audit-log-path", "", "path of a file to append permission audit records to as JSON lines, \"-\" for stdout (default: disabled)")
+	flags.StringVar(&o.auditWebhookURL, "audit-webhook-url", "", "URL to POST permission audit records to (default: disabled)")
+	flags.DurationVar(&o.auditWebhookTimeout, "audit-webhook-timeout", 10*time.Second, "timeout for requests to the audit webhook")
+	flags.StringVar(&o.vendingBindAddress, "vending-bind-address", "", "address the token vending endpoint listens on, e.g. :8443 (default: disabled)")
+	flags.StringVar(&o.vendingCertFile, "vending-cert-file", "", "PEM encoded TLS certificate of the token vending endpoint")
+	flags.StringVar(&o.vendingKeyFile, "vending-key-file", "", "PEM encoded TLS key of the token vending endpoint")
+	flags.StringVar(&o.vendingServer, "vending-server", "", "API server URL written to vended kubeconfigs (default: the URL the manager connects to)")
 }
 
 // initStartFunc accepts options that are typically set from CLI flags or
@@ -85,21 +141,94 @@ func initStartFunc(o *opts) bootstrap.StartFunc {
 		// metrics sink
 		promReg := prometheus.NewRegistry()
 		promMetrics := metrics.MustMakeMetrics(mgr.GetScheme(), promReg)
+		// token metrics are served by the manager's metrics endpoint
+		tokenMetrics := tokenmetrics.MustMakeMetrics(mgr.GetClient(), accesstoken.ClientResolveFunc(mgr.GetClient()), ctrlmetrics.Registry)
+
+		// audit sinks
+		var auditSinks []audit.Sink
+		if o.auditLogPath != "" {
+			sink, err := audit.NewFileSink(o.auditLogPath)
+			if err != nil {
+				return err
+			}
+			auditSinks = append(auditSinks, sink)
+		}
+		if o.auditWebhookURL != "" {
+			auditSinks = append(auditSinks, audit.NewWebhookSink(o.auditWebhookURL, o.auditWebhookTimeout))
+		}
 
 		// map flag values into controlplane's context
 		cpCtx := controlplane.Context{
-			DisableSync: o.disableSync,
-			Metrics:     promMetrics,
+			DisableSync:  o.disableSync,
+			Metrics:      promMetrics,
+			TokenMetrics: tokenMetrics,
+			Auditor:      audit.NewLogger(auditSinks...),
+
+			RequireNamespaceConsent: o.requireNamespaceConsent,
+			DenyList:                denylist.New(o.deniedNamespaces, o.deniedResources),
 		}
 		log, err := logging.FromContext(ctx)
 		if err != nil {
 			return fmt.Errorf("getting logger from context: %w", err)
 		}
 
+		if !o.disableSync && !o.disableAdmissionPolicy {
+			if err := admissionpolicy.Setup(mgr, client, log, o.controllerNamespace, o.controllerServiceAccount); err != nil {
+				return fmt.Errorf("setting up admission policy: %w", err)
+			}
+		}
+
+		if o.enableWebhooks {
+			if o.webhookCertSecret != "" {
+				if err := webhookcert.Setup(ctx, mgr, webhookcert.Options{
+					Namespace:                o.controllerNamespace,
+					SecretName:               o.webhookCertSecret,
+					ServiceName:              o.webhookService,
+					WebhookConfigurationName: ApplicationName,
+				}, log); err != nil {
+					return fmt.Errorf("setting up webhook certificates: %w", err)
+				}
+			}
+			if err := webhook.SetupAccessTokenWebhook(mgr); err != nil {
+				return fmt.Errorf("setting up AccessToken webhook: %w", err)
+			}
+			if err := webhook.SetupPodInjectionWebhook(mgr); err != nil {
+				return fmt.Errorf("setting up Pod injection webhook: %w", err)
+			}
+		}
+
+		if o.vendingBindAddress != "" {
+			restConfig := rest.CopyConfig(mgr.GetConfig())
+			if err := rest.LoadTLSFiles(restConfig); err != nil {
+				return fmt.Errorf("loading TLS files: %w", err)
+			}
+			server := o.vendingServer
+			if server == "" {
+				server = restConfig.Host
+			}
+			if err := vending.Setup(mgr, cpCtx.Auditor, log, vending.Options{
+				BindAddress: o.vendingBindAddress,
+				CertFile:    o.vendingCertFile,
+				KeyFile:     o.vendingKeyFile,
+				Server:      server,
+				CAData:      restConfig.CAData,
+			}); err != nil {
+				return fmt.Errorf("setting up token vending endpoint: %w", err)
+			}
+		}
+
 		log.Info("starting controllers...")
 		if err := accesstoken.SetupController(ctx, cpCtx, mgr, rl, client); err != nil {
 			return fmt.Errorf("setting up AccessToken controller: %w", err)
 		}
+		generatorRL := ratelimiter.NewDefaultProviderRateLimiter(ratelimiter.DefaultProviderRPS)
+		if err := accesstokengenerator.SetupController(ctx, cpCtx, mgr, generatorRL, client); err != nil {
+			return fmt.Errorf("setting up AccessTokenGenerator controller: %w", err)
+		}
+		summaryRL := ratelimiter.NewDefaultProviderRateLimiter(ratelimiter.DefaultProviderRPS)
+		if err := accesssummary.SetupController(ctx, cpCtx, mgr, summaryRL, client); err != nil {
+			return fmt.Errorf("setting up AccessSummary controller: %w", err)
+		}
 		return nil
 	}
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
+	"github.com/reddit/achilles-token-controller/internal/manifest"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/spf13/cobra"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func renderCommand() *cobra.Command {
+	var (
+		files     []string
+		namespace string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "render -f <file>",
+		Short: "Print the RBAC objects the AccessTokens in the given manifests would produce",
+		Long: `Render reads AccessTokens from manifests and prints the ServiceAccount, Secret, Roles, RoleBindings,
+ClusterRole and ClusterRoleBinding the controller would provision for them as YAML, without contacting a cluster.
+AccessTokenTemplates and permission sets referenced by the AccessTokens must be part of the manifests.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scheme, err := intscheme.NewScheme()
+			if err != nil {
+				return err
+			}
+
+			sources, err := manifest.ReadFiles(scheme, cmd.InOrStdin(), files...)
+			if err != nil {
+				return err
+			}
+
+			resolver := manifestResolver(sources, namespace, files)
+
+			var objs []client.Object
+			for _, src := range sources {
+				accessToken, ok := src.Object.(*v1alpha1.AccessToken)
+				if !ok {
+					continue
+				}
+				if accessToken.GetNamespace() == "" {
+					accessToken.SetNamespace(namespace)
+				}
+				resolved, err := accesstoken.Resolve(accessToken, resolver)
+				if err != nil {
+					return fmt.Errorf("%s: %w", src.Path, err)
+				}
+				objs = append(objs, accesstoken.Render(resolved)...)
+			}
+
+			if len(objs) == 0 {
+				return fmt.Errorf("no AccessTokens found in %v", files)
+			}
+
+			return manifest.WriteYAML(scheme, cmd.OutOrStdout(), objs...)
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&files, "filename", "f", nil, "files or directories containing AccessTokens, \"-\" for stdin")
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "namespace of AccessTokens that don't specify one")
+	_ = cmd.MarkFlagRequired("filename")
+
+	return cmd
+}
+
+// manifestResolver returns a Resolver looking up the AccessTokenTemplates, PermissionSets and ClusterPermissionSets
+// read from the manifests.
+func manifestResolver(sources []manifest.Source, namespace string, files []string) accesstoken.Resolver {
+	templates := map[string]*v1alpha1.AccessTokenTemplate{}
+	sets := map[string][]rbacv1.PolicyRule{}
+	for _, src := range sources {
+		switch o := src.Object.(type) {
+		case *v1alpha1.AccessTokenTemplate:
+			templates[o.GetName()] = o
+		case *v1alpha1.PermissionSet:
+			if o.GetNamespace() == "" {
+				o.SetNamespace(namespace)
+			}
+			sets[permissionSetKey(v1alpha1.PermissionSetKind, o.GetNamespace(), o.GetName())] = o.Spec.Rules
+		case *v1alpha1.ClusterPermissionSet:
+			sets[permissionSetKey(v1alpha1.ClusterPermissionSetKind, "", o.GetName())] = o.Spec.Rules
+		}
+	}
+
+	return accesstoken.Resolver{
+		Rules: func(ref v1alpha1.PermissionSetRef, namespace string) ([]rbacv1.PolicyRule, error) {
+			kind := ref.Kind
+			if kind == "" {
+				kind = v1alpha1.PermissionSetKind
+			}
+			rules, ok := sets[permissionSetKey(kind, namespace, ref.Name)]
+			if !ok {
+				return nil, fmt.Errorf("referenced %s %s not found in %v", kind, ref.Name, files)
+			}
+			return rules, nil
+		},
+		Template: func(name string) (*v1alpha1.AccessTokenTemplate, error) {
+			tmpl, ok := templates[name]
+			if !ok {
+				return nil, fmt.Errorf("referenced AccessTokenTemplate %s not found in %v", name, files)
+			}
+			return tmpl, nil
+		},
+	}
+}
+
+// permissionSetKey identifies a permission set read from the manifests, ClusterPermissionSets have no namespace.
+func permissionSetKey(kind, namespace, name string) string {
+	if kind == v1alpha1.ClusterPermissionSetKind {
+		namespace = ""
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
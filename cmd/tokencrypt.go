@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/tokencrypt"
+	"github.com/spf13/cobra"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func keygenCommand() *cobra.Command {
+	var privateKeyFile string
+
+	cmd := &cobra.Command{
+		Use:   "keygen --private-key-file <path>",
+		Short: "Generate a key pair for encrypted token delivery",
+		Long: `Keygen writes a new X25519 private key to --private-key-file and prints the matching public key, to be set as
+spec.delivery.publicKey of an AccessToken. Keep the private key with the token's consumer, e.g. as a CI secret.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			privateKey, publicKey, err := tokencrypt.GenerateKey()
+			if err != nil {
+				return err
+			}
+
+			// O_EXCL refuses to overwrite an existing key, which would make its tokens undecryptable
+			f, err := os.OpenFile(privateKeyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+			if err != nil {
+				return fmt.Errorf("creating private key file: %w", err)
+			}
+			if _, err := fmt.Fprintln(f, privateKey); err != nil {
+				_ = f.Close()
+				return fmt.Errorf("writing private key file: %w", err)
+			}
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("writing private key file: %w", err)
+			}
+
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), publicKey)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&privateKeyFile, "private-key-file", "", "file to write the private key to, it must not exist")
+	_ = cmd.MarkFlagRequired("private-key-file")
+
+	return cmd
+}
+
+func decryptCommand() *cobra.Command {
+	var (
+		cluster        clusterOpts
+		namespace      string
+		privateKeyFile string
+		sealedFile     string
+		timeout        time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "decrypt [name] --private-key-file <path>",
+		Short: "Decrypt the token delivered to an AccessToken's public key",
+		Long: `Decrypt waits for the AccessToken to hold a token encrypted to spec.delivery.publicKey, decrypts it with the
+private key and prints it. With --sealed-file, the encrypted token is read from the file ("-" for stdin) instead of the
+cluster, e.g. after copying status.encryptedToken into a CI job.`,
+		Args:          cobra.MaximumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			privateKey, err := os.ReadFile(privateKeyFile)
+			if err != nil {
+				return fmt.Errorf("reading private key file: %w", err)
+			}
+
+			var sealed string
+			switch {
+			case sealedFile != "":
+				sealed, err = readSealed(cmd.InOrStdin(), sealedFile)
+			case len(args) == 1:
+				var c client.Client
+				c, _, err = cluster.client()
+				if err != nil {
+					return err
+				}
+				sealed, err = waitForEncryptedToken(cmd.Context(), c, client.ObjectKey{Namespace: namespace, Name: args[0]}, timeout)
+			default:
+				return errors.New("either an AccessToken name or --sealed-file is required")
+			}
+			if err != nil {
+				return err
+			}
+
+			token, err := tokencrypt.Decrypt(string(privateKey), sealed)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), string(token))
+			return err
+		},
+	}
+
+	cluster.addToFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "namespace of the AccessToken")
+	cmd.Flags().StringVar(&privateKeyFile, "private-key-file", "", "file holding the private key written by keygen")
+	cmd.Flags().StringVar(&sealedFile, "sealed-file", "", "file holding the encrypted token, \"-\" for stdin, instead of reading it from the AccessToken")
+	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "how long to wait for the token to be delivered")
+	_ = cmd.MarkFlagRequired("private-key-file")
+
+	return cmd
+}
+
+func readSealed(stdin io.Reader, path string) (string, error) {
+	var b []byte
+	var err error
+	if path == "-" {
+		b, err = io.ReadAll(stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading encrypted token: %w", err)
+	}
+	return string(b), nil
+}
+
+// waitForEncryptedToken waits until the AccessToken holds a token encrypted to its current public key.
+func waitForEncryptedToken(ctx context.Context, c client.Client, key client.ObjectKey, timeout time.Duration) (string, error) {
+	accessToken := &v1alpha1.AccessToken{}
+
+	// notReady records why the token isn't delivered yet, so that a timeout can be explained
+	var notReady error
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		if err := c.Get(ctx, key, accessToken); err != nil {
+			if kerrors.IsNotFound(err) {
+				notReady = err
+				return false, nil
+			}
+			return false, err
+		}
+
+		delivery := accessToken.Spec.Delivery
+		if delivery == nil {
+			return false, fmt.Errorf("%T %s has no spec.delivery, its token is stored in a Secret", accessToken, key)
+		}
+		if accessToken.Status.EncryptedToken == "" || accessToken.Status.EncryptedTokenPublicKey != delivery.PublicKey {
+			notReady = fmt.Errorf("%T %s has no token encrypted to spec.delivery.publicKey yet", accessToken, key)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		if notReady != nil && wait.Interrupted(err) {
+			err = errors.Join(err, notReady)
+		}
+		return "", fmt.Errorf("waiting for %T %s: %w", accessToken, key, err)
+	}
+
+	return accessToken.Status.EncryptedToken, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reddit/achilles-token-controller/internal/whocan"
+	"github.com/spf13/cobra"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func whoCanCommand() *cobra.Command {
+	var (
+		cluster   clusterOpts
+		namespace string
+		output    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "who-can -n <namespace> [verb [resource]]",
+		Short: "List the AccessTokens that can access a namespace",
+		Long: `Who-can lists every AccessToken granted access to the namespace, either through a RoleBinding in the namespace
+or a ClusterRoleBinding. The access can be narrowed to a verb and a resource, e.g. "get pods", "create pods/exec" or
+"patch deployments.apps".`,
+		Args:          cobra.MaximumNArgs(2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q := whocan.Query{Namespace: namespace}
+			if len(args) > 0 {
+				q.Verb = args[0]
+			}
+			if len(args) > 1 {
+				q.Resource, q.APIGroup = parseResource(args[1])
+			}
+
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+
+			c, err := cluster.managedCache(ctx)
+			if err != nil {
+				return err
+			}
+
+			grants, err := whocan.Lookup(ctx, c, q)
+			if err != nil {
+				return err
+			}
+
+			switch output {
+			case outputText:
+				return writeWhoCanText(cmd.OutOrStdout(), grants)
+			case outputJSON:
+				return writeJSON(cmd.OutOrStdout(), grants)
+			default:
+				return fmt.Errorf("unknown output format %q", output)
+			}
+		},
+	}
+
+	cluster.addToFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace to look up access to")
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "output format, one of: text, json")
+	_ = cmd.MarkFlagRequired("namespace")
+
+	return cmd
+}
+
+// parseResource parses a resource in kubectl's "resource.group/subresource" form.
+func parseResource(s string) (resource, group string) {
+	resource, subresource, hasSubresource := strings.Cut(s, "/")
+	resource, group, _ = strings.Cut(resource, ".")
+	if hasSubresource {
+		resource = fmt.Sprintf("%s/%s", resource, subresource)
+	}
+	return resource, group
+}
+
+func writeWhoCanText(w io.Writer, grants []whocan.Grant) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCESSTOKEN\tSERVICEACCOUNT\tBINDING\tCLUSTER-SCOPED\tRULES")
+	for _, g := range grants {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\n", g.AccessToken, g.ServiceAccount, g.Binding, g.ClusterScoped, rulesString(g.Rules))
+	}
+	return tw.Flush()
+}
+
+// rulesString formats rules compactly, e.g. "get,list pods,configmaps; * deployments.apps".
+func rulesString(rules []rbacv1.PolicyRule) string {
+	var s []string
+	for _, rule := range rules {
+		resources := make([]string, 0, len(rule.Resources))
+		for _, r := range rule.Resources {
+			for _, g := range rule.APIGroups {
+				if g == "" {
+					resources = append(resources, r)
+				} else {
+					resources = append(resources, fmt.Sprintf("%s.%s", r, g))
+				}
+			}
+		}
+		s = append(s, fmt.Sprintf("%s %s", strings.Join(rule.Verbs, ","), strings.Join(resources, ",")))
+	}
+	return strings.Join(s, "; ")
+}
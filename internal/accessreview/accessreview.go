@@ -0,0 +1,180 @@
+// Package accessreview checks the effective access of a ServiceAccount with SubjectAccessReviews.
+package accessreview
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// Check is a single access request to review.
+type Check struct {
+	// ResourceAttributes describes a request for a resource. Mutually exclusive with NonResourceAttributes.
+	ResourceAttributes *authorizationv1.ResourceAttributes
+
+	// NonResourceAttributes describes a request for a non-resource URL. Mutually exclusive with ResourceAttributes.
+	NonResourceAttributes *authorizationv1.NonResourceAttributes
+}
+
+// String returns a human readable representation of the check, similar to the arguments of `kubectl auth can-i`.
+func (c Check) String() string {
+	if c.NonResourceAttributes != nil {
+		return fmt.Sprintf("%s %s", c.NonResourceAttributes.Verb, c.NonResourceAttributes.Path)
+	}
+
+	a := c.ResourceAttributes
+	resource := a.Resource
+	if a.Group != "" {
+		resource = fmt.Sprintf("%s.%s", resource, a.Group)
+	}
+	if a.Subresource != "" {
+		resource = fmt.Sprintf("%s/%s", resource, a.Subresource)
+	}
+	if a.Name != "" {
+		resource = fmt.Sprintf("%s/%s", resource, a.Name)
+	}
+
+	scope := "cluster-wide"
+	if a.Namespace != "" {
+		scope = fmt.Sprintf("in namespace %q", a.Namespace)
+	}
+
+	return fmt.Sprintf("%s %s %s", a.Verb, resource, scope)
+}
+
+// Result is the outcome of a Check.
+type Result struct {
+	Check
+
+	// Allowed is true if the API server authorized the request.
+	Allowed bool
+
+	// Reason is the authorizer's explanation, if any.
+	Reason string
+}
+
+// ServiceAccountUser identifies a ServiceAccount the way the API server authenticates its tokens.
+type ServiceAccountUser struct {
+	Namespace string
+	Name      string
+}
+
+// Username returns the username of the ServiceAccount.
+func (u ServiceAccountUser) Username() string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", u.Namespace, u.Name)
+}
+
+// Groups returns the groups every ServiceAccount token is authenticated with.
+func (u ServiceAccountUser) Groups() []string {
+	return []string{
+		"system:serviceaccounts",
+		fmt.Sprintf("system:serviceaccounts:%s", u.Namespace),
+		"system:authenticated",
+	}
+}
+
+// Reviewer runs SubjectAccessReviews against the API server.
+type Reviewer struct {
+	c client.Client
+}
+
+// NewReviewer returns a Reviewer using the provided client.
+func NewReviewer(c client.Client) *Reviewer {
+	return &Reviewer{c: c}
+}
+
+// Review runs the checks as the provided user.
+func (r *Reviewer) Review(ctx context.Context, user ServiceAccountUser, checks []Check) ([]Result, error) {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		sar := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:                  user.Username(),
+				Groups:                user.Groups(),
+				ResourceAttributes:    check.ResourceAttributes,
+				NonResourceAttributes: check.NonResourceAttributes,
+			},
+		}
+		if err := r.c.Create(ctx, sar); err != nil {
+			return nil, fmt.Errorf("creating SubjectAccessReview for %q: %w", check, err)
+		}
+
+		results = append(results, Result{
+			Check:   check,
+			Allowed: sar.Status.Allowed && !sar.Status.Denied,
+			Reason:  sar.Status.Reason,
+		})
+	}
+	return results, nil
+}
+
+// SampleRule returns a representative sample of checks for the rule applied to the namespace, or cluster-wide if the
+// namespace is empty. Every verb is checked against the first resource, and every other resource and API group against
+// the first verb, so that each value declared by the rule is exercised at least once.
+func SampleRule(rule rbacv1.PolicyRule, namespace string) []Check {
+	if len(rule.Verbs) == 0 {
+		return nil
+	}
+
+	var checks []Check
+
+	if len(rule.NonResourceURLs) > 0 {
+		for _, verb := range rule.Verbs {
+			checks = append(checks, Check{NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: rule.NonResourceURLs[0],
+				Verb: verb,
+			}})
+		}
+		for _, path := range rule.NonResourceURLs[1:] {
+			checks = append(checks, Check{NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: path,
+				Verb: rule.Verbs[0],
+			}})
+		}
+		return checks
+	}
+
+	if len(rule.Resources) == 0 {
+		return nil
+	}
+
+	var group, name string
+	if len(rule.APIGroups) > 0 {
+		group = rule.APIGroups[0]
+	}
+	if len(rule.ResourceNames) > 0 {
+		name = rule.ResourceNames[0]
+	}
+
+	attributes := func(verb, resource string) *authorizationv1.ResourceAttributes {
+		resource, subresource, _ := strings.Cut(resource, "/")
+		return &authorizationv1.ResourceAttributes{
+			Namespace:   namespace,
+			Verb:        verb,
+			Group:       group,
+			Resource:    resource,
+			Subresource: subresource,
+			Name:        name,
+		}
+	}
+
+	for _, verb := range rule.Verbs {
+		checks = append(checks, Check{ResourceAttributes: attributes(verb, rule.Resources[0])})
+	}
+	for _, resource := range rule.Resources[1:] {
+		checks = append(checks, Check{ResourceAttributes: attributes(rule.Verbs[0], resource)})
+	}
+	for _, g := range rule.APIGroups[min(1, len(rule.APIGroups)):] {
+		a := attributes(rule.Verbs[0], rule.Resources[0])
+		a.Group = g
+		checks = append(checks, Check{ResourceAttributes: a})
+	}
+
+	return checks
+}
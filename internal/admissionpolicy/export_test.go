@@ -0,0 +1,13 @@
+package admissionpolicy
+
+import "sigs.k8s.io/controller-runtime/pkg/client"
+
+// Build returns the objects installed for the controller's ServiceAccount.
+func Build(controllerNamespace, controllerServiceAccount string) []client.Object {
+	return newBuilder(controllerNamespace, controllerServiceAccount).build()
+}
+
+// Expression returns the CEL expression of the policy installed for the controller's ServiceAccount.
+func Expression(controllerNamespace, controllerServiceAccount string) string {
+	return newBuilder(controllerNamespace, controllerServiceAccount).expression()
+}
@@ -0,0 +1,195 @@
+// Package admissionpolicy contains the ValidatingAdmissionPolicy that locks objects managed by the controller
+// against manual edits.
+package admissionpolicy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reddit/achilles-sdk/pkg/io"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingadmissionpolicies;validatingadmissionpolicybindings,verbs=*
+
+const (
+	// PolicyName is the name of the ValidatingAdmissionPolicy and its binding.
+	PolicyName = "achilles-token-controller-managed-objects"
+
+	// reapplyInterval is how often the policy and its binding are re-applied, so that manual edits or deletions are
+	// reverted.
+	reapplyInterval = 10 * time.Minute
+)
+
+// exemptUsernames are the built-in identities that legitimately mutate managed objects: the kube-controller-manager
+// populating the token Secret, and the garbage collector and namespace controller removing owned objects and objects
+// of deleted namespaces when the kube-controller-manager runs its controllers under their own ServiceAccounts.
+var exemptUsernames = []string{
+	"system:kube-controller-manager",
+	fmt.Sprintf("system:serviceaccount:%s:generic-garbage-collector", metav1.NamespaceSystem),
+	fmt.Sprintf("system:serviceaccount:%s:namespace-controller", metav1.NamespaceSystem),
+}
+
+type builder struct {
+	controllerUsername string
+}
+
+func newBuilder(controllerNamespace, controllerServiceAccount string) *builder {
+	return &builder{
+		controllerUsername: fmt.Sprintf("system:serviceaccount:%s:%s", controllerNamespace, controllerServiceAccount),
+	}
+}
+
+func (b *builder) build() []client.Object {
+	return []client.Object{
+		b.policy(),
+		b.binding(),
+	}
+}
+
+func (b *builder) policy() *admissionregistrationv1.ValidatingAdmissionPolicy {
+	operations := []admissionregistrationv1.OperationType{
+		admissionregistrationv1.Update,
+		admissionregistrationv1.Delete,
+	}
+
+	return &admissionregistrationv1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: PolicyName,
+		},
+		Spec: admissionregistrationv1.ValidatingAdmissionPolicySpec{
+			FailurePolicy: ptr.To(admissionregistrationv1.Fail),
+			MatchConstraints: &admissionregistrationv1.MatchResources{
+				// NOTE: for DELETE requests the object selector is evaluated against the existing object
+				ObjectSelector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{
+							Key:      v1alpha1.LabelAccessTokenName,
+							Operator: metav1.LabelSelectorOpExists,
+						},
+					},
+				},
+				ResourceRules: []admissionregistrationv1.NamedRuleWithOperations{
+					{
+						RuleWithOperations: admissionregistrationv1.RuleWithOperations{
+							Operations: operations,
+							Rule: admissionregistrationv1.Rule{
+								APIGroups:   []string{corev1.GroupName},
+								APIVersions: []string{corev1.SchemeGroupVersion.Version},
+								Resources:   []string{"serviceaccounts", "secrets"},
+							},
+						},
+					},
+					{
+						RuleWithOperations: admissionregistrationv1.RuleWithOperations{
+							Operations: operations,
+							Rule: admissionregistrationv1.Rule{
+								APIGroups:   []string{rbacv1.GroupName},
+								APIVersions: []string{rbacv1.SchemeGroupVersion.Version},
+								Resources:   []string{"roles", "rolebindings", "clusterroles", "clusterrolebindings"},
+							},
+						},
+					},
+				},
+			},
+			Validations: []admissionregistrationv1.Validation{
+				{
+					Expression: b.expression(),
+					Message:    fmt.Sprintf("objects labeled %q are managed by an AccessToken and may only be modified by the achilles-token-controller", v1alpha1.LabelAccessTokenName),
+					Reason:     ptr.To(metav1.StatusReasonForbidden),
+				},
+			},
+		},
+	}
+}
+
+func (b *builder) binding() *admissionregistrationv1.ValidatingAdmissionPolicyBinding {
+	return &admissionregistrationv1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: PolicyName,
+		},
+		Spec: admissionregistrationv1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        PolicyName,
+			ValidationActions: []admissionregistrationv1.ValidationAction{admissionregistrationv1.Deny},
+		},
+	}
+}
+
+// expression returns the CEL expression admitting requests only from the controller and exempt built-in identities.
+func (b *builder) expression() string {
+	usernames := append([]string{b.controllerUsername}, exemptUsernames...)
+
+	quoted := make([]string, len(usernames))
+	for i, u := range usernames {
+		quoted[i] = strconv.Quote(u)
+	}
+
+	return fmt.Sprintf("request.userInfo.username in [%s]", strings.Join(quoted, ", "))
+}
+
+// Setup registers a runnable with the manager that installs the admission policy and its binding once the manager
+// starts and re-applies them periodically. controllerNamespace and controllerServiceAccount identify the ServiceAccount
+// the controller runs as, which is the only non built-in identity allowed to modify managed objects.
+func Setup(
+	mgr manager.Manager,
+	c *io.ClientApplicator,
+	log *zap.SugaredLogger,
+	controllerNamespace string,
+	controllerServiceAccount string,
+) error {
+	return mgr.Add(&applier{
+		c:    c,
+		objs: newBuilder(controllerNamespace, controllerServiceAccount).build(),
+		log:  log,
+	})
+}
+
+// applier applies the policy and its binding.
+type applier struct {
+	c    *io.ClientApplicator
+	objs []client.Object
+	log  *zap.SugaredLogger
+}
+
+// Start applies the objects, then re-applies them until the context is cancelled. Failures to re-apply are logged and
+// retried.
+func (a *applier) Start(ctx context.Context) error {
+	if err := a.apply(ctx); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(reapplyInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := a.apply(ctx); err != nil {
+				a.log.Errorw("re-applying admission policy", "error", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (a *applier) apply(ctx context.Context) error {
+	for _, o := range a.objs {
+		// apply a copy, the applicator populates the object from the response
+		if err := a.c.Apply(ctx, o.DeepCopyObject().(client.Object)); err != nil {
+			return fmt.Errorf("applying %T %s: %w", o, client.ObjectKeyFromObject(o), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package admissionpolicy_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/admissionpolicy"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Policy", func() {
+	It("should only admit the controller and the built-in controllers removing managed objects", func() {
+		Expect(admissionpolicy.Expression("achilles-system", "achilles-token-controller")).To(Equal(
+			`request.userInfo.username in [` +
+				`"system:serviceaccount:achilles-system:achilles-token-controller", ` +
+				`"system:kube-controller-manager", ` +
+				`"system:serviceaccount:kube-system:generic-garbage-collector", ` +
+				`"system:serviceaccount:kube-system:namespace-controller"]`,
+		))
+	})
+
+	It("should bind the policy to managed objects", func() {
+		objs := admissionpolicy.Build("achilles-system", "achilles-token-controller")
+		Expect(objs).To(HaveLen(2))
+
+		policy, ok := objs[0].(*admissionregistrationv1.ValidatingAdmissionPolicy)
+		Expect(ok).To(BeTrue())
+		Expect(policy.Name).To(Equal(admissionpolicy.PolicyName))
+		Expect(policy.Spec.MatchConstraints.ObjectSelector.MatchExpressions).To(HaveExactElements(metav1.LabelSelectorRequirement{
+			Key:      v1alpha1.LabelAccessTokenName,
+			Operator: metav1.LabelSelectorOpExists,
+		}))
+		Expect(policy.Spec.MatchConstraints.ResourceRules).To(HaveEach(HaveField("Operations", ConsistOf(
+			admissionregistrationv1.Update,
+			admissionregistrationv1.Delete,
+		))))
+		Expect(policy.Spec.Validations).To(HaveExactElements(HaveField("Expression",
+			admissionpolicy.Expression("achilles-system", "achilles-token-controller"))))
+
+		binding, ok := objs[1].(*admissionregistrationv1.ValidatingAdmissionPolicyBinding)
+		Expect(ok).To(BeTrue())
+		Expect(binding.Spec.PolicyName).To(Equal(policy.Name))
+		Expect(binding.Spec.ValidationActions).To(HaveExactElements(admissionregistrationv1.Deny))
+	})
+})
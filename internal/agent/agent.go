@@ -0,0 +1,191 @@
+// Package agent keeps the token of an AccessToken fresh on disk, e.g. in a sidecar, for workloads that read a token or
+// kubeconfig file.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/fileutil"
+	"github.com/reddit/achilles-token-controller/internal/kubeconfig"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TokenFile holds the bearer token.
+	TokenFile = "token"
+
+	// CAFile holds the PEM encoded CA bundle of the API server.
+	CAFile = "ca.crt"
+
+	// KubeconfigFile holds a kubeconfig authenticating with the token.
+	KubeconfigFile = "kubeconfig"
+)
+
+// ErrRevoked is returned once the token has been revoked, i.e. its AccessToken or token Secret was deleted, or its
+// AccessToken stopped being ready.
+var ErrRevoked = errors.New("token has been revoked")
+
+// Options configure the files written by the Agent.
+type Options struct {
+	// Dir is the directory the files are written to.
+	Dir string
+
+	// Server is the URL of the API server written to the kubeconfig.
+	Server string
+
+	// CAData is the PEM encoded CA bundle used if the token Secret doesn't hold one.
+	CAData []byte
+
+	// ContextName is the name of the kubeconfig's cluster, user and context.
+	ContextName string
+}
+
+// Agent writes the token of an AccessToken to files whenever it changes.
+type Agent struct {
+	c    client.Reader
+	key  client.ObjectKey
+	opts Options
+	log  *zap.SugaredLogger
+
+	// token is the token last written, nil until the files have been written
+	token   []byte
+	healthy atomic.Bool
+}
+
+// New returns an Agent for the AccessToken identified by key, reading it and its token Secret with c.
+func New(c client.Reader, key client.ObjectKey, opts Options, log *zap.SugaredLogger) *Agent {
+	return &Agent{
+		c:    c,
+		key:  key,
+		opts: opts,
+		log:  log,
+	}
+}
+
+// Sync writes the files if the token changed since the last call. It returns ErrRevoked once the AccessToken is deleted,
+// or once its token Secret is deleted or the AccessToken isn't ready anymore after a token has been written. Until the
+// AccessToken is ready and the token is issued, Sync returns without writing anything.
+func (a *Agent) Sync(ctx context.Context) error {
+	accessToken := &v1alpha1.AccessToken{}
+	if err := a.c.Get(ctx, a.key, accessToken); err != nil {
+		if kerrors.IsNotFound(err) {
+			return a.revoked(fmt.Errorf("%w: AccessToken %s was deleted", ErrRevoked, a.key))
+		}
+		return fmt.Errorf("getting AccessToken %s: %w", a.key, err)
+	}
+	if accessToken.GetDeletionTimestamp() != nil {
+		return a.revoked(fmt.Errorf("%w: AccessToken %s is being deleted", ErrRevoked, a.key))
+	}
+	if accessToken.Spec.Delivery != nil {
+		return fmt.Errorf("AccessToken %s delivers its token encrypted, there's no token Secret to follow", a.key)
+	}
+	if ready := accessToken.GetCondition(api.TypeReady); ready.Status != corev1.ConditionTrue {
+		// the token's permissions may no longer be what was declared, e.g. after being denied or suspended
+		if a.token != nil {
+			return a.revoked(fmt.Errorf("%w: AccessToken %s is not ready: %s", ErrRevoked, a.key, ready.Message))
+		}
+		a.log.Infow("waiting for the AccessToken to be ready", "accessToken", a.key.String())
+		return nil
+	}
+	if accessToken.Status.TokenSecretRef == nil {
+		a.log.Infow("waiting for the token Secret", "accessToken", a.key.String())
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := client.ObjectKey{Namespace: a.key.Namespace, Name: *accessToken.Status.TokenSecretRef}
+	if err := a.c.Get(ctx, secretKey, secret); err != nil {
+		if kerrors.IsNotFound(err) && a.token != nil {
+			return a.revoked(fmt.Errorf("%w: token Secret %s was deleted", ErrRevoked, secretKey))
+		}
+		if kerrors.IsNotFound(err) {
+			a.log.Infow("waiting for the token Secret", "secret", secretKey.String())
+			return nil
+		}
+		return fmt.Errorf("getting token Secret %s: %w", secretKey, err)
+	}
+	if len(secret.Data[corev1.ServiceAccountTokenKey]) == 0 {
+		a.log.Infow("waiting for the token to be issued", "secret", secretKey.String())
+		return nil
+	}
+
+	token, err := kubeconfig.TokenFromSecret(secret, a.opts.Server, a.opts.CAData)
+	if err != nil {
+		return err
+	}
+	if a.token != nil && bytes.Equal(a.token, []byte(token.Token)) {
+		return nil
+	}
+	if err := a.write(token); err != nil {
+		return err
+	}
+
+	a.token = []byte(token.Token)
+	a.healthy.Store(true)
+	a.log.Infow("wrote token", "accessToken", a.key.String(), "dir", a.opts.Dir)
+	return nil
+}
+
+// revoked removes the written files so that the revoked token can't be used by accident, and returns err.
+func (a *Agent) revoked(err error) error {
+	a.healthy.Store(false)
+	for _, name := range []string{KubeconfigFile, TokenFile, CAFile} {
+		if rmErr := os.Remove(filepath.Join(a.opts.Dir, name)); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+			err = errors.Join(err, rmErr)
+		}
+	}
+	return err
+}
+
+func (a *Agent) write(token kubeconfig.Token) error {
+	token.Namespace = a.key.Namespace
+	cfg, err := clientcmd.Write(*kubeconfig.New(a.opts.ContextName, token))
+	if err != nil {
+		return fmt.Errorf("writing kubeconfig: %w", err)
+	}
+
+	// the kubeconfig embeds the token and CA, so readers of any single file always see a consistent state
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{TokenFile, []byte(token.Token)},
+		{CAFile, token.CAData},
+		{KubeconfigFile, cfg},
+	}
+	for _, f := range files {
+		if err := fileutil.WriteFileAtomic(filepath.Join(a.opts.Dir, f.name), f.data, 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Healthy returns true once the files have been written, until the token is revoked.
+func (a *Agent) Healthy() bool {
+	return a.healthy.Load()
+}
+
+// HealthHandler serves the Agent's health, 200 if Healthy and 503 otherwise.
+func (a *Agent) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if !a.Healthy() {
+			http.Error(w, "token not written", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+}
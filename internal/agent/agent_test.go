@@ -0,0 +1,161 @@
+package agent_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/agent"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Agent", func() {
+	var (
+		ctx         context.Context
+		dir         string
+		c           client.Client
+		accessToken *v1alpha1.AccessToken
+		secret      *corev1.Secret
+		a           *agent.Agent
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dir = GinkgoT().TempDir()
+
+		accessToken = &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "team-a"},
+			Status: v1alpha1.AccessTokenStatus{
+				ConditionedStatus: api.ConditionedStatus{
+					Conditions: []api.Condition{{Type: api.TypeReady, Status: corev1.ConditionTrue}},
+				},
+				TokenSecretRef: ptr.To("deployer"),
+			},
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "team-a"},
+		}
+		c = fake.NewClientBuilder().
+			WithScheme(intscheme.MustNewScheme()).
+			WithObjects(accessToken, secret).
+			Build()
+
+		a = agent.New(c, client.ObjectKeyFromObject(accessToken), agent.Options{
+			Dir:         dir,
+			Server:      "https://api.example.com",
+			ContextName: "deployer",
+		}, zap.NewNop().Sugar())
+	})
+
+	issue := func(token string) {
+		secret.Data = map[string][]byte{
+			corev1.ServiceAccountTokenKey:  []byte(token),
+			corev1.ServiceAccountRootCAKey: []byte("ca"),
+		}
+		Expect(c.Update(ctx, secret)).To(Succeed())
+	}
+
+	readFile := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		Expect(err).ToNot(HaveOccurred())
+		return string(b)
+	}
+
+	It("should wait for the token to be issued", func() {
+		Expect(a.Sync(ctx)).To(Succeed())
+		Expect(a.Healthy()).To(BeFalse())
+		Expect(filepath.Join(dir, agent.TokenFile)).ToNot(BeAnExistingFile())
+	})
+
+	It("should write the token, CA and kubeconfig and rewrite them on rotation", func() {
+		issue("first")
+		Expect(a.Sync(ctx)).To(Succeed())
+		Expect(a.Healthy()).To(BeTrue())
+		Expect(readFile(agent.TokenFile)).To(Equal("first"))
+		Expect(readFile(agent.CAFile)).To(Equal("ca"))
+
+		cfg, err := clientcmd.LoadFromFile(filepath.Join(dir, agent.KubeconfigFile))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.CurrentContext).To(Equal("deployer"))
+		Expect(cfg.Clusters["deployer"].Server).To(Equal("https://api.example.com"))
+		Expect(cfg.AuthInfos["deployer"].Token).To(Equal("first"))
+		Expect(cfg.Contexts["deployer"].Namespace).To(Equal("team-a"))
+
+		info, err := os.Stat(filepath.Join(dir, agent.TokenFile))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+
+		issue("second")
+		Expect(a.Sync(ctx)).To(Succeed())
+		Expect(readFile(agent.TokenFile)).To(Equal("second"))
+
+		entries, err := os.ReadDir(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(3), "no temporary files are left behind")
+	})
+
+	It("should report revocation once the token Secret is deleted", func() {
+		issue("first")
+		Expect(a.Sync(ctx)).To(Succeed())
+
+		Expect(c.Delete(ctx, secret)).To(Succeed())
+		Expect(a.Sync(ctx)).To(MatchError(agent.ErrRevoked))
+		Expect(a.Healthy()).To(BeFalse())
+		Expect(filepath.Join(dir, agent.TokenFile)).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(dir, agent.KubeconfigFile)).ToNot(BeAnExistingFile())
+	})
+
+	It("should report revocation once the AccessToken isn't ready anymore", func() {
+		issue("first")
+		Expect(a.Sync(ctx)).To(Succeed())
+
+		accessToken.SetConditions(api.Condition{Type: api.TypeReady, Status: corev1.ConditionFalse, Message: "denied"})
+		Expect(c.Update(ctx, accessToken)).To(Succeed())
+		err := a.Sync(ctx)
+		Expect(err).To(MatchError(agent.ErrRevoked))
+		Expect(err).To(MatchError(ContainSubstring("denied")))
+		Expect(a.Healthy()).To(BeFalse())
+		Expect(filepath.Join(dir, agent.TokenFile)).ToNot(BeAnExistingFile())
+	})
+
+	It("should wait for the AccessToken to be ready", func() {
+		accessToken.SetConditions(api.Condition{Type: api.TypeReady, Status: corev1.ConditionFalse})
+		Expect(c.Update(ctx, accessToken)).To(Succeed())
+		issue("first")
+
+		Expect(a.Sync(ctx)).To(Succeed())
+		Expect(a.Healthy()).To(BeFalse())
+		Expect(filepath.Join(dir, agent.TokenFile)).ToNot(BeAnExistingFile())
+	})
+
+	It("should report revocation once the AccessToken is deleted", func() {
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+		Expect(a.Sync(ctx)).To(MatchError(agent.ErrRevoked))
+	})
+
+	It("should serve its health", func() {
+		rec := httptest.NewRecorder()
+		a.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		Expect(rec.Code).To(Equal(http.StatusServiceUnavailable))
+
+		issue("first")
+		Expect(a.Sync(ctx)).To(Succeed())
+
+		rec = httptest.NewRecorder()
+		a.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+})
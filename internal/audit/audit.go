@@ -0,0 +1,186 @@
+// Package audit records an append-only, tamper-evident trail of every permission granted or revoked by the controller,
+// and of every token vended to a consumer.
+package audit
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Action describes what happened to the permissions of a subject.
+type Action string
+
+const (
+	// ActionGrant indicates that rules were granted to (or changed for) a subject.
+	ActionGrant Action = "Grant"
+
+	// ActionRevoke indicates that rules were revoked from a subject.
+	ActionRevoke Action = "Revoke"
+
+	// ActionVend indicates that a subject's token was handed out to a consumer by the vending endpoint.
+	ActionVend Action = "Vend"
+)
+
+// Record is a single audit event.
+type Record struct {
+	// Time the record was logged.
+	Time time.Time `json:"time"`
+
+	// Action performed.
+	Action Action `json:"action"`
+
+	// AccessTokenNamespace is the namespace of the AccessToken the permissions belong to.
+	AccessTokenNamespace string `json:"accessTokenNamespace"`
+
+	// AccessTokenName is the name of the AccessToken the permissions belong to.
+	AccessTokenName string `json:"accessTokenName"`
+
+	// Generation is the AccessToken's metadata.generation the change was made for.
+	Generation int64 `json:"generation"`
+
+	// Subject the permissions are bound to.
+	Subject rbacv1.Subject `json:"subject"`
+
+	// Cluster is the name of the target cluster the rules apply in, empty for the controller's own cluster.
+	Cluster string `json:"cluster,omitempty"`
+
+	// Namespace the rules apply to, empty for cluster scoped rules.
+	Namespace string `json:"namespace,omitempty"`
+
+	// RoleKind is the kind of the role holding the rules, "Role" or "ClusterRole".
+	RoleKind string `json:"roleKind"`
+
+	// RoleName is the name of the role holding the rules.
+	RoleName string `json:"roleName"`
+
+	// RulesAdded are the rules the subject gained.
+	RulesAdded []rbacv1.PolicyRule `json:"rulesAdded,omitempty"`
+
+	// RulesRemoved are the rules the subject lost.
+	RulesRemoved []rbacv1.PolicyRule `json:"rulesRemoved,omitempty"`
+
+	// Consumer is the username of the workload the subject's token was vended to.
+	Consumer string `json:"consumer,omitempty"`
+
+	// Sequence is the position of the record in the chain, starting at 1 every time the controller starts.
+	Sequence uint64 `json:"sequence"`
+
+	// PreviousHash is the Hash of the previous record in the chain, empty for the first record.
+	PreviousHash string `json:"previousHash"`
+
+	// Hash is the hex encoded SHA-256 of PreviousHash and the JSON encoding of this record with an empty Hash.
+	// Removing, reordering, or modifying records breaks the chain.
+	Hash string `json:"hash"`
+}
+
+// Sink persists audit records.
+type Sink interface {
+	// Write persists a single record.
+	Write(ctx context.Context, r Record) error
+}
+
+// Logger chains audit records together and fans them out to all configured sinks.
+// A Logger without sinks discards all records.
+type Logger struct {
+	mu       sync.Mutex
+	sinks    []Sink
+	sequence uint64
+	prevHash string
+}
+
+// NewLogger returns a Logger writing to the provided sinks.
+func NewLogger(sinks ...Sink) *Logger {
+	return &Logger{
+		sinks: sinks,
+	}
+}
+
+// Log completes the record's chain fields and writes it to every sink. All sinks are written to even if some fail.
+// The chain is advanced under a lock but sinks are written to outside it, so a slow sink doesn't hold up concurrent
+// callers; records of concurrent calls may reach sinks out of sequence.
+func (l *Logger) Log(ctx context.Context, r Record) error {
+	if len(l.sinks) == 0 {
+		return nil
+	}
+
+	if err := l.chain(&r); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, s := range l.sinks {
+		if err := s.Write(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// chain completes the record's chain fields and advances the chain past it. The chain advances even if a sink later
+// fails to write the record so that sinks which did succeed remain verifiable.
+func (l *Logger) chain(r *Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Time = time.Now().UTC()
+	r.Sequence = l.sequence + 1
+	r.PreviousHash = l.prevHash
+	r.Hash = ""
+
+	hash, err := hashRecord(*r)
+	if err != nil {
+		return err
+	}
+	r.Hash = hash
+
+	l.sequence = r.Sequence
+	l.prevHash = r.Hash
+	return nil
+}
+
+// Verify checks that records form an unbroken chain, as written by a single Logger. Records are put in sequence order
+// first since concurrently logged records may have been written out of order.
+func Verify(records []Record) error {
+	records = slices.Clone(records)
+	slices.SortStableFunc(records, func(a, b Record) int {
+		return cmp.Compare(a.Sequence, b.Sequence)
+	})
+
+	var prevHash string
+	for i, r := range records {
+		if r.PreviousHash != prevHash {
+			return fmt.Errorf("record %d (sequence %d): previous hash %q does not match %q", i, r.Sequence, r.PreviousHash, prevHash)
+		}
+
+		expected := r.Hash
+		r.Hash = ""
+		actual, err := hashRecord(r)
+		if err != nil {
+			return err
+		}
+		if actual != expected {
+			return fmt.Errorf("record %d (sequence %d): hash mismatch", i, r.Sequence)
+		}
+		prevHash = expected
+	}
+	return nil
+}
+
+func hashRecord(r Record) (string, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("marshalling audit record: %w", err)
+	}
+	sum := sha256.Sum256(append([]byte(r.PreviousHash), b...))
+	return hex.EncodeToString(sum[:]), nil
+}
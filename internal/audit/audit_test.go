@@ -0,0 +1,130 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/internal/audit"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+var _ = Describe("Logger", func() {
+	var (
+		buf    *bytes.Buffer
+		logger *audit.Logger
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		logger = audit.NewLogger(audit.NewJSONLinesSink(buf))
+	})
+
+	readRecords := func() []audit.Record {
+		var records []audit.Record
+		dec := json.NewDecoder(buf)
+		for dec.More() {
+			var r audit.Record
+			Expect(dec.Decode(&r)).To(Succeed())
+			records = append(records, r)
+		}
+		return records
+	}
+
+	It("should write a verifiable chain of records", func() {
+		for _, action := range []audit.Action{audit.ActionGrant, audit.ActionGrant, audit.ActionRevoke} {
+			Expect(logger.Log(context.Background(), audit.Record{
+				Action:               action,
+				AccessTokenNamespace: "default",
+				AccessTokenName:      "foobar",
+				RoleKind:             "Role",
+				RoleName:             "foobar",
+				RulesAdded: []rbacv1.PolicyRule{
+					{
+						APIGroups: []string{""},
+						Resources: []string{"configmaps"},
+						Verbs:     []string{"get"},
+					},
+				},
+			})).To(Succeed())
+		}
+
+		records := readRecords()
+		Expect(records).To(HaveLen(3))
+		Expect(records[0].Sequence).To(Equal(uint64(1)))
+		Expect(records[0].PreviousHash).To(BeEmpty())
+		Expect(records[1].PreviousHash).To(Equal(records[0].Hash))
+		Expect(audit.Verify(records)).To(Succeed())
+	})
+
+	It("should detect tampering", func() {
+		for i := 0; i < 3; i++ {
+			Expect(logger.Log(context.Background(), audit.Record{Action: audit.ActionGrant, AccessTokenName: "foobar"})).To(Succeed())
+		}
+
+		By("modifying a record")
+		records := readRecords()
+		records[1].AccessTokenName = "other"
+		Expect(audit.Verify(records)).ToNot(Succeed())
+
+		By("removing a record")
+		records[1].AccessTokenName = "foobar"
+		Expect(audit.Verify(append(records[:1:1], records[2]))).ToNot(Succeed())
+
+		By("renumbering a record")
+		records[2].Sequence = 2
+		Expect(audit.Verify(records)).ToNot(Succeed())
+	})
+
+	It("should verify records written out of sequence", func() {
+		for i := 0; i < 3; i++ {
+			Expect(logger.Log(context.Background(), audit.Record{Action: audit.ActionGrant, AccessTokenName: "foobar"})).To(Succeed())
+		}
+
+		records := readRecords()
+		Expect(audit.Verify([]audit.Record{records[2], records[0], records[1]})).To(Succeed())
+	})
+
+	It("should not hold up other records while a sink is writing", func() {
+		sink := &blockingSink{release: make(chan struct{})}
+		logger = audit.NewLogger(sink)
+
+		done := make(chan error)
+		go func() {
+			done <- logger.Log(context.Background(), audit.Record{Action: audit.ActionGrant, AccessTokenName: "slow"})
+		}()
+		Eventually(sink.blocked.Load).Should(BeTrue())
+
+		Expect(logger.Log(context.Background(), audit.Record{Action: audit.ActionGrant, AccessTokenName: "fast"})).To(Succeed())
+
+		close(sink.release)
+		Expect(<-done).To(Succeed())
+		Expect(sink.records).To(HaveLen(2))
+		Expect(sink.records[0].Sequence).To(Equal(uint64(2)))
+		Expect(audit.Verify(sink.records)).To(Succeed())
+	})
+})
+
+// blockingSink blocks writing records for the "slow" AccessToken until released.
+type blockingSink struct {
+	mu      sync.Mutex
+	blocked atomic.Bool
+	release chan struct{}
+	records []audit.Record
+}
+
+func (s *blockingSink) Write(_ context.Context, r audit.Record) error {
+	if r.AccessTokenName == "slow" {
+		s.blocked.Store(true)
+		<-s.release
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, r)
+	return nil
+}
@@ -0,0 +1,275 @@
+// Package accesssummary maintains an AccessSummary in every namespace targeted by an AccessToken, so that namespace
+// owners can audit inbound access with the RBAC they already have.
+package accesssummary
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/reddit/achilles-sdk/pkg/io"
+	"github.com/reddit/achilles-sdk/pkg/logging"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/controlplane"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// +kubebuilder:rbac:groups=group.example.com,resources=accesssummaries;accesssummaries/status,verbs=*
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings;clusterroles;clusterrolebindings,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+const (
+	controllerName = "AccessSummary"
+)
+
+type reconciler struct {
+	c   *io.ClientApplicator
+	log *zap.SugaredLogger
+}
+
+// Reconcile summarizes the AccessTokens granted access to the namespace of the request.
+func (r *reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	if req.Name != v1alpha1.AccessSummaryName {
+		return reconcile.Result{}, nil
+	}
+
+	grants, err := r.inboundGrants(ctx, req.Namespace)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	summary := &v1alpha1.AccessSummary{}
+	if err := r.c.Get(ctx, req.NamespacedName, summary); err != nil {
+		if !errors.IsNotFound(err) {
+			return reconcile.Result{}, fmt.Errorf("getting %T %s: %w", summary, req.NamespacedName, err)
+		}
+		if len(grants) == 0 {
+			return reconcile.Result{}, nil
+		}
+
+		summary.SetName(req.Name)
+		summary.SetNamespace(req.Namespace)
+		if err := r.c.Create(ctx, summary); err != nil {
+			if errors.HasStatusCause(err, corev1.NamespaceTerminatingCause) {
+				return reconcile.Result{}, nil
+			}
+			return reconcile.Result{}, fmt.Errorf("creating %T %s: %w", summary, req.NamespacedName, err)
+		}
+	}
+
+	// the namespace is no longer targeted by any AccessToken
+	if len(grants) == 0 {
+		if err := r.c.Delete(ctx, summary); client.IgnoreNotFound(err) != nil {
+			return reconcile.Result{}, fmt.Errorf("deleting %T %s: %w", summary, req.NamespacedName, err)
+		}
+		return reconcile.Result{}, nil
+	}
+
+	if equality.Semantic.DeepEqual(summary.Status.Grants, grants) {
+		return reconcile.Result{}, nil
+	}
+
+	summary.Status.Grants = grants
+	if err := r.c.Status().Update(ctx, summary); err != nil {
+		return reconcile.Result{}, fmt.Errorf("updating status of %T %s: %w", summary, req.NamespacedName, err)
+	}
+	r.log.Debugw("updated access summary", "namespace", req.Namespace, "grants", len(grants))
+
+	return reconcile.Result{}, nil
+}
+
+// inboundGrants returns the grants of the RoleBindings managed for AccessTokens in the namespace, and of the
+// ClusterRoleBindings managed for AccessTokens since they apply in every namespace, sorted by AccessToken.
+func (r *reconciler) inboundGrants(ctx context.Context, namespace string) ([]v1alpha1.InboundGrant, error) {
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.c.List(ctx, roleBindings,
+		client.InNamespace(namespace),
+		client.HasLabels{v1alpha1.LabelAccessTokenName},
+	); err != nil {
+		return nil, fmt.Errorf("listing RoleBindings: %w", err)
+	}
+
+	clusterRoleBindings := &rbacv1.ClusterRoleBindingList{}
+	if err := r.c.List(ctx, clusterRoleBindings, client.HasLabels{v1alpha1.LabelAccessTokenName}); err != nil {
+		return nil, fmt.Errorf("listing ClusterRoleBindings: %w", err)
+	}
+
+	var grants []v1alpha1.InboundGrant
+	for _, binding := range roleBindings.Items {
+		grant, ok, err := r.inboundGrant(ctx, &binding, binding.RoleRef, binding.Subjects)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			grants = append(grants, grant)
+		}
+	}
+	for _, binding := range clusterRoleBindings.Items {
+		grant, ok, err := r.inboundGrant(ctx, &binding, binding.RoleRef, binding.Subjects)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			grant.ClusterWide = true
+			grants = append(grants, grant)
+		}
+	}
+
+	slices.SortFunc(grants, func(a, b v1alpha1.InboundGrant) int {
+		return cmp.Or(
+			cmp.Compare(a.AccessTokenNamespace, b.AccessTokenNamespace),
+			cmp.Compare(a.AccessTokenName, b.AccessTokenName),
+			compareBool(a.ClusterWide, b.ClusterWide),
+		)
+	})
+
+	return grants, nil
+}
+
+// inboundGrant returns the grant of a binding managed for an AccessToken, holding the rules of the Role or ClusterRole
+// it references. No grant is returned if the binding is being deleted or its role doesn't exist.
+func (r *reconciler) inboundGrant(
+	ctx context.Context,
+	binding client.Object,
+	roleRef rbacv1.RoleRef,
+	subjects []rbacv1.Subject,
+) (v1alpha1.InboundGrant, bool, error) {
+	if binding.GetDeletionTimestamp() != nil {
+		return v1alpha1.InboundGrant{}, false, nil
+	}
+
+	var rules []rbacv1.PolicyRule
+	switch roleRef.Kind {
+	case "Role":
+		role := &rbacv1.Role{}
+		key := client.ObjectKey{Namespace: binding.GetNamespace(), Name: roleRef.Name}
+		if err := r.c.Get(ctx, key, role); err != nil {
+			if errors.IsNotFound(err) {
+				return v1alpha1.InboundGrant{}, false, nil
+			}
+			return v1alpha1.InboundGrant{}, false, fmt.Errorf("getting %T %s: %w", role, key, err)
+		}
+		rules = role.Rules
+	case "ClusterRole":
+		clusterRole := &rbacv1.ClusterRole{}
+		key := client.ObjectKey{Name: roleRef.Name}
+		if err := r.c.Get(ctx, key, clusterRole); err != nil {
+			if errors.IsNotFound(err) {
+				return v1alpha1.InboundGrant{}, false, nil
+			}
+			return v1alpha1.InboundGrant{}, false, fmt.Errorf("getting %T %s: %w", clusterRole, key, err)
+		}
+		rules = clusterRole.Rules
+	default:
+		return v1alpha1.InboundGrant{}, false, nil
+	}
+
+	grant := v1alpha1.InboundGrant{
+		AccessTokenNamespace: binding.GetLabels()[v1alpha1.LabelAccessTokenNamespace],
+		AccessTokenName:      binding.GetLabels()[v1alpha1.LabelAccessTokenName],
+		Rules:                rules,
+	}
+	for _, subject := range subjects {
+		if subject.Kind == rbacv1.ServiceAccountKind {
+			grant.ServiceAccount = client.ObjectKey{Namespace: subject.Namespace, Name: subject.Name}.String()
+			break
+		}
+	}
+	return grant, true, nil
+}
+
+// compareBool orders false before true.
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// summaryFor maps an object to the AccessSummary of its namespace.
+func summaryFor(_ context.Context, o client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{
+		Namespace: o.GetNamespace(),
+		Name:      v1alpha1.AccessSummaryName,
+	}}}
+}
+
+// namespaceSummary maps a Namespace to its AccessSummary, new namespaces are subject to cluster-wide grants.
+func namespaceSummary(_ context.Context, o client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{
+		Namespace: o.GetName(),
+		Name:      v1alpha1.AccessSummaryName,
+	}}}
+}
+
+// allSummaries maps an object to the AccessSummary of every namespace, since cluster-scoped roles and bindings may grant
+// access to any of them.
+func (r *reconciler) allSummaries(ctx context.Context, _ client.Object) []reconcile.Request {
+	namespaces := &corev1.NamespaceList{}
+	if err := r.c.List(ctx, namespaces); err != nil {
+		r.log.Errorf("listing Namespaces: %s", err)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		requests = append(requests, namespaceSummary(ctx, &ns)...)
+	}
+	return requests
+}
+
+func SetupController(
+	ctx context.Context,
+	_ controlplane.Context,
+	mgr ctrl.Manager,
+	rl workqueue.TypedRateLimiter[reconcile.Request],
+	c *io.ClientApplicator,
+) error {
+	_, log, err := logging.ControllerCtx(ctx, controllerName)
+	if err != nil {
+		return err
+	}
+
+	r := &reconciler{
+		c:   c,
+		log: log,
+	}
+
+	managed := builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+		_, ok := o.GetLabels()[v1alpha1.LabelAccessTokenName]
+		return ok
+	}))
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(&v1alpha1.AccessSummary{}).
+		Watches(&rbacv1.RoleBinding{}, handler.EnqueueRequestsFromMapFunc(summaryFor), managed).
+		Watches(&rbacv1.Role{}, handler.EnqueueRequestsFromMapFunc(summaryFor), managed).
+		Watches(&rbacv1.ClusterRoleBinding{}, handler.EnqueueRequestsFromMapFunc(r.allSummaries), managed).
+		Watches(&rbacv1.ClusterRole{}, handler.EnqueueRequestsFromMapFunc(r.allSummaries), managed).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(namespaceSummary),
+			builder.WithPredicates(predicate.Funcs{
+				UpdateFunc:  func(event.UpdateEvent) bool { return false },
+				DeleteFunc:  func(event.DeleteEvent) bool { return false },
+				GenericFunc: func(event.GenericEvent) bool { return false },
+			})).
+		WithOptions(controller.Options{RateLimiter: rl}).
+		Complete(r)
+}
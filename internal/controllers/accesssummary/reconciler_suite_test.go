@@ -0,0 +1,80 @@
+package accesssummary_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fgrosse/zaptest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-sdk/pkg/io"
+	"github.com/reddit/achilles-sdk/pkg/logging"
+	achratelimiter "github.com/reddit/achilles-sdk/pkg/ratelimiter"
+	sdktest "github.com/reddit/achilles-sdk/pkg/test"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesssummary"
+	"github.com/reddit/achilles-token-controller/internal/controlplane"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/reddit/achilles-token-controller/internal/test"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	ctrlzap "sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var (
+	ctx     context.Context
+	testEnv *sdktest.TestEnv
+	c       client.Client
+	scheme  *runtime.Scheme
+	log     *zap.SugaredLogger
+)
+
+func TestAccessSummary(t *testing.T) {
+	RegisterFailHandler(Fail)
+	ctrllog.SetLogger(ctrlzap.New(ctrlzap.WriteTo(GinkgoWriter), ctrlzap.UseDevMode(true)))
+	RunSpecs(t, "AccessSummary Suite")
+}
+
+var _ = BeforeSuite(func() {
+	SetDefaultEventuallyTimeout(15 * time.Second)
+	SetDefaultEventuallyPollingInterval(200 * time.Millisecond)
+
+	log = zaptest.LoggerWriter(GinkgoWriter).Sugar()
+	ctx = logging.NewContext(context.Background(), log)
+	rl := achratelimiter.NewDefaultProviderRateLimiter(achratelimiter.DefaultProviderRPS)
+
+	scheme = intscheme.MustNewScheme()
+
+	var err error
+	testEnv, err = sdktest.NewEnvTestBuilder(ctx).
+		WithCRDDirectoryPaths(
+			test.CRDPaths(),
+		).
+		WithScheme(scheme).
+		WithLog(log.Desugar()).
+		WithManagerSetupFns(
+			func(mgr manager.Manager) error {
+				clientApplicator := &io.ClientApplicator{
+					Client:     mgr.GetClient(),
+					Applicator: io.NewAPIPatchingApplicator(mgr.GetClient()),
+				}
+
+				return accesssummary.SetupController(ctx, controlplane.Context{}, mgr, rl, clientApplicator)
+			},
+		).
+		WithKubeConfigFile("./").
+		Start()
+
+	Expect(err).ToNot(HaveOccurred())
+
+	c = testEnv.Client
+})
+
+var _ = AfterSuite(func() {
+	By("tearing down the test environment")
+	err := testEnv.Stop()
+	Expect(err).NotTo(HaveOccurred())
+})
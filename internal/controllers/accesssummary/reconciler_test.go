@@ -0,0 +1,152 @@
+package accesssummary_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("AccessSummary", func() {
+	It("should summarize the AccessTokens granted access to a namespace", func() {
+		rules := []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{""},
+				Resources: []string{"configmaps"},
+				Verbs:     []string{"get", "list"},
+			},
+		}
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "foobar",
+				Namespace: "kube-public",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+					{Namespace: "default", Rules: rules},
+				},
+			},
+		}
+
+		// the objects the AccessToken controller provisions in the target namespace
+		var provisioned []client.Object
+		for _, o := range accesstoken.Render(accessToken) {
+			if o.GetNamespace() == "default" {
+				provisioned = append(provisioned, o)
+				Expect(c.Create(ctx, o)).To(Succeed())
+			}
+		}
+		Expect(provisioned).To(HaveLen(2))
+
+		summaryKey := client.ObjectKey{Namespace: "default", Name: v1alpha1.AccessSummaryName}
+
+		Eventually(func(g Gomega) {
+			summary := &v1alpha1.AccessSummary{}
+			g.Expect(c.Get(ctx, summaryKey, summary)).To(Succeed())
+			g.Expect(summary.Status.Grants).To(Equal([]v1alpha1.InboundGrant{
+				{
+					AccessTokenNamespace: "kube-public",
+					AccessTokenName:      "foobar",
+					ServiceAccount:       "kube-public/foobar",
+					Rules:                rules,
+				},
+			}))
+		}).Should(Succeed())
+
+		By("deleting the summary once no AccessToken targets the namespace")
+
+		for _, o := range provisioned {
+			Expect(c.Delete(ctx, o)).To(Succeed())
+		}
+
+		Eventually(func(g Gomega) {
+			err := c.Get(ctx, summaryKey, &v1alpha1.AccessSummary{})
+			g.Expect(errors.IsNotFound(err)).To(BeTrue())
+		}).Should(Succeed())
+	})
+
+	It("should list cluster-wide grants in every namespace", func() {
+		rules := []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{""},
+				Resources: []string{"pods"},
+				Verbs:     []string{"list"},
+			},
+		}
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "lister",
+				Namespace: "kube-public",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				ClusterPermissions: &v1alpha1.ClusterPermissions{Rules: rules},
+			},
+		}
+
+		// the cluster-scoped objects the AccessToken controller provisions
+		var provisioned []client.Object
+		for _, o := range accesstoken.Render(accessToken) {
+			switch o.(type) {
+			case *rbacv1.ClusterRole, *rbacv1.ClusterRoleBinding:
+				provisioned = append(provisioned, o)
+				Expect(c.Create(ctx, o)).To(Succeed())
+			}
+		}
+		Expect(provisioned).To(HaveLen(2))
+
+		for _, ns := range []string{"default", "kube-system"} {
+			Eventually(func(g Gomega) {
+				summary := &v1alpha1.AccessSummary{}
+				g.Expect(c.Get(ctx, client.ObjectKey{Namespace: ns, Name: v1alpha1.AccessSummaryName}, summary)).To(Succeed())
+				g.Expect(summary.Status.Grants).To(Equal([]v1alpha1.InboundGrant{
+					{
+						AccessTokenNamespace: "kube-public",
+						AccessTokenName:      "lister",
+						ServiceAccount:       "kube-public/lister",
+						Rules:                rules,
+						ClusterWide:          true,
+					},
+				}))
+			}).Should(Succeed())
+		}
+
+		By("summarizing RoleBindings referencing the ClusterRole in their namespace only")
+		binding := &rbacv1.RoleBinding{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "lister",
+				Namespace: "default",
+				Labels:    provisioned[1].GetLabels(),
+			},
+			RoleRef:  provisioned[1].(*rbacv1.ClusterRoleBinding).RoleRef,
+			Subjects: provisioned[1].(*rbacv1.ClusterRoleBinding).Subjects,
+		}
+		Expect(c.Create(ctx, binding)).To(Succeed())
+		Eventually(func(g Gomega) {
+			summary := &v1alpha1.AccessSummary{}
+			g.Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: v1alpha1.AccessSummaryName}, summary)).To(Succeed())
+			g.Expect(summary.Status.Grants).To(HaveLen(2))
+			g.Expect(summary.Status.Grants[0].ClusterWide).To(BeFalse())
+			g.Expect(summary.Status.Grants[0].Rules).To(Equal(rules))
+		}).Should(Succeed())
+		Consistently(func(g Gomega) {
+			summary := &v1alpha1.AccessSummary{}
+			g.Expect(c.Get(ctx, client.ObjectKey{Namespace: "kube-system", Name: v1alpha1.AccessSummaryName}, summary)).To(Succeed())
+			g.Expect(summary.Status.Grants).To(HaveLen(1))
+		}, "1s").Should(Succeed())
+
+		By("deleting the summaries once the cluster-wide grant is gone")
+		for _, o := range append(provisioned, binding) {
+			Expect(c.Delete(ctx, o)).To(Succeed())
+		}
+		for _, ns := range []string{"default", "kube-system"} {
+			Eventually(func(g Gomega) {
+				err := c.Get(ctx, client.ObjectKey{Namespace: ns, Name: v1alpha1.AccessSummaryName}, &v1alpha1.AccessSummary{})
+				g.Expect(errors.IsNotFound(err)).To(BeTrue())
+			}).Should(Succeed())
+		}
+	})
+})
@@ -0,0 +1,124 @@
+package accesstoken
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/reddit/achilles-sdk/pkg/fsm/types"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/accessreview"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// evaluateAssertions evaluates the AccessToken's assertions. Deny assertions without a namespace are checked in every
+// namespace the AccessToken, given with references resolved, is granted access to.
+func (r *reconciler) evaluateAssertions(resolved *v1alpha1.AccessToken) *state {
+	return &state{
+		Name:      "evaluate-assertions",
+		Condition: conditionAssertionsSatisfied,
+		Transition: func(
+			ctx context.Context,
+			accessToken *v1alpha1.AccessToken,
+			out *types.OutputSet,
+		) (*state, types.Result) {
+			accessToken.Status.AssertionResults = nil
+			if accessToken.Spec.Assertions == nil {
+				return nil, types.DoneResult()
+			}
+
+			sa := newBuilder(accessToken).serviceAccount()
+			user := accessreview.ServiceAccountUser{Namespace: sa.GetNamespace(), Name: sa.GetName()}
+
+			var results []v1alpha1.AssertionResult
+			for _, assertion := range accessToken.Spec.Assertions.Allow {
+				result, err := r.evaluateAssertion(ctx, user, resolved, assertion, v1alpha1.AssertionExpectationAllow)
+				if err != nil {
+					return nil, types.ErrorResult(err)
+				}
+				results = append(results, result)
+			}
+			for _, assertion := range accessToken.Spec.Assertions.Deny {
+				result, err := r.evaluateAssertion(ctx, user, resolved, assertion, v1alpha1.AssertionExpectationDeny)
+				if err != nil {
+					return nil, types.ErrorResult(err)
+				}
+				results = append(results, result)
+			}
+			accessToken.Status.AssertionResults = results
+
+			var unsatisfied []string
+			for _, result := range results {
+				if !result.Satisfied {
+					unsatisfied = append(unsatisfied, result.Message)
+				}
+			}
+			if len(unsatisfied) > 0 {
+				return nil, types.ErrorResultf("access assertions not satisfied (see `status.assertionResults`): %s", strings.Join(unsatisfied, "; "))
+			}
+
+			return nil, types.DoneResult()
+		},
+	}
+}
+
+func (r *reconciler) evaluateAssertion(
+	ctx context.Context,
+	user accessreview.ServiceAccountUser,
+	resolved *v1alpha1.AccessToken,
+	assertion v1alpha1.AccessAssertion,
+	expectation v1alpha1.AssertionExpectation,
+) (v1alpha1.AssertionResult, error) {
+	namespaces := []string{assertion.Namespace}
+	if assertion.Namespace == "" && expectation == v1alpha1.AssertionExpectationDeny {
+		// a request denied cluster-wide may still be allowed within a single namespace, so check every namespace the
+		// token is granted access to
+		for _, p := range resolved.Spec.NamespacedPermissions {
+			namespaces = append(namespaces, p.Namespace)
+		}
+	}
+
+	var checks []accessreview.Check
+	seen := map[string]bool{}
+	for _, ns := range namespaces {
+		if seen[ns] {
+			continue
+		}
+		seen[ns] = true
+
+		resource, subresource, _ := strings.Cut(assertion.Resource, "/")
+		checks = append(checks, accessreview.Check{ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace:   ns,
+			Verb:        assertion.Verb,
+			Group:       assertion.APIGroup,
+			Resource:    resource,
+			Subresource: subresource,
+		}})
+	}
+
+	reviews, err := r.reviewer.Review(ctx, user, checks)
+	if err != nil {
+		return v1alpha1.AssertionResult{}, fmt.Errorf("evaluating %s assertion: %w", expectation, err)
+	}
+
+	result := v1alpha1.AssertionResult{
+		AccessAssertion: assertion,
+		Expectation:     expectation,
+		Satisfied:       true,
+	}
+	for _, review := range reviews {
+		switch {
+		case expectation == v1alpha1.AssertionExpectationAllow && !review.Allowed:
+			result.Satisfied = false
+			result.Message = fmt.Sprintf("expected to be allowed to %s", review.Check)
+		case expectation == v1alpha1.AssertionExpectationDeny && review.Allowed:
+			result.Satisfied = false
+			result.Message = fmt.Sprintf("expected to be denied to %s", review.Check)
+		}
+		if !result.Satisfied {
+			break
+		}
+	}
+
+	return result, nil
+}
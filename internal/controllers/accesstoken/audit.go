@@ -0,0 +1,167 @@
+package accesstoken
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reddit/achilles-sdk/pkg/fsm/types"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/audit"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// grantRecords returns the audit records of the difference between the rules of the desired roles and the roles
+// currently in the cluster read with c, named cluster or empty for the controller's own cluster. Roles whose rules are
+// unchanged aren't recorded. The records are computed before the roles are applied and logged with logGrants once they
+// have been.
+func grantRecords(
+	ctx context.Context,
+	c client.Reader,
+	cluster string,
+	accessToken *v1alpha1.AccessToken,
+	desiredObjs []client.Object,
+) ([]audit.Record, error) {
+	var records []audit.Record
+	for _, o := range desiredObjs {
+		var desiredRules []rbacv1.PolicyRule
+		var actual client.Object
+
+		switch role := o.(type) {
+		case *rbacv1.Role:
+			desiredRules = role.Rules
+			actual = &rbacv1.Role{}
+		case *rbacv1.ClusterRole:
+			desiredRules = role.Rules
+			actual = &rbacv1.ClusterRole{}
+		default:
+			continue
+		}
+
+		var actualRules []rbacv1.PolicyRule
+		if err := c.Get(ctx, client.ObjectKeyFromObject(o), actual); err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, fmt.Errorf("getting %T %s: %w", o, client.ObjectKeyFromObject(o), err)
+			}
+		} else {
+			actualRules = rulesOf(actual)
+		}
+
+		added := subtractRules(desiredRules, actualRules)
+		removed := subtractRules(actualRules, desiredRules)
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		records = append(records, auditRecord(accessToken, cluster, audit.ActionGrant, o, added, removed))
+	}
+
+	return records, nil
+}
+
+// logGrants logs grant records computed by grantRecords.
+func (r *reconciler) logGrants(ctx context.Context, records []audit.Record) error {
+	for _, record := range records {
+		if err := r.auditor.Log(ctx, record); err != nil {
+			return fmt.Errorf("auditing grant for %s %s/%s: %w", record.RoleKind, record.Namespace, record.RoleName, err)
+		}
+	}
+	return nil
+}
+
+// auditGrants logs grant records computed by grantRecords before the roles were applied. It follows the state applying
+// them, so nothing is recorded as granted unless the roles were applied successfully.
+func (r *reconciler) auditGrants(records []audit.Record, nextState *state) *state {
+	return &state{
+		Name: "audit-grants",
+		Transition: func(
+			ctx context.Context,
+			accessToken *v1alpha1.AccessToken,
+			out *types.OutputSet,
+		) (*state, types.Result) {
+			if err := r.logGrants(ctx, records); err != nil {
+				return nil, types.ErrorResult(err)
+			}
+			return nextState, types.DoneResult()
+		},
+	}
+}
+
+// auditRevocation records the removal of all rules held by a stale role in the named cluster, empty for the controller's
+// own cluster. Objects other than roles aren't recorded.
+func (r *reconciler) auditRevocation(ctx context.Context, cluster string, accessToken *v1alpha1.AccessToken, staleObj client.Object) error {
+	switch staleObj.(type) {
+	case *rbacv1.Role, *rbacv1.ClusterRole:
+	default:
+		return nil
+	}
+
+	if err := r.auditor.Log(ctx, auditRecord(accessToken, cluster, audit.ActionRevoke, staleObj, nil, rulesOf(staleObj))); err != nil {
+		return fmt.Errorf("auditing revocation for %T %s: %w", staleObj, client.ObjectKeyFromObject(staleObj), err)
+	}
+	return nil
+}
+
+func auditRecord(
+	accessToken *v1alpha1.AccessToken,
+	cluster string,
+	action audit.Action,
+	role client.Object,
+	added, removed []rbacv1.PolicyRule,
+) audit.Record {
+	roleKind := "Role"
+	if _, ok := role.(*rbacv1.ClusterRole); ok {
+		roleKind = "ClusterRole"
+	}
+
+	sa := newBuilder(accessToken).serviceAccount()
+
+	return audit.Record{
+		Action:               action,
+		AccessTokenNamespace: accessToken.GetNamespace(),
+		AccessTokenName:      accessToken.GetName(),
+		Generation:           accessToken.GetGeneration(),
+		Subject: rbacv1.Subject{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      sa.GetName(),
+			Namespace: sa.GetNamespace(),
+		},
+		Cluster:      cluster,
+		Namespace:    role.GetNamespace(),
+		RoleKind:     roleKind,
+		RoleName:     role.GetName(),
+		RulesAdded:   added,
+		RulesRemoved: removed,
+	}
+}
+
+func rulesOf(o client.Object) []rbacv1.PolicyRule {
+	switch role := o.(type) {
+	case *rbacv1.Role:
+		return role.Rules
+	case *rbacv1.ClusterRole:
+		return role.Rules
+	default:
+		return nil
+	}
+}
+
+// subtractRules returns the rules in a that are not in b.
+func subtractRules(a, b []rbacv1.PolicyRule) []rbacv1.PolicyRule {
+	var out []rbacv1.PolicyRule
+	for _, ruleA := range a {
+		found := false
+		for _, ruleB := range b {
+			if equality.Semantic.DeepEqual(ruleA, ruleB) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, ruleA)
+		}
+	}
+	return out
+}
@@ -2,8 +2,10 @@ package accesstoken
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/denylist"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -12,6 +14,12 @@ import (
 
 type builder struct {
 	accessToken *v1alpha1.AccessToken
+
+	// skippedNamespaces are namespaces no Roles or RoleBindings are built in, e.g. because they haven't consented
+	skippedNamespaces []string
+
+	// denyList refuses namespaces and rules no AccessToken may be granted
+	denyList *denylist.DenyList
 }
 
 func newBuilder(
@@ -22,10 +30,30 @@ func newBuilder(
 	}
 }
 
+// withoutNamespaces skips the NamespacedPermissions targeting the namespaces.
+func (b *builder) withoutNamespaces(namespaces ...string) *builder {
+	b.skippedNamespaces = append(b.skippedNamespaces, namespaces...)
+	return b
+}
+
+// withDenyList refuses the namespaces and rules denied by the DenyList.
+func (b *builder) withDenyList(d *denylist.DenyList) *builder {
+	b.denyList = d
+	return b
+}
+
+// Render returns the objects provisioned for the AccessToken. It doesn't require access to a cluster.
+func Render(accessToken *v1alpha1.AccessToken) []client.Object {
+	return newBuilder(accessToken).build()
+}
+
 func (b *builder) build() []client.Object {
 	resources := []client.Object{
 		b.serviceAccount(),
-		b.secret(),
+	}
+	// delivered tokens are issued through the TokenRequest API and never stored in plaintext
+	if b.accessToken.Spec.Delivery == nil {
+		resources = append(resources, b.secret())
 	}
 
 	resources = append(resources, b.roleAndBindings()...)
@@ -34,11 +62,20 @@ func (b *builder) build() []client.Object {
 	return resources
 }
 
+// labels returns the labels identifying objects managed on behalf of the AccessToken.
+func (b *builder) labels() map[string]string {
+	return map[string]string{
+		v1alpha1.LabelAccessTokenName:      b.accessToken.GetName(),
+		v1alpha1.LabelAccessTokenNamespace: b.accessToken.GetNamespace(),
+	}
+}
+
 func (b *builder) serviceAccount() *corev1.ServiceAccount {
 	return &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      b.accessToken.GetName(),
 			Namespace: b.accessToken.GetNamespace(),
+			Labels:    b.labels(),
 		},
 	}
 }
@@ -50,6 +87,7 @@ func (b *builder) secret() *corev1.Secret {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      b.accessToken.Name,
 			Namespace: b.accessToken.Namespace,
+			Labels:    b.labels(),
 			Annotations: map[string]string{
 				"kubernetes.io/service-account.name": sa.GetName(),
 			},
@@ -62,7 +100,16 @@ func (b *builder) roleAndBindings() []client.Object {
 	var objs []client.Object
 
 	for _, namespacedRole := range b.accessToken.Spec.NamespacedPermissions {
-		role := b.role(b.accessToken, namespacedRole.Namespace, namespacedRole.Rules)
+		if slices.Contains(b.skippedNamespaces, namespacedRole.Namespace) || b.denyList.DeniesNamespace(namespacedRole.Namespace) {
+			continue
+		}
+		rules := b.denyList.AllowedRules(namespacedRole.Rules)
+		if len(rules) == 0 && len(namespacedRole.Rules) > 0 {
+			// every rule is denied
+			continue
+		}
+
+		role := b.role(b.accessToken, namespacedRole.Namespace, rules)
 		objs = append(objs, role)
 
 		roleRef := rbacv1.RoleRef{
@@ -82,6 +129,7 @@ func (b *builder) role(accessToken *v1alpha1.AccessToken, ns string, rules []rba
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      accessToken.GetName(),
 			Namespace: ns,
+			Labels:    b.labels(),
 		},
 		Rules: rules,
 	}
@@ -93,6 +141,7 @@ func (b *builder) roleBinding(roleRef rbacv1.RoleRef, ns string) *rbacv1.RoleBin
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      b.accessToken.GetName(),
 			Namespace: ns,
+			Labels:    b.labels(),
 		},
 		RoleRef: roleRef,
 		Subjects: []rbacv1.Subject{
@@ -111,7 +160,13 @@ func (b *builder) clusterRoleAndBinding() []client.Object {
 		return nil
 	}
 
-	clusterRole := b.clusterRole(b.accessToken.Spec.ClusterPermissions.Rules)
+	rules := b.denyList.AllowedClusterRules(b.accessToken.Spec.ClusterPermissions.Rules)
+	if len(rules) == 0 && len(b.accessToken.Spec.ClusterPermissions.Rules) > 0 {
+		// every rule is denied
+		return nil
+	}
+
+	clusterRole := b.clusterRole(rules)
 	objs = append(objs, clusterRole)
 
 	roleRef := rbacv1.RoleRef{
@@ -128,7 +183,8 @@ func (b *builder) clusterRole(rules []rbacv1.PolicyRule) *rbacv1.ClusterRole {
 	return &rbacv1.ClusterRole{
 		ObjectMeta: metav1.ObjectMeta{
 			// NOTE: ClusterRoles are cluster-scoped objects so we qualify the name with the namespace to avoid colliding names
-			Name: fmt.Sprintf("%s-%s", b.accessToken.GetName(), b.accessToken.GetNamespace()),
+			Name:   fmt.Sprintf("%s-%s", b.accessToken.GetName(), b.accessToken.GetNamespace()),
+			Labels: b.labels(),
 		},
 		Rules: rules,
 	}
@@ -139,7 +195,8 @@ func (b *builder) clusterRoleBinding(roleRef rbacv1.RoleRef) *rbacv1.ClusterRole
 	return &rbacv1.ClusterRoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
 			// NOTE: ClusterRoles are cluster-scoped objects so we qualify the name with the namespace to avoid colliding names
-			Name: fmt.Sprintf("%s-%s", b.accessToken.GetName(), b.accessToken.GetNamespace()),
+			Name:   fmt.Sprintf("%s-%s", b.accessToken.GetName(), b.accessToken.GetNamespace()),
+			Labels: b.labels(),
 		},
 		RoleRef: roleRef,
 		Subjects: []rbacv1.Subject{
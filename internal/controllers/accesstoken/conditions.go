@@ -17,3 +17,21 @@ var conditionStalePermissionsRemoved = api.Condition{
 	Status:  corev1.ConditionTrue,
 	Message: "Stale permissions have been removed",
 }
+
+var conditionRemoteTokensProvisioned = api.Condition{
+	Type:    v1alpha1.TypeRemoteTokensProvisioned,
+	Status:  corev1.ConditionTrue,
+	Message: "Access token has been provisioned in all target clusters (see `status.clusters`)",
+}
+
+var conditionTokenDelivered = api.Condition{
+	Type:    v1alpha1.TypeTokenDelivered,
+	Status:  corev1.ConditionTrue,
+	Message: "Access token has been encrypted to `spec.delivery.publicKey` (see `status.encryptedToken`)",
+}
+
+var conditionAssertionsSatisfied = api.Condition{
+	Type:    v1alpha1.TypeAssertionsSatisfied,
+	Status:  corev1.ConditionTrue,
+	Message: "All access assertions are satisfied (see `status.assertionResults`)",
+}
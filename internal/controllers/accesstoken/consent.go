@@ -0,0 +1,209 @@
+package accesstoken
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/tokentemplate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// indexTargetNamespace indexes AccessTokens by the namespaces targeted by their NamespacedPermissions, excluding those
+// of their template, see templatedAccessTokensTargeting.
+const indexTargetNamespace = "accesstoken.spec.namespacedPermissions.namespace"
+
+// allNamespaces consents to AccessTokens from any namespace.
+const allNamespaces = "*"
+
+// checkConsent returns the namespaces targeted by the NamespacedPermissions of the AccessToken, given by spec with
+// references resolved, that haven't consented to grants from the AccessToken's namespace, and reports them through the
+// NamespacesConsented condition. Entries targeting these namespaces are skipped rather than failing the whole token. If
+// consent isn't required, they're only reported, so that consent can be set up before it's enforced.
+func (r *reconciler) checkConsent(ctx context.Context, accessToken *v1alpha1.AccessToken, spec v1alpha1.AccessTokenSpec) ([]string, error) {
+	condition := api.Condition{
+		Type:               v1alpha1.TypeNamespacesConsented,
+		Status:             corev1.ConditionTrue,
+		Reason:             v1alpha1.ReasonConsentGranted,
+		Message:            "All targeted namespaces consent to the token's grants",
+		ObservedGeneration: accessToken.GetGeneration(),
+		LastTransitionTime: metav1.Now(),
+	}
+
+	var missing []string
+	for _, p := range spec.NamespacedPermissions {
+		if slices.Contains(missing, p.Namespace) {
+			continue
+		}
+		ok, err := consents(ctx, r.c, p.Namespace, accessToken.GetNamespace())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			missing = append(missing, p.Namespace)
+		}
+	}
+
+	switch {
+	case len(missing) > 0 && r.requireConsent:
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = v1alpha1.ReasonConsentMissing
+		condition.Message = fmt.Sprintf(
+			"Permissions in namespaces %s are not granted, they must consent through an AccessTokenGrant, the %s<namespace> label or the %s annotation",
+			strings.Join(missing, ", "), v1alpha1.LabelPrefixAllowedSourceNamespace, v1alpha1.AnnotationAllowedSourceNamespaces)
+	case len(missing) > 0:
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = v1alpha1.ReasonConsentMissing
+		condition.Message = fmt.Sprintf(
+			"Namespaces %s don't consent, their permissions are granted since namespace consent is not required yet",
+			strings.Join(missing, ", "))
+		missing = nil
+	}
+	accessToken.SetConditions(condition)
+
+	return missing, nil
+}
+
+// consents returns true if the target namespace consents to grants from AccessTokens in the source namespace. A namespace
+// always consents to AccessTokens in itself, other namespaces consent through a LabelPrefixAllowedSourceNamespace label,
+// the AnnotationAllowedSourceNamespaces annotation or an AccessTokenGrant. Namespaces that don't exist never consent.
+func consents(ctx context.Context, c client.Reader, target, source string) (bool, error) {
+	if target == source {
+		return true, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: target}, ns); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting %T %s: %w", ns, target, err)
+	}
+	if ns.GetLabels()[v1alpha1.LabelPrefixAllowedSourceNamespace+source] == "true" {
+		return true, nil
+	}
+	if annotationAllows(ns, v1alpha1.AnnotationAllowedSourceNamespaces, source) {
+		return true, nil
+	}
+
+	grants := &v1alpha1.AccessTokenGrantList{}
+	if err := c.List(ctx, grants, client.InNamespace(target)); err != nil {
+		// target clusters may not have the AccessTokenGrant CRD installed, their namespaces consent through annotations
+		if apimeta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("listing %T in namespace %s: %w", grants, target, err)
+	}
+	for _, grant := range grants.Items {
+		for _, from := range grant.Spec.From {
+			if from.Namespace == source || from.Namespace == allNamespaces {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// annotationAllows returns true if the annotation of the Namespace, a comma separated list of namespaces, lists the
+// source namespace or allNamespaces.
+func annotationAllows(ns *corev1.Namespace, annotation, source string) bool {
+	for _, n := range strings.Split(ns.GetAnnotations()[annotation], ",") {
+		if n = strings.TrimSpace(n); n == source || n == allNamespaces {
+			return true
+		}
+	}
+	return false
+}
+
+// targetNamespaces is the index function of indexTargetNamespace.
+func targetNamespaces(o client.Object) []string {
+	accessToken, ok := o.(*v1alpha1.AccessToken)
+	if !ok {
+		return nil
+	}
+	var namespaces []string
+	for _, p := range accessToken.Spec.NamespacedPermissions {
+		if !slices.Contains(namespaces, p.Namespace) {
+			namespaces = append(namespaces, p.Namespace)
+		}
+	}
+	return namespaces
+}
+
+// templatedAccessTokensTargeting returns a map function enqueuing the AccessTokens whose template, once expanded,
+// targets the namespace returned by valueOf. Template-derived namespaces aren't indexed by indexTargetNamespace since
+// index functions must only depend on the indexed object.
+func (r *reconciler) templatedAccessTokensTargeting(valueOf func(client.Object) string) handler.MapFunc {
+	return func(ctx context.Context, o client.Object) []reconcile.Request {
+		namespace := valueOf(o)
+
+		templates := &v1alpha1.AccessTokenTemplateList{}
+		if err := r.c.List(ctx, templates); err != nil {
+			r.log.Errorf("listing AccessTokenTemplates: %s", err)
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, tmpl := range templates.Items {
+			accessTokens := &v1alpha1.AccessTokenList{}
+			if err := r.c.List(ctx, accessTokens, client.MatchingFields{indexTemplateRef: tmpl.GetName()}); err != nil {
+				r.log.Errorf("listing AccessTokens indexed by %s under %s: %s", indexTemplateRef, tmpl.GetName(), err)
+				continue
+			}
+			for _, at := range accessTokens.Items {
+				// a template that can't be expanded fails the token, it doesn't grant anything until it's fixed
+				expanded, err := tokentemplate.Expand(tmpl.Spec, at.GetNamespace(), at.GetName(), at.Spec.TemplateRef.Parameters)
+				if err != nil {
+					continue
+				}
+				if slices.ContainsFunc(expanded, func(p v1alpha1.NamespacedPermissions) bool { return p.Namespace == namespace }) {
+					requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&at)})
+				}
+			}
+		}
+		return requests
+	}
+}
+
+// accessTokensIndexed returns a map function enqueuing the AccessTokens indexed under the value returned by valueOf,
+// e.g. to reconcile the AccessTokens targeting a namespace when consent is granted or withdrawn.
+func (r *reconciler) accessTokensIndexed(index string, valueOf func(client.Object) string) func(context.Context, client.Object) []reconcile.Request {
+	return func(ctx context.Context, o client.Object) []reconcile.Request {
+		accessTokens := &v1alpha1.AccessTokenList{}
+		if err := r.c.List(ctx, accessTokens, client.MatchingFields{index: valueOf(o)}); err != nil {
+			r.log.Errorf("listing AccessTokens indexed by %s under %s: %s", index, valueOf(o), err)
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(accessTokens.Items))
+		for _, at := range accessTokens.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&at)})
+		}
+		return requests
+	}
+}
+
+// mergeMapFuncs returns a map function enqueuing the requests of every map function once, so that a single watch can
+// serve several indexes.
+func mergeMapFuncs(fns ...handler.MapFunc) handler.MapFunc {
+	return func(ctx context.Context, o client.Object) []reconcile.Request {
+		var requests []reconcile.Request
+		for _, fn := range fns {
+			for _, req := range fn(ctx, o) {
+				if !slices.Contains(requests, req) {
+					requests = append(requests, req)
+				}
+			}
+		}
+		return requests
+	}
+}
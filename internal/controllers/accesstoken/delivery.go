@@ -0,0 +1,159 @@
+package accesstoken
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-sdk/pkg/fsm/types"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/tokencrypt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// defaultDeliveryExpirationSeconds is the lifetime requested for delivered tokens if `spec.delivery.expirationSeconds`
+// isn't set.
+const defaultDeliveryExpirationSeconds = int64(24 * time.Hour / time.Second)
+
+// validateDelivery refuses specs combining delivery with features that require the token Secret.
+func validateDelivery(spec v1alpha1.AccessTokenSpec) error {
+	if spec.Delivery == nil {
+		return nil
+	}
+	if len(spec.SecretTargets) > 0 || len(spec.TargetClusters) > 0 {
+		return errors.New("spec.delivery can't be combined with spec.secretTargets or spec.targetClusters, they require the token Secret")
+	}
+	return nil
+}
+
+// checkDelivery reports specs refused by validateDelivery through the TokenDelivered condition and stops there, no
+// token is delivered until the spec is fixed. Retrying wouldn't help, so the reconcile isn't failed.
+func (r *reconciler) checkDelivery(nextState *state) *state {
+	return &state{
+		Name: "check-delivery",
+		Transition: func(
+			ctx context.Context,
+			accessToken *v1alpha1.AccessToken,
+			out *types.OutputSet,
+		) (*state, types.Result) {
+			if err := validateDelivery(accessToken.Spec); err != nil {
+				accessToken.SetConditions(api.Condition{
+					Type:               v1alpha1.TypeTokenDelivered,
+					Status:             corev1.ConditionFalse,
+					Reason:             v1alpha1.ReasonDeliveryConflict,
+					Message:            err.Error(),
+					ObservedGeneration: accessToken.GetGeneration(),
+					LastTransitionTime: metav1.Now(),
+				})
+				return nil, types.DoneResult()
+			}
+			return nextState, types.DoneResult()
+		},
+	}
+}
+
+// deliverToken issues a token for the AccessToken's ServiceAccount through the TokenRequest API and writes it, encrypted
+// to `spec.delivery.publicKey`, to `status.encryptedToken`. The plaintext token is never stored. The token is reissued
+// once half of the lifetime it was issued with has passed, see requeueReissue, or the public key changes.
+func (r *reconciler) deliverToken(nextState *state) *state {
+	return &state{
+		Name:      "deliver-token",
+		Condition: conditionTokenDelivered,
+		Transition: func(
+			ctx context.Context,
+			accessToken *v1alpha1.AccessToken,
+			out *types.OutputSet,
+		) (*state, types.Result) {
+			delivery := accessToken.Spec.Delivery
+			if delivery == nil {
+				accessToken.Status.EncryptedToken = ""
+				accessToken.Status.EncryptedTokenPublicKey = ""
+				accessToken.Status.EncryptedTokenIssueTimestamp = nil
+				accessToken.Status.EncryptedTokenExpirationTimestamp = nil
+				return nextState, types.DoneResult()
+			}
+
+			now := time.Now()
+			if !needsReissue(accessToken.Status, delivery.PublicKey, now) {
+				return nextState, types.DoneResult()
+			}
+
+			sa := newBuilder(accessToken).serviceAccount()
+			tokenRequest := &authenticationv1.TokenRequest{
+				Spec: authenticationv1.TokenRequestSpec{
+					ExpirationSeconds: ptr.To(ptr.Deref(delivery.ExpirationSeconds, defaultDeliveryExpirationSeconds)),
+				},
+			}
+			if err := r.c.SubResource("token").Create(ctx, sa, tokenRequest); err != nil {
+				return nil, types.ErrorResultf("requesting token for %T %s: %s", sa, client.ObjectKeyFromObject(sa), err)
+			}
+
+			sealed, err := tokencrypt.Encrypt(delivery.PublicKey, []byte(tokenRequest.Status.Token))
+			if err != nil {
+				return nil, types.ErrorResultf("encrypting token to spec.delivery.publicKey: %s", err)
+			}
+
+			if accessToken.Status.EncryptedToken != "" {
+				r.metrics.RecordRotation(accessToken.GetNamespace())
+			}
+			accessToken.Status.EncryptedToken = sealed
+			accessToken.Status.EncryptedTokenPublicKey = delivery.PublicKey
+			accessToken.Status.EncryptedTokenIssueTimestamp = ptr.To(metav1.NewTime(now))
+			accessToken.Status.EncryptedTokenExpirationTimestamp = ptr.To(tokenRequest.Status.ExpirationTimestamp)
+
+			return nextState, types.DoneResult()
+		},
+	}
+}
+
+// needsReissue returns true if the delivered token is missing, encrypted to another public key, or past half of its
+// lifetime.
+func needsReissue(status v1alpha1.AccessTokenStatus, publicKey string, now time.Time) bool {
+	if status.EncryptedToken == "" || status.EncryptedTokenPublicKey != publicKey {
+		return true
+	}
+	reissueAt, ok := reissueTime(status)
+	return !ok || !now.Before(reissueAt)
+}
+
+// reissueTime returns when half of the delivered token's lifetime has passed. The lifetime is the one the token was
+// issued with, which the API server may have shortened from `spec.delivery.expirationSeconds`.
+func reissueTime(status v1alpha1.AccessTokenStatus) (time.Time, bool) {
+	issued, expires := status.EncryptedTokenIssueTimestamp, status.EncryptedTokenExpirationTimestamp
+	if issued == nil || expires == nil {
+		return time.Time{}, false
+	}
+	return issued.Add(expires.Sub(issued.Time) / 2), true
+}
+
+// requeueReissue requeues AccessTokens with delivered tokens once they're due to be reissued, the token's lifetime
+// passes without any event triggering a reconcile otherwise.
+func requeueReissue(o client.Object, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	accessToken, ok := o.(*v1alpha1.AccessToken)
+	if !ok || accessToken.Spec.Delivery == nil {
+		return
+	}
+	if reissueAt, ok := reissueTime(accessToken.Status); ok {
+		q.AddAfter(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(accessToken)}, time.Until(reissueAt))
+	}
+}
+
+// reissueHandler calls requeueReissue whenever an AccessToken is created, e.g. on startup, or updated, e.g. once its
+// token is reissued.
+var reissueHandler = handler.Funcs{
+	CreateFunc: func(_ context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		requeueReissue(e.Object, q)
+	},
+	UpdateFunc: func(_ context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		requeueReissue(e.ObjectNew, q)
+	},
+}
@@ -0,0 +1,36 @@
+package accesstoken
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// checkDenyList reports the namespaces and rules of the AccessToken, given by spec with permission sets resolved, refused
+// by the deny list through the PermissionsAllowed condition. The builder skips them, the rest of the token is
+// provisioned.
+func (r *reconciler) checkDenyList(accessToken *v1alpha1.AccessToken, spec v1alpha1.AccessTokenSpec) {
+	condition := api.Condition{
+		Type:               v1alpha1.TypePermissionsAllowed,
+		Status:             corev1.ConditionTrue,
+		Reason:             v1alpha1.ReasonNoDeniedPermissions,
+		Message:            "No permissions are refused by the controller's deny list",
+		ObservedGeneration: accessToken.GetGeneration(),
+		LastTransitionTime: metav1.Now(),
+	}
+
+	if violations := r.denyList.Check(spec); len(violations) > 0 {
+		refused := make([]string, 0, len(violations))
+		for _, v := range violations {
+			refused = append(refused, v.String())
+		}
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = v1alpha1.ReasonPermissionsDenied
+		condition.Message = fmt.Sprintf("Permissions refused by the controller's deny list are not granted: %s", strings.Join(refused, "; "))
+	}
+	accessToken.SetConditions(condition)
+}
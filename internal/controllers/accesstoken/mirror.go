@@ -0,0 +1,158 @@
+package accesstoken
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// indexSecretTargetNamespace indexes AccessTokens by the namespaces of their SecretTargets.
+const indexSecretTargetNamespace = "accesstoken.spec.secretTargets.namespace"
+
+// mirroredSecret returns the Secret the token Secret is mirrored to for the target.
+func (b *builder) mirroredSecret(target v1alpha1.SecretTarget) *corev1.Secret {
+	name := target.Name
+	if name == "" {
+		name = b.accessToken.GetName()
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: target.Namespace,
+			Labels:    b.labels(),
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+}
+
+// mirrorSecrets returns the Secrets the token Secret is mirrored to, for the SecretTargets of the AccessToken whose
+// namespace has opted in, and reports refused targets through the SecretsMirrored condition. Targets naming a Secret
+// managed for the AccessToken, or an existing Secret that isn't one of its mirrors, are refused as conflicting rather
+// than overwritten. The Secrets only hold the token's data if synced is true, until the token is issued they're only
+// returned so existing mirrors aren't deleted.
+func (r *reconciler) mirrorSecrets(ctx context.Context, accessToken *v1alpha1.AccessToken, b *builder) (mirrors []*corev1.Secret, synced bool, err error) {
+	condition := api.Condition{
+		Type:               v1alpha1.TypeSecretsMirrored,
+		Status:             corev1.ConditionTrue,
+		Reason:             v1alpha1.ReasonSecretsInSync,
+		Message:            "The token Secret is mirrored to every secret target",
+		ObservedGeneration: accessToken.GetGeneration(),
+		LastTransitionTime: metav1.Now(),
+	}
+
+	var refused, conflicting []string
+	for _, target := range accessToken.Spec.SecretTargets {
+		ok, err := acceptsSecrets(ctx, r.c, target.Namespace, accessToken.GetNamespace())
+		if err != nil {
+			return nil, false, err
+		}
+		mirror := b.mirroredSecret(target)
+		if !ok {
+			refused = append(refused, client.ObjectKeyFromObject(mirror).String())
+			continue
+		}
+		owned, err := r.ownsMirror(ctx, b, mirror)
+		if err != nil {
+			return nil, false, err
+		}
+		if !owned {
+			conflicting = append(conflicting, client.ObjectKeyFromObject(mirror).String())
+			continue
+		}
+		mirrors = append(mirrors, mirror)
+	}
+
+	token := b.secret()
+	if err := r.c.Get(ctx, client.ObjectKeyFromObject(token), token); err != nil && !errors.IsNotFound(err) {
+		return nil, false, fmt.Errorf("getting token %T %s: %w", token, client.ObjectKeyFromObject(token), err)
+	}
+	synced = len(token.Data[corev1.ServiceAccountTokenKey]) > 0
+	if synced {
+		for _, mirror := range mirrors {
+			mirror.Data = maps.Clone(token.Data)
+		}
+	}
+
+	switch {
+	case len(conflicting) > 0:
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = v1alpha1.ReasonSecretTargetsConflict
+		condition.Message = fmt.Sprintf("The token Secret isn't mirrored to %s, they name Secrets that aren't mirrors of the token",
+			strings.Join(conflicting, ", "))
+	case len(refused) > 0:
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = v1alpha1.ReasonSecretTargetsRefused
+		condition.Message = fmt.Sprintf("The token Secret isn't mirrored to %s, their namespaces must opt in through the %s annotation",
+			strings.Join(refused, ", "), v1alpha1.AnnotationAllowedSecretSourceNamespaces)
+	case !synced && len(mirrors) > 0:
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = v1alpha1.ReasonTokenNotIssued
+		condition.Message = "Waiting for the token to be issued before mirroring it"
+	}
+	accessToken.SetConditions(condition)
+
+	return mirrors, synced, nil
+}
+
+// ownsMirror returns true if the mirror may be applied: it doesn't collide with the token Secret or the Secrets
+// collecting tokens from target clusters, and doesn't exist yet or carries the labels of the AccessToken.
+func (r *reconciler) ownsMirror(ctx context.Context, b *builder, mirror *corev1.Secret) (bool, error) {
+	for _, o := range append([]client.Object{b.secret()}, b.remoteTokenSecrets()...) {
+		if client.ObjectKeyFromObject(o) == client.ObjectKeyFromObject(mirror) {
+			return false, nil
+		}
+	}
+
+	existing := &corev1.Secret{}
+	if err := r.c.Get(ctx, client.ObjectKeyFromObject(mirror), existing); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("getting %T %s: %w", existing, client.ObjectKeyFromObject(mirror), err)
+	}
+	for k, v := range b.labels() {
+		if existing.GetLabels()[k] != v {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// acceptsSecrets returns true if the target namespace opted in to receiving mirrored token Secrets of AccessTokens in the
+// source namespace through the AnnotationAllowedSecretSourceNamespaces annotation. A namespace always accepts Secrets of
+// AccessTokens in itself, namespaces that don't exist never do.
+func acceptsSecrets(ctx context.Context, c client.Reader, target, source string) (bool, error) {
+	if target == source {
+		return true, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: target}, ns); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting %T %s: %w", ns, target, err)
+	}
+	return annotationAllows(ns, v1alpha1.AnnotationAllowedSecretSourceNamespaces, source), nil
+}
+
+// secretTargetNamespaces is the index function of indexSecretTargetNamespace.
+func secretTargetNamespaces(o client.Object) []string {
+	accessToken, ok := o.(*v1alpha1.AccessToken)
+	if !ok {
+		return nil
+	}
+	var namespaces []string
+	for _, target := range accessToken.Spec.SecretTargets {
+		namespaces = append(namespaces, target.Namespace)
+	}
+	return namespaces
+}
@@ -0,0 +1,120 @@
+package accesstoken
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// indexPermissionSetRef indexes AccessTokens by the permission sets they reference, see permissionSetKey.
+const indexPermissionSetRef = "accesstoken.spec.permissionSetRefs"
+
+// RulesGetter returns the rules of a permission set referenced by an AccessToken in the namespace.
+type RulesGetter func(ref v1alpha1.PermissionSetRef, namespace string) ([]rbacv1.PolicyRule, error)
+
+// resolvePermissionSets returns a copy of the AccessToken with the rules of every referenced permission set appended to
+// the rules of the permissions referencing it.
+func resolvePermissionSets(accessToken *v1alpha1.AccessToken, get RulesGetter) (*v1alpha1.AccessToken, error) {
+	resolved := accessToken.DeepCopy()
+
+	resolve := func(refs []v1alpha1.PermissionSetRef, rules []rbacv1.PolicyRule) ([]rbacv1.PolicyRule, error) {
+		for _, ref := range refs {
+			refRules, err := get(ref, accessToken.GetNamespace())
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, refRules...)
+		}
+		return rules, nil
+	}
+
+	for i := range resolved.Spec.NamespacedPermissions {
+		p := &resolved.Spec.NamespacedPermissions[i]
+		rules, err := resolve(p.PermissionSetRefs, p.Rules)
+		if err != nil {
+			return nil, err
+		}
+		p.Rules = rules
+	}
+	if p := resolved.Spec.ClusterPermissions; p != nil {
+		rules, err := resolve(p.PermissionSetRefs, p.Rules)
+		if err != nil {
+			return nil, err
+		}
+		p.Rules = rules
+	}
+
+	return resolved, nil
+}
+
+// clientRulesGetter returns a RulesGetter reading permission sets with the client.
+func clientRulesGetter(ctx context.Context, c client.Reader) RulesGetter {
+	return func(ref v1alpha1.PermissionSetRef, namespace string) ([]rbacv1.PolicyRule, error) {
+		var obj client.Object
+		var rules func() []rbacv1.PolicyRule
+		switch refKind(ref) {
+		case v1alpha1.ClusterPermissionSetKind:
+			set := &v1alpha1.ClusterPermissionSet{}
+			obj, rules = set, func() []rbacv1.PolicyRule { return set.Spec.Rules }
+			namespace = ""
+		case v1alpha1.PermissionSetKind:
+			set := &v1alpha1.PermissionSet{}
+			obj, rules = set, func() []rbacv1.PolicyRule { return set.Spec.Rules }
+		default:
+			return nil, fmt.Errorf("unknown permission set kind %q", ref.Kind)
+		}
+
+		key := client.ObjectKey{Namespace: namespace, Name: ref.Name}
+		if err := c.Get(ctx, key, obj); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, fmt.Errorf("referenced %s %s not found", refKind(ref), key)
+			}
+			return nil, fmt.Errorf("getting %s %s: %w", refKind(ref), key, err)
+		}
+		return rules(), nil
+	}
+}
+
+// refKind returns the kind of the referenced permission set, defaulted like the API server does.
+func refKind(ref v1alpha1.PermissionSetRef) string {
+	if ref.Kind == "" {
+		return v1alpha1.PermissionSetKind
+	}
+	return ref.Kind
+}
+
+// permissionSetKey identifies a permission set in indexPermissionSetRef.
+func permissionSetKey(kind, namespace, name string) string {
+	if kind == v1alpha1.ClusterPermissionSetKind {
+		return fmt.Sprintf("%s/%s", kind, name)
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// referencedPermissionSets is the index function of indexPermissionSetRef.
+func referencedPermissionSets(o client.Object) []string {
+	accessToken, ok := o.(*v1alpha1.AccessToken)
+	if !ok {
+		return nil
+	}
+	var refs []v1alpha1.PermissionSetRef
+	for _, p := range accessToken.Spec.NamespacedPermissions {
+		refs = append(refs, p.PermissionSetRefs...)
+	}
+	if accessToken.Spec.ClusterPermissions != nil {
+		refs = append(refs, accessToken.Spec.ClusterPermissions.PermissionSetRefs...)
+	}
+
+	var keys []string
+	for _, ref := range refs {
+		if key := permissionSetKey(refKind(ref), accessToken.GetNamespace(), ref.Name); !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
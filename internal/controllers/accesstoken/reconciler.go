@@ -2,6 +2,7 @@ package accesstoken
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/reddit/achilles-sdk/pkg/fsm"
 	"github.com/reddit/achilles-sdk/pkg/fsm/types"
@@ -10,7 +11,11 @@ import (
 	"github.com/reddit/achilles-sdk/pkg/meta"
 	"github.com/reddit/achilles-sdk/pkg/sets"
 	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/accessreview"
+	"github.com/reddit/achilles-token-controller/internal/audit"
 	"github.com/reddit/achilles-token-controller/internal/controlplane"
+	"github.com/reddit/achilles-token-controller/internal/denylist"
+	"github.com/reddit/achilles-token-controller/internal/tokenmetrics"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -20,6 +25,8 @@ import (
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -35,6 +42,9 @@ import (
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=*
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=*
 // +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=*
+// +kubebuilder:rbac:groups=core,resources=serviceaccounts/token,verbs=create
+// +kubebuilder:rbac:groups=group.example.com,resources=accesstokengrants;accesstokentemplates;permissionsets;clusterpermissionsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
 
 const (
 	controllerName = "AccessToken"
@@ -43,9 +53,18 @@ const (
 type state = types.State[*v1alpha1.AccessToken]
 
 type reconciler struct {
-	c      *io.ClientApplicator
-	scheme *runtime.Scheme
-	log    *zap.SugaredLogger
+	c        *io.ClientApplicator
+	scheme   *runtime.Scheme
+	log      *zap.SugaredLogger
+	metrics  *tokenmetrics.Metrics
+	auditor  *audit.Logger
+	reviewer *accessreview.Reviewer
+
+	// requireConsent, if true, only grants permissions in namespaces that consent to the AccessToken's namespace
+	requireConsent bool
+
+	// denyList refuses namespaces and rules no AccessToken may be granted
+	denyList *denylist.DenyList
 }
 
 func (r *reconciler) provisionToken() *state {
@@ -57,9 +76,31 @@ func (r *reconciler) provisionToken() *state {
 			accessToken *v1alpha1.AccessToken,
 			out *types.OutputSet,
 		) (*state, types.Result) {
-			builder := newBuilder(accessToken)
+			// the token's permissions include those of the template and permission sets it references
+			resolved, err := Resolve(accessToken, ClientResolver(ctx, r.c))
+			if err != nil {
+				return nil, types.ErrorResult(err)
+			}
+
+			if err := r.classifyRisk(ctx, accessToken, resolved.Spec); err != nil {
+				return nil, types.ErrorResult(err)
+			}
+
+			unconsented, err := r.checkConsent(ctx, accessToken, resolved.Spec)
+			if err != nil {
+				return nil, types.ErrorResult(err)
+			}
+			r.checkDenyList(accessToken, resolved.Spec)
+
+			builder := newBuilder(resolved).withoutNamespaces(unconsented...).withDenyList(r.denyList)
 
 			outputs := builder.build()
+
+			grants, err := grantRecords(ctx, r.c, "", accessToken, outputs)
+			if err != nil {
+				return nil, types.ErrorResult(err)
+			}
+
 			for _, o := range outputs {
 				var applyOpts []io.ApplyOption
 
@@ -81,14 +122,36 @@ func (r *reconciler) provisionToken() *state {
 				out.Apply(o, applyOpts...)
 			}
 
-			accessToken.Status.TokenSecretRef = ptr.To(builder.secret().Name)
+			accessToken.Status.TokenSecretRef = nil
+			if accessToken.Spec.Delivery == nil {
+				accessToken.Status.TokenSecretRef = ptr.To(builder.secret().Name)
+			}
+
+			// the local Secrets collecting tokens issued by target clusters are applied by provisionRemoteTokens
+			desired := append(outputs, builder.remoteTokenSecrets()...)
+
+			mirrors, synced, err := r.mirrorSecrets(ctx, accessToken, builder)
+			if err != nil {
+				return nil, types.ErrorResult(err)
+			}
+			for _, mirror := range mirrors {
+				// mirrors that can't be synced yet are kept as they are rather than being emptied
+				if synced {
+					var applyOpts []io.ApplyOption
+					if mirror.GetNamespace() != accessToken.GetNamespace() {
+						applyOpts = append(applyOpts, io.WithoutOwnerRefs())
+					}
+					out.Apply(mirror, applyOpts...)
+				}
+				desired = append(desired, mirror)
+			}
 
-			return r.deleteStalePermissions(outputs), types.DoneResult()
+			return r.auditGrants(grants, r.deleteStalePermissions(desired, r.checkDelivery(r.deliverToken(r.provisionRemoteTokens(resolved, r.verifyPermissions(outputs, r.evaluateAssertions(resolved))))))), types.DoneResult()
 		},
 	}
 }
 
-func (r *reconciler) deleteStalePermissions(desiredObjs []client.Object) *state {
+func (r *reconciler) deleteStalePermissions(desiredObjs []client.Object, nextState *state) *state {
 	return &state{
 		Name:      "delete-stale-permissions",
 		Condition: conditionStalePermissionsRemoved,
@@ -97,6 +160,7 @@ func (r *reconciler) deleteStalePermissions(desiredObjs []client.Object) *state
 			accessToken *v1alpha1.AccessToken,
 			out *types.OutputSet,
 		) (*state, types.Result) {
+			tokenSecret := newBuilder(accessToken).secret()
 			desired := sets.NewObjectSet(r.scheme, desiredObjs...)
 			actual := sets.NewObjectSet(r.scheme)
 
@@ -123,10 +187,27 @@ func (r *reconciler) deleteStalePermissions(desiredObjs []client.Object) *state
 
 			// delete stale permissions
 			for _, staleObj := range actual.Difference(desired).List() {
+				if err := r.auditRevocation(ctx, "", accessToken, staleObj); err != nil {
+					return nil, types.ErrorResult(err)
+				}
+
 				out.Delete(staleObj)
+
+				gvk, err := apiutil.GVKForObject(staleObj, r.scheme)
+				if err != nil {
+					return nil, types.ErrorResultf("getting GVK for %T %s: %s", staleObj, client.ObjectKeyFromObject(staleObj), err)
+				}
+				r.metrics.RecordStaleObjectDeleted(accessToken.GetNamespace(), gvk.Kind)
+
+				// deleting the token Secret of an AccessToken being deleted revokes the token, mirrors and the Secrets
+				// collecting remote tokens only hold copies
+				if _, ok := staleObj.(*corev1.Secret); ok && accessToken.GetDeletionTimestamp() != nil &&
+					client.ObjectKeyFromObject(staleObj) == client.ObjectKeyFromObject(tokenSecret) {
+					r.metrics.RecordRevocation(accessToken.GetNamespace())
+				}
 			}
 
-			return nil, types.DoneResult()
+			return nextState, types.DoneResult()
 		},
 	}
 }
@@ -144,9 +225,15 @@ func SetupController(
 	}
 
 	r := &reconciler{
-		c:      c,
-		scheme: mgr.GetScheme(),
-		log:    log,
+		c:        c,
+		scheme:   mgr.GetScheme(),
+		log:      log,
+		metrics:  cpCtx.TokenMetrics,
+		auditor:  cpCtx.Auditor,
+		reviewer: accessreview.NewReviewer(c),
+
+		requireConsent: cpCtx.RequireNamespaceConsent,
+		denyList:       cpCtx.DenyList.WithRESTMapper(mgr.GetRESTMapper()),
 	}
 
 	builder := fsm.NewBuilder(
@@ -162,8 +249,66 @@ func SetupController(
 		rbacv1.SchemeGroupVersion.WithKind("ClusterRoleBinding"),
 	).WithFinalizerState(
 		// NOTE: we can't rely on native Kubernetes GC to delete cluster scoped resources (ClusterRole, ClusterRoleBinding)
-		// or cross-namespace resources (Roles, RoleBindings) so we need to handle this ourselves
-		r.deleteStalePermissions(nil),
+		// or cross-namespace resources (Roles, RoleBindings) so we need to handle this ourselves, the same goes for
+		// everything provisioned in target clusters
+		r.deleteRemoteTokens(r.deleteStalePermissions(nil, nil)),
+	)
+
+	// reconcile AccessTokens when their delivered token is due to be reissued
+	builder = builder.Watches(&v1alpha1.AccessToken{}, reissueHandler)
+
+	// reconcile AccessTokens whose permissions weren't granted yet to verify them again
+	builder = builder.Watches(&v1alpha1.AccessToken{}, verificationHandler)
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &v1alpha1.AccessToken{}, indexPermissionSetRef, referencedPermissionSets); err != nil {
+		return fmt.Errorf("indexing %s: %w", indexPermissionSetRef, err)
+	}
+
+	// reconcile AccessTokens when the permission sets they reference change
+	builder = builder.Watches(
+		&v1alpha1.PermissionSet{},
+		handler.EnqueueRequestsFromMapFunc(r.accessTokensIndexed(indexPermissionSetRef, func(o client.Object) string {
+			return permissionSetKey(v1alpha1.PermissionSetKind, o.GetNamespace(), o.GetName())
+		})),
+	).Watches(
+		&v1alpha1.ClusterPermissionSet{},
+		handler.EnqueueRequestsFromMapFunc(r.accessTokensIndexed(indexPermissionSetRef, func(o client.Object) string {
+			return permissionSetKey(v1alpha1.ClusterPermissionSetKind, "", o.GetName())
+		})),
+	)
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &v1alpha1.AccessToken{}, indexTemplateRef, referencedTemplate); err != nil {
+		return fmt.Errorf("indexing %s: %w", indexTemplateRef, err)
+	}
+
+	// reconcile AccessTokens when the template they reference changes
+	builder = builder.Watches(
+		&v1alpha1.AccessTokenTemplate{},
+		handler.EnqueueRequestsFromMapFunc(r.accessTokensIndexed(indexTemplateRef, client.Object.GetName)),
+	)
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &v1alpha1.AccessToken{}, indexSecretTargetNamespace, secretTargetNamespaces); err != nil {
+		return fmt.Errorf("indexing %s: %w", indexSecretTargetNamespace, err)
+	}
+	// consent is checked even if it isn't required, to report namespaces that don't consent
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &v1alpha1.AccessToken{}, indexTargetNamespace, targetNamespaces); err != nil {
+		return fmt.Errorf("indexing %s: %w", indexTargetNamespace, err)
+	}
+
+	// reconcile AccessTokens when namespaces grant or withdraw consent, or opt in to or out of receiving their Secrets
+	builder = builder.Watches(
+		&v1alpha1.AccessTokenGrant{},
+		handler.EnqueueRequestsFromMapFunc(mergeMapFuncs(
+			r.accessTokensIndexed(indexTargetNamespace, client.Object.GetNamespace),
+			r.templatedAccessTokensTargeting(client.Object.GetNamespace),
+		)),
+	).Watches(
+		&corev1.Namespace{},
+		handler.EnqueueRequestsFromMapFunc(mergeMapFuncs(
+			r.accessTokensIndexed(indexTargetNamespace, client.Object.GetName),
+			r.templatedAccessTokensTargeting(client.Object.GetName),
+			r.accessTokensIndexed(indexSecretTargetNamespace, client.Object.GetName),
+		)),
 	)
 
 	return builder.Build()(mgr, log, rl, cpCtx.Metrics)
@@ -14,10 +14,13 @@ import (
 	"github.com/reddit/achilles-sdk/pkg/logging"
 	achratelimiter "github.com/reddit/achilles-sdk/pkg/ratelimiter"
 	sdktest "github.com/reddit/achilles-sdk/pkg/test"
+	"github.com/reddit/achilles-token-controller/internal/audit"
 	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
 	"github.com/reddit/achilles-token-controller/internal/controlplane"
+	"github.com/reddit/achilles-token-controller/internal/denylist"
 	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
 	"github.com/reddit/achilles-token-controller/internal/test"
+	"github.com/reddit/achilles-token-controller/internal/tokenmetrics"
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -68,8 +71,14 @@ var _ = BeforeSuite(func() {
 					Applicator: io.NewAPIPatchingApplicator(mgr.GetClient()),
 				}
 
+				reg := prometheus.NewRegistry()
 				cpCtx := controlplane.Context{
-					Metrics: metrics.MustMakeMetrics(scheme, prometheus.NewRegistry()),
+					Metrics:      metrics.MustMakeMetrics(scheme, reg),
+					TokenMetrics: tokenmetrics.MustMakeMetrics(mgr.GetClient(), accesstoken.ClientResolveFunc(mgr.GetClient()), reg),
+					Auditor:      audit.NewLogger(audit.NewJSONLinesSink(GinkgoWriter)),
+
+					RequireNamespaceConsent: true,
+					DenyList:                denylist.New([]string{"protected"}, []string{"nodes"}),
 				}
 
 				return accesstoken.SetupController(ctx, cpCtx, mgr, rl, clientApplicator)
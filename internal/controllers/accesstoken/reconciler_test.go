@@ -6,6 +6,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/tokencrypt"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -13,6 +14,7 @@ import (
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
 )
 
 var _ = Describe("AccessTokenReconciler", Ordered, func() {
@@ -56,8 +58,35 @@ var _ = Describe("AccessTokenReconciler", Ordered, func() {
 						},
 					},
 				},
+				Assertions: &v1alpha1.Assertions{
+					Allow: []v1alpha1.AccessAssertion{
+						{
+							Verb:      "list",
+							Resource:  "configmaps",
+							Namespace: "kube-system",
+						},
+					},
+					Deny: []v1alpha1.AccessAssertion{
+						{
+							Verb:     "get",
+							Resource: "secrets",
+						},
+					},
+				},
+			},
+		}
+
+		// consent to grants from the "default" namespace
+		grant := &v1alpha1.AccessTokenGrant{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "allow-default",
+				Namespace: "kube-system",
+			},
+			Spec: v1alpha1.AccessTokenGrantSpec{
+				From: []v1alpha1.AccessTokenGrantFrom{{Namespace: "default"}},
 			},
 		}
+		Expect(client.IgnoreAlreadyExists(c.Create(ctx, grant))).To(Succeed())
 
 		Expect(c.Create(ctx, accessToken)).To(Succeed())
 	})
@@ -78,6 +107,8 @@ var _ = Describe("AccessTokenReconciler", Ordered, func() {
 
 			g.Expect(actual.Type).To(Equal(corev1.SecretTypeServiceAccountToken))
 			g.Expect(actual.Annotations).To(HaveKeyWithValue("kubernetes.io/service-account.name", accessToken.Name))
+			g.Expect(actual.Labels).To(HaveKeyWithValue(v1alpha1.LabelAccessTokenName, accessToken.Name))
+			g.Expect(actual.Labels).To(HaveKeyWithValue(v1alpha1.LabelAccessTokenNamespace, accessToken.Namespace))
 		}).Should(Succeed())
 
 		// ServiceAccount
@@ -237,6 +268,48 @@ var _ = Describe("AccessTokenReconciler", Ordered, func() {
 			g.Expect(actual.Status.TokenSecretRef).To(Equal(expectedTokenSecretRef))
 		}).Should(Succeed())
 
+		By("verifying the declared permissions with the API server")
+
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypePermissionsVerified).Status).To(Equal(corev1.ConditionTrue))
+			g.Expect(actual.GetCondition(v1alpha1.TypePermissionsVerified).Reason).To(Equal(v1alpha1.ReasonPermissionsGranted))
+		}).Should(Succeed())
+
+		By("evaluating access assertions")
+
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypeAssertionsSatisfied).Status).To(Equal(corev1.ConditionTrue))
+			g.Expect(actual.Status.AssertionResults).To(HaveLen(2))
+			for _, result := range actual.Status.AssertionResults {
+				g.Expect(result.Satisfied).To(BeTrue(), result.Message)
+			}
+		}).Should(Succeed())
+
+		By("reporting namespace consent")
+
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypeNamespacesConsented).Status).To(Equal(corev1.ConditionTrue))
+		}).Should(Succeed())
+
+		By("surfacing high risk permissions")
+
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypeHighRiskPermissions).Status).To(Equal(corev1.ConditionTrue))
+			g.Expect(actual.GetCondition(v1alpha1.TypeHighRiskPermissions).Reason).To(Equal(v1alpha1.ReasonHighRiskPermissionsGranted))
+			g.Expect(actual.GetAnnotations()).To(HaveKeyWithValue(
+				v1alpha1.AnnotationRiskFindings,
+				`[{"id":"wildcard-verbs","severity":"high","path":"spec.namespacedPermissions[0].rules[0]"}]`,
+			))
+		}).Should(Succeed())
+
 		By("cleaning up stale permissions")
 
 		// mutate AccessToken to remove permissions for "kube-system" namespace
@@ -298,3 +371,929 @@ var _ = Describe("AccessTokenReconciler", Ordered, func() {
 		}).Should(Succeed())
 	})
 })
+
+var _ = Describe("Namespace consent", Ordered, func() {
+	var (
+		accessToken *v1alpha1.AccessToken
+		target      *corev1.Namespace
+		role        *rbacv1.Role
+	)
+
+	BeforeAll(func() {
+		target = &corev1.Namespace{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "consent-target",
+			},
+		}
+		Expect(c.Create(ctx, target)).To(Succeed())
+
+		accessToken = &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "consent",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+					{
+						Namespace: target.Name,
+						Rules: []rbacv1.PolicyRule{
+							{
+								APIGroups: []string{""},
+								Resources: []string{"configmaps"},
+								Verbs:     []string{"get"},
+							},
+						},
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		role = &rbacv1.Role{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      accessToken.Name,
+				Namespace: target.Name,
+			},
+		}
+	})
+
+	It("should skip namespaces that haven't consented", func() {
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypeNamespacesConsented).Status).To(Equal(corev1.ConditionFalse))
+			g.Expect(actual.GetCondition(v1alpha1.TypeNamespacesConsented).Reason).To(Equal(v1alpha1.ReasonConsentMissing))
+			g.Expect(actual.GetCondition(v1alpha1.TypeNamespacesConsented).Message).To(ContainSubstring(target.Name))
+			g.Expect(actual.Status.TokenSecretRef).ToNot(BeNil())
+		}).Should(Succeed())
+
+		Consistently(func(g Gomega) {
+			g.Expect(errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(role), &rbacv1.Role{}))).To(BeTrue())
+		}, "2s").Should(Succeed())
+	})
+
+	It("should grant permissions once the namespace consents", func() {
+		grant := &v1alpha1.AccessTokenGrant{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "allow-default",
+				Namespace: target.Name,
+			},
+			Spec: v1alpha1.AccessTokenGrantSpec{
+				From: []v1alpha1.AccessTokenGrantFrom{{Namespace: "default"}},
+			},
+		}
+		Expect(c.Create(ctx, grant)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(role), &rbacv1.Role{})).To(Succeed())
+
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypeNamespacesConsented).Status).To(Equal(corev1.ConditionTrue))
+		}).Should(Succeed())
+
+		By("revoking permissions once consent is withdrawn")
+		Expect(c.Delete(ctx, grant)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(role), &rbacv1.Role{}))).To(BeTrue())
+		}).Should(Succeed())
+	})
+
+	It("should honor the namespace label", func() {
+		patched := target.DeepCopy()
+		patched.SetLabels(map[string]string{v1alpha1.LabelPrefixAllowedSourceNamespace + "default": "true"})
+		Expect(c.Patch(ctx, patched, client.MergeFrom(target))).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(role), &rbacv1.Role{})).To(Succeed())
+		}).Should(Succeed())
+
+		By("revoking permissions once the label is removed")
+		Expect(c.Patch(ctx, target.DeepCopy(), client.MergeFrom(patched))).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(role), &rbacv1.Role{}))).To(BeTrue())
+		}).Should(Succeed())
+	})
+
+	It("should honor the namespace annotation", func() {
+		patched := target.DeepCopy()
+		patched.SetAnnotations(map[string]string{v1alpha1.AnnotationAllowedSourceNamespaces: "other, default"})
+		Expect(c.Patch(ctx, patched, client.MergeFrom(target))).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(role), &rbacv1.Role{})).To(Succeed())
+		}).Should(Succeed())
+
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+	})
+})
+
+var _ = Describe("Secret targets", func() {
+	It("should mirror the token Secret into namespaces that opt in", func() {
+		consumer := &corev1.Namespace{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "consumer",
+				Annotations: map[string]string{v1alpha1.AnnotationAllowedSecretSourceNamespaces: "default"},
+			},
+		}
+		Expect(c.Create(ctx, consumer)).To(Succeed())
+		unwilling := &corev1.Namespace{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "unwilling",
+			},
+		}
+		Expect(c.Create(ctx, unwilling)).To(Succeed())
+
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "mirrored",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				SecretTargets: []v1alpha1.SecretTarget{
+					{Namespace: consumer.Name, Name: "api-token"},
+					{Namespace: unwilling.Name},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		token := &corev1.Secret{}
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), token)).To(Succeed())
+
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypeSecretsMirrored).Reason).To(Equal(v1alpha1.ReasonSecretTargetsRefused))
+			g.Expect(actual.GetCondition(v1alpha1.TypeSecretsMirrored).Message).To(ContainSubstring("unwilling/mirrored"))
+		}).Should(Succeed())
+
+		// envtest doesn't run the token controller, issue the token by hand
+		token.Data = map[string][]byte{corev1.ServiceAccountTokenKey: []byte("first")}
+		Expect(c.Update(ctx, token)).To(Succeed())
+
+		mirror := &corev1.Secret{}
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKey{Namespace: consumer.Name, Name: "api-token"}, mirror)).To(Succeed())
+			g.Expect(mirror.Data).To(HaveKeyWithValue(corev1.ServiceAccountTokenKey, []byte("first")))
+		}).Should(Succeed())
+		Expect(errors.IsNotFound(c.Get(ctx, client.ObjectKey{Namespace: unwilling.Name, Name: accessToken.Name}, &corev1.Secret{}))).To(BeTrue())
+
+		By("keeping the mirror in sync as the token rotates")
+		token.Data = map[string][]byte{corev1.ServiceAccountTokenKey: []byte("second")}
+		Expect(c.Update(ctx, token)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(mirror), mirror)).To(Succeed())
+			g.Expect(mirror.Data).To(HaveKeyWithValue(corev1.ServiceAccountTokenKey, []byte("second")))
+		}).Should(Succeed())
+
+		By("removing the mirror on deletion")
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(mirror), &corev1.Secret{}))).To(BeTrue())
+		}).Should(Succeed())
+	})
+
+	It("should refuse targets naming Secrets that aren't its mirrors", func() {
+		existing := &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "database-credentials",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{"password": []byte("hunter2")},
+		}
+		Expect(c.Create(ctx, existing)).To(Succeed())
+
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "colliding",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				SecretTargets: []v1alpha1.SecretTarget{
+					{Namespace: "default", Name: existing.Name},
+					{Namespace: "default"},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		token := &corev1.Secret{}
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), token)).To(Succeed())
+		}).Should(Succeed())
+		token.Data = map[string][]byte{corev1.ServiceAccountTokenKey: []byte("token")}
+		Expect(c.Update(ctx, token)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypeSecretsMirrored).Reason).To(Equal(v1alpha1.ReasonSecretTargetsConflict))
+			g.Expect(actual.GetCondition(v1alpha1.TypeSecretsMirrored).Message).To(ContainSubstring("default/database-credentials"))
+			g.Expect(actual.GetCondition(v1alpha1.TypeSecretsMirrored).Message).To(ContainSubstring("default/colliding"))
+		}).Should(Succeed())
+
+		Consistently(func(g Gomega) {
+			actual := &corev1.Secret{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(existing), actual)).To(Succeed())
+			g.Expect(actual.Data).To(Equal(existing.Data))
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(token), actual)).To(Succeed())
+			g.Expect(actual.Type).To(Equal(corev1.SecretTypeServiceAccountToken))
+		}, "2s").Should(Succeed())
+
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+	})
+})
+
+var _ = Describe("Delivery", func() {
+	It("should deliver the token encrypted to the public key", func() {
+		privateKey, publicKey, err := tokencrypt.GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "delivered",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				Delivery: &v1alpha1.Delivery{PublicKey: publicKey},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		actual := &v1alpha1.AccessToken{}
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypeTokenDelivered).Status).To(Equal(corev1.ConditionTrue))
+			g.Expect(actual.Status.EncryptedToken).ToNot(BeEmpty())
+			g.Expect(actual.Status.EncryptedTokenPublicKey).To(Equal(publicKey))
+			g.Expect(actual.Status.EncryptedTokenIssueTimestamp).ToNot(BeNil())
+			g.Expect(actual.Status.EncryptedTokenExpirationTimestamp).ToNot(BeNil())
+			g.Expect(actual.Status.EncryptedTokenExpirationTimestamp.After(actual.Status.EncryptedTokenIssueTimestamp.Time)).To(BeTrue())
+			g.Expect(actual.Status.TokenSecretRef).To(BeNil())
+		}).Should(Succeed())
+
+		token, err := tokencrypt.Decrypt(privateKey, actual.Status.EncryptedToken)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token).ToNot(BeEmpty())
+
+		By("never storing the token in a Secret")
+		Expect(errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(accessToken), &corev1.Secret{}))).To(BeTrue())
+
+		By("reissuing the token when the public key changes")
+		otherPrivateKey, otherPublicKey, err := tokencrypt.GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+		patched := actual.DeepCopy()
+		patched.Spec.Delivery.PublicKey = otherPublicKey
+		Expect(c.Patch(ctx, patched, client.MergeFrom(actual))).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.Status.EncryptedTokenPublicKey).To(Equal(otherPublicKey))
+			_, err := tokencrypt.Decrypt(otherPrivateKey, actual.Status.EncryptedToken)
+			g.Expect(err).ToNot(HaveOccurred())
+		}).Should(Succeed())
+
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+	})
+
+	It("should report delivery combined with secret targets without retrying", func() {
+		_, publicKey, err := tokencrypt.GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "delivered-and-mirrored",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				Delivery:      &v1alpha1.Delivery{PublicKey: publicKey},
+				SecretTargets: []v1alpha1.SecretTarget{{Namespace: "kube-public"}},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypeTokenDelivered).Status).To(Equal(corev1.ConditionFalse))
+			g.Expect(actual.GetCondition(v1alpha1.TypeTokenDelivered).Reason).To(Equal(v1alpha1.ReasonDeliveryConflict))
+			g.Expect(actual.GetCondition(v1alpha1.TypeTokenDelivered).Message).To(ContainSubstring("spec.secretTargets"))
+			g.Expect(actual.Status.EncryptedToken).To(BeEmpty())
+
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), &corev1.ServiceAccount{})).To(Succeed())
+		}).Should(Succeed())
+
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+	})
+})
+
+var _ = Describe("Deny list", func() {
+	It("should refuse denied rules and provision the rest", func() {
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "denied",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				ClusterPermissions: &v1alpha1.ClusterPermissions{
+					Rules: []rbacv1.PolicyRule{
+						{
+							APIGroups: []string{""},
+							Resources: []string{"nodes"},
+							Verbs:     []string{"get"},
+						},
+						{
+							APIGroups: []string{""},
+							Resources: []string{"namespaces"},
+							Verbs:     []string{"get"},
+						},
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypePermissionsAllowed).Status).To(Equal(corev1.ConditionFalse))
+			g.Expect(actual.GetCondition(v1alpha1.TypePermissionsAllowed).Reason).To(Equal(v1alpha1.ReasonPermissionsDenied))
+			g.Expect(actual.GetCondition(v1alpha1.TypePermissionsAllowed).Message).To(ContainSubstring("spec.clusterPermissions.rules[0]"))
+
+			clusterRole := &rbacv1.ClusterRole{}
+			g.Expect(c.Get(ctx, client.ObjectKey{Name: fmt.Sprintf("%s-%s", accessToken.Name, accessToken.Namespace)}, clusterRole)).To(Succeed())
+			g.Expect(clusterRole.Rules).To(Equal(accessToken.Spec.ClusterPermissions.Rules[1:]))
+		}).Should(Succeed())
+
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+	})
+})
+
+var _ = Describe("Permission sets", func() {
+	It("should grant the rules of referenced permission sets", func() {
+		permissionSet := &v1alpha1.PermissionSet{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "read-configmaps",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.PermissionSetSpec{
+				Rules: []rbacv1.PolicyRule{
+					{
+						APIGroups: []string{""},
+						Resources: []string{"configmaps"},
+						Verbs:     []string{"get"},
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, permissionSet)).To(Succeed())
+
+		clusterPermissionSet := &v1alpha1.ClusterPermissionSet{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "read-namespaces",
+			},
+			Spec: v1alpha1.PermissionSetSpec{
+				Rules: []rbacv1.PolicyRule{
+					{
+						APIGroups: []string{""},
+						Resources: []string{"namespaces"},
+						Verbs:     []string{"get"},
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterPermissionSet)).To(Succeed())
+
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "permission-sets",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+					{
+						Namespace:         "default",
+						PermissionSetRefs: []v1alpha1.PermissionSetRef{{Name: permissionSet.Name}},
+					},
+				},
+				ClusterPermissions: &v1alpha1.ClusterPermissions{
+					PermissionSetRefs: []v1alpha1.PermissionSetRef{
+						{Kind: v1alpha1.ClusterPermissionSetKind, Name: clusterPermissionSet.Name},
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		role := &rbacv1.Role{}
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), role)).To(Succeed())
+			g.Expect(role.Rules).To(Equal(permissionSet.Spec.Rules))
+
+			clusterRole := &rbacv1.ClusterRole{}
+			g.Expect(c.Get(ctx, client.ObjectKey{Name: fmt.Sprintf("%s-%s", accessToken.Name, accessToken.Namespace)}, clusterRole)).To(Succeed())
+			g.Expect(clusterRole.Rules).To(Equal(clusterPermissionSet.Spec.Rules))
+		}).Should(Succeed())
+
+		By("re-reconciling when a permission set changes")
+		patched := permissionSet.DeepCopy()
+		patched.Spec.Rules[0].Verbs = []string{"get", "list"}
+		Expect(c.Patch(ctx, patched, client.MergeFrom(permissionSet))).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), role)).To(Succeed())
+			g.Expect(role.Rules).To(Equal(patched.Spec.Rules))
+		}).Should(Succeed())
+
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+	})
+})
+
+var _ = Describe("Templates", func() {
+	It("should grant the expanded permissions of the referenced template", func() {
+		template := &v1alpha1.AccessTokenTemplate{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "deployer",
+			},
+			Spec: v1alpha1.AccessTokenTemplateSpec{
+				Parameters: []v1alpha1.TemplateParameter{{Name: "app"}},
+				NamespacedPermissions: []v1alpha1.TemplatedPermissions{
+					{
+						Namespace: "${namespace}",
+						Rules: []rbacv1.PolicyRule{
+							{
+								APIGroups:     []string{"apps"},
+								Resources:     []string{"deployments"},
+								ResourceNames: []string{"${app}"},
+								Verbs:         []string{"get"},
+							},
+						},
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, template)).To(Succeed())
+
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "templated",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				TemplateRef: &v1alpha1.TemplateRef{
+					Name:       template.Name,
+					Parameters: map[string]string{"app": "web"},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			role := &rbacv1.Role{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), role)).To(Succeed())
+			g.Expect(role.Rules).To(Equal([]rbacv1.PolicyRule{
+				{
+					APIGroups:     []string{"apps"},
+					Resources:     []string{"deployments"},
+					ResourceNames: []string{"web"},
+					Verbs:         []string{"get"},
+				},
+			}))
+		}).Should(Succeed())
+
+		By("refusing invalid parameters")
+		patched := accessToken.DeepCopy()
+		patched.Spec.TemplateRef.Parameters["app"] = "*"
+		Expect(c.Patch(ctx, patched, client.MergeFrom(accessToken))).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypeTokenProvisioned).Status).ToNot(Equal(corev1.ConditionTrue))
+			g.Expect(actual.GetCondition(v1alpha1.TypeTokenProvisioned).Message).To(ContainSubstring(`parameter "app"`))
+		}).Should(Succeed())
+
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+	})
+
+	It("should evaluate deny assertions in the namespaces reached through the template", func() {
+		template := &v1alpha1.AccessTokenTemplate{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "configmap-reader",
+			},
+			Spec: v1alpha1.AccessTokenTemplateSpec{
+				NamespacedPermissions: []v1alpha1.TemplatedPermissions{
+					{
+						Namespace: "${namespace}",
+						Rules: []rbacv1.PolicyRule{
+							{
+								APIGroups: []string{""},
+								Resources: []string{"configmaps"},
+								Verbs:     []string{"get"},
+							},
+						},
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, template)).To(Succeed())
+
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "templated-assertions",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				TemplateRef: &v1alpha1.TemplateRef{Name: template.Name},
+				Assertions: &v1alpha1.Assertions{
+					Deny: []v1alpha1.AccessAssertion{{Verb: "get", Resource: "configmaps"}},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.Status.AssertionResults).To(HaveLen(1))
+			g.Expect(actual.Status.AssertionResults[0].Satisfied).To(BeFalse())
+			g.Expect(actual.Status.AssertionResults[0].Message).To(ContainSubstring("default"))
+			g.Expect(actual.GetCondition(v1alpha1.TypeAssertionsSatisfied).Status).ToNot(Equal(corev1.ConditionTrue))
+		}).Should(Succeed())
+
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+	})
+
+	It("should grant permissions in namespaces reached through the template once they consent", func() {
+		target := &corev1.Namespace{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "templated-target",
+			},
+		}
+		Expect(c.Create(ctx, target)).To(Succeed())
+
+		template := &v1alpha1.AccessTokenTemplate{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "cross-namespace",
+			},
+			Spec: v1alpha1.AccessTokenTemplateSpec{
+				Parameters: []v1alpha1.TemplateParameter{{Name: "team", AllowedValues: []string{"target"}}},
+				NamespacedPermissions: []v1alpha1.TemplatedPermissions{
+					{
+						Namespace: "templated-${team}",
+						Rules: []rbacv1.PolicyRule{
+							{
+								APIGroups: []string{""},
+								Resources: []string{"configmaps"},
+								Verbs:     []string{"get"},
+							},
+						},
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, template)).To(Succeed())
+
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "templated-cross-namespace",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				TemplateRef: &v1alpha1.TemplateRef{
+					Name:       template.Name,
+					Parameters: map[string]string{"team": "target"},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		roleKey := client.ObjectKey{Namespace: target.Name, Name: accessToken.Name}
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.GetCondition(v1alpha1.TypeNamespacesConsented).Message).To(ContainSubstring(target.Name))
+		}).Should(Succeed())
+		Expect(errors.IsNotFound(c.Get(ctx, roleKey, &rbacv1.Role{}))).To(BeTrue())
+
+		By("reconciling the token when the namespace consents")
+		patched := target.DeepCopy()
+		patched.SetAnnotations(map[string]string{v1alpha1.AnnotationAllowedSourceNamespaces: "default"})
+		Expect(c.Patch(ctx, patched, client.MergeFrom(target))).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, roleKey, &rbacv1.Role{})).To(Succeed())
+		}).Should(Succeed())
+
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+	})
+})
+
+var _ = Describe("Remote clusters", func() {
+	// startCluster starts an API server and returns an admin client for it along with a kubeconfig Secret in the
+	// default namespace reaching it.
+	startCluster := func(name string) (client.Client, *corev1.Secret) {
+		env := &envtest.Environment{}
+		cfg, err := env.Start()
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(env.Stop)
+
+		remote, err := client.New(cfg, client.Options{Scheme: scheme})
+		Expect(err).ToNot(HaveOccurred())
+
+		admin, err := env.AddUser(envtest.User{Name: "remote-admin", Groups: []string{"system:masters"}}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		kubeconfig, err := admin.KubeConfig()
+		Expect(err).ToNot(HaveOccurred())
+
+		secret := &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name + "-kubeconfig",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{"kubeconfig": kubeconfig},
+		}
+		Expect(c.Create(ctx, secret)).To(Succeed())
+		return remote, secret
+	}
+
+	It("should provision each cluster according to its own consent and clean up removed clusters", func() {
+		east, eastKubeconfig := startCluster("east")
+		west, westKubeconfig := startCluster("west")
+
+		// team-b consents in east only, protected consents everywhere but is refused by the deny list
+		for _, remote := range []client.Client{east, west} {
+			Expect(remote.Create(ctx, &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "team-b"}})).To(Succeed())
+			Expect(remote.Create(ctx, &corev1.Namespace{ObjectMeta: v1.ObjectMeta{
+				Name:        "protected",
+				Annotations: map[string]string{v1alpha1.AnnotationAllowedSourceNamespaces: "*"},
+			}})).To(Succeed())
+		}
+		teamB := &corev1.Namespace{}
+		Expect(east.Get(ctx, client.ObjectKey{Name: "team-b"}, teamB)).To(Succeed())
+		teamB.Annotations = map[string]string{v1alpha1.AnnotationAllowedSourceNamespaces: "default"}
+		Expect(east.Update(ctx, teamB)).To(Succeed())
+
+		rules := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}}
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "multi",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+					{Namespace: "team-b", Rules: rules},
+					{Namespace: "protected", Rules: rules},
+				},
+				TargetClusters: []v1alpha1.TargetCluster{
+					{Name: "east", KubeconfigSecretRef: v1alpha1.SecretKeyRef{Name: eastKubeconfig.Name}},
+					{Name: "west", KubeconfigSecretRef: v1alpha1.SecretKeyRef{Name: westKubeconfig.Name}},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		By("issuing the token in both clusters")
+		for _, remote := range []client.Client{east, west} {
+			remoteSecret := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(remote.Get(ctx, client.ObjectKeyFromObject(accessToken), remoteSecret)).To(Succeed())
+			}).Should(Succeed())
+			// envtest doesn't run the token controller, issue the token by hand
+			remoteSecret.Data = map[string][]byte{corev1.ServiceAccountTokenKey: []byte("remote-token")}
+			Expect(remote.Update(ctx, remoteSecret)).To(Succeed())
+		}
+
+		By("granting permissions only where the cluster's namespace consents")
+		roleKey := client.ObjectKey{Namespace: "team-b", Name: accessToken.Name}
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.Status.Clusters).To(HaveLen(2))
+			g.Expect(actual.Status.Clusters).To(HaveEach(HaveField("Ready", BeTrue())))
+			g.Expect(actual.Status.Clusters[1].Message).To(ContainSubstring("team-b"))
+
+			g.Expect(east.Get(ctx, roleKey, &rbacv1.Role{})).To(Succeed())
+			g.Expect(errors.IsNotFound(west.Get(ctx, roleKey, &rbacv1.Role{}))).To(BeTrue())
+		}).Should(Succeed())
+
+		By("refusing namespaces on the deny list in every cluster")
+		protectedKey := client.ObjectKey{Namespace: "protected", Name: accessToken.Name}
+		Expect(errors.IsNotFound(east.Get(ctx, protectedKey, &rbacv1.Role{}))).To(BeTrue())
+		Expect(errors.IsNotFound(west.Get(ctx, protectedKey, &rbacv1.Role{}))).To(BeTrue())
+
+		By("cleaning up clusters removed from the spec")
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			actual.Spec.TargetClusters = actual.Spec.TargetClusters[:1]
+			g.Expect(c.Update(ctx, actual)).To(Succeed())
+		}).Should(Succeed())
+		Eventually(func(g Gomega) {
+			err := west.Get(ctx, client.ObjectKeyFromObject(accessToken), &corev1.ServiceAccount{})
+			g.Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.Status.Clusters).To(HaveLen(1))
+		}).Should(Succeed())
+		Expect(east.Get(ctx, client.ObjectKeyFromObject(accessToken), &corev1.ServiceAccount{})).To(Succeed())
+
+		By("revoking the remaining cluster's token on deletion")
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+		Eventually(func(g Gomega) {
+			err := east.Get(ctx, client.ObjectKeyFromObject(accessToken), &corev1.ServiceAccount{})
+			g.Expect(errors.IsNotFound(err)).To(BeTrue())
+		}).Should(Succeed())
+	})
+
+	It("should orphan a cluster's objects on deletion once its kubeconfig Secret is gone", func() {
+		south, southKubeconfig := startCluster("south")
+
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "orphan",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				TargetClusters: []v1alpha1.TargetCluster{
+					{Name: "south", KubeconfigSecretRef: v1alpha1.SecretKeyRef{Name: southKubeconfig.Name}},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(south.Get(ctx, client.ObjectKeyFromObject(accessToken), &corev1.ServiceAccount{})).To(Succeed())
+		}).Should(Succeed())
+
+		By("deleting the AccessToken after its kubeconfig Secret")
+		Expect(c.Delete(ctx, southKubeconfig)).To(Succeed())
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+		Eventually(func(g Gomega) {
+			err := c.Get(ctx, client.ObjectKeyFromObject(accessToken), &v1alpha1.AccessToken{})
+			g.Expect(errors.IsNotFound(err)).To(BeTrue())
+		}).Should(Succeed())
+
+		By("leaving the remote objects behind")
+		Expect(south.Get(ctx, client.ObjectKeyFromObject(accessToken), &corev1.ServiceAccount{})).To(Succeed())
+	})
+
+	It("should refuse kubeconfigs that aren't self-contained", func() {
+		kubeconfigSecret := &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "exec-kubeconfig",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{"kubeconfig": []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: https://remote.example.com
+users:
+- name: remote
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: sh
+      args: ["-c", "touch /tmp/pwned"]
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: remote
+current-context: remote
+`)},
+		}
+		Expect(c.Create(ctx, kubeconfigSecret)).To(Succeed())
+
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "exec",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				TargetClusters: []v1alpha1.TargetCluster{
+					{Name: "remote", KubeconfigSecretRef: v1alpha1.SecretKeyRef{Name: kubeconfigSecret.Name}},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.Status.Clusters).To(HaveLen(1))
+			g.Expect(actual.Status.Clusters[0].Ready).To(BeFalse())
+			g.Expect(actual.Status.Clusters[0].Message).To(ContainSubstring("exec plugins aren't allowed"))
+		}).Should(Succeed())
+	})
+
+	It("should provision the token in target clusters and collect it locally", func() {
+		By("starting a second API server")
+		remoteEnv := &envtest.Environment{}
+		remoteCfg, err := remoteEnv.Start()
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(remoteEnv.Stop)
+
+		remote, err := client.New(remoteCfg, client.Options{Scheme: scheme})
+		Expect(err).ToNot(HaveOccurred())
+
+		admin, err := remoteEnv.AddUser(envtest.User{Name: "remote-admin", Groups: []string{"system:masters"}}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		kubeconfig, err := admin.KubeConfig()
+		Expect(err).ToNot(HaveOccurred())
+
+		kubeconfigSecret := &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "remote-kubeconfig",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{"kubeconfig": kubeconfig},
+		}
+		Expect(c.Create(ctx, kubeconfigSecret)).To(Succeed())
+
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "remote",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.AccessTokenSpec{
+				NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+					{
+						Namespace: "default",
+						Rules: []rbacv1.PolicyRule{
+							{
+								APIGroups: []string{""},
+								Resources: []string{"configmaps"},
+								Verbs:     []string{"get"},
+							},
+						},
+					},
+				},
+				TargetClusters: []v1alpha1.TargetCluster{
+					{
+						Name:                "east",
+						KubeconfigSecretRef: v1alpha1.SecretKeyRef{Name: kubeconfigSecret.Name},
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, accessToken)).To(Succeed())
+
+		By("applying the token's objects in the remote cluster")
+		remoteSecret := &corev1.Secret{}
+		Eventually(func(g Gomega) {
+			g.Expect(remote.Get(ctx, client.ObjectKeyFromObject(accessToken), &corev1.ServiceAccount{})).To(Succeed())
+			g.Expect(remote.Get(ctx, client.ObjectKeyFromObject(accessToken), &rbacv1.Role{})).To(Succeed())
+			g.Expect(remote.Get(ctx, client.ObjectKeyFromObject(accessToken), &rbacv1.RoleBinding{})).To(Succeed())
+			g.Expect(remote.Get(ctx, client.ObjectKeyFromObject(accessToken), remoteSecret)).To(Succeed())
+		}).Should(Succeed())
+
+		By("reporting the cluster as not ready until it issues the token")
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.Status.Clusters).To(HaveLen(1))
+			g.Expect(actual.Status.Clusters[0].Ready).To(BeFalse())
+			g.Expect(actual.GetCondition(v1alpha1.TypeRemoteTokensProvisioned).Status).ToNot(Equal(corev1.ConditionTrue))
+		}).Should(Succeed())
+
+		// envtest doesn't run the token controller, issue the token by hand
+		remoteSecret.Data = map[string][]byte{corev1.ServiceAccountTokenKey: []byte("remote-token")}
+		Expect(remote.Update(ctx, remoteSecret)).To(Succeed())
+
+		By("collecting the remote token into a local Secret")
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessToken{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(accessToken), actual)).To(Succeed())
+			g.Expect(actual.Status.Clusters).To(HaveLen(1))
+			g.Expect(actual.Status.Clusters[0].Ready).To(BeTrue())
+			g.Expect(actual.Status.Clusters[0].TokenSecretRef).To(Equal(ptr.To("remote-east")))
+			g.Expect(actual.GetCondition(v1alpha1.TypeRemoteTokensProvisioned).Status).To(Equal(corev1.ConditionTrue))
+
+			local := &corev1.Secret{}
+			g.Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "remote-east"}, local)).To(Succeed())
+			g.Expect(local.Labels).To(HaveKeyWithValue(v1alpha1.LabelTargetCluster, "east"))
+			g.Expect(local.Data).To(HaveKeyWithValue(corev1.ServiceAccountTokenKey, []byte("remote-token")))
+			g.Expect(local.Data).To(HaveKey("kubeconfig"))
+		}).Should(Succeed())
+
+		By("revoking the remote token on deletion")
+		Expect(c.Delete(ctx, accessToken)).To(Succeed())
+		Eventually(func(g Gomega) {
+			err := remote.Get(ctx, client.ObjectKeyFromObject(accessToken), &corev1.ServiceAccount{})
+			g.Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = remote.Get(ctx, client.ObjectKeyFromObject(accessToken), &rbacv1.Role{})
+			g.Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = c.Get(ctx, client.ObjectKeyFromObject(accessToken), &v1alpha1.AccessToken{})
+			g.Expect(errors.IsNotFound(err)).To(BeTrue())
+		}).Should(Succeed())
+	})
+})
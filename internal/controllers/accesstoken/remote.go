@@ -0,0 +1,363 @@
+package accesstoken
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-sdk/pkg/fsm/types"
+	"github.com/reddit/achilles-sdk/pkg/io"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/kubeconfig"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const defaultKubeconfigKey = "kubeconfig"
+
+// remoteCluster is a client for a target cluster along with the address it was reached at.
+type remoteCluster struct {
+	client.Client
+	server string
+	caData []byte
+}
+
+// remoteCluster builds a client for the cluster whose kubeconfig is held by the Secret ref in the AccessToken's namespace.
+func (r *reconciler) remoteCluster(ctx context.Context, accessToken *v1alpha1.AccessToken, ref v1alpha1.SecretKeyRef) (*remoteCluster, error) {
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: accessToken.GetNamespace(), Name: ref.Name}
+	if err := r.c.Get(ctx, key, secret); err != nil {
+		return nil, fmt.Errorf("getting kubeconfig %T %s: %w", secret, key, err)
+	}
+
+	dataKey := ref.Key
+	if dataKey == "" {
+		dataKey = defaultKubeconfigKey
+	}
+	data, ok := secret.Data[dataKey]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig %T %s has no %q key", secret, key, dataKey)
+	}
+
+	// NOTE: the kubeconfig is authored by whoever may write Secrets in the AccessToken's namespace, it must not make
+	// the controller run commands or read its own files
+	cfg, err := kubeconfig.InlineRESTConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig %T %s: %w", secret, key, err)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: r.scheme})
+	if err != nil {
+		return nil, fmt.Errorf("building client for %s: %w", cfg.Host, err)
+	}
+
+	return &remoteCluster{Client: c, server: cfg.Host, caData: cfg.CAData}, nil
+}
+
+// remoteTokenSecret returns the local Secret collecting the token issued to the AccessToken by the named cluster.
+func (b *builder) remoteTokenSecret(cluster string) *corev1.Secret {
+	labels := b.labels()
+	labels[v1alpha1.LabelTargetCluster] = cluster
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", b.accessToken.GetName(), cluster),
+			Namespace: b.accessToken.GetNamespace(),
+			Labels:    labels,
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+}
+
+// remoteTokenSecrets returns the local Secrets for every target cluster, they're managed alongside the objects returned
+// by build().
+func (b *builder) remoteTokenSecrets() []client.Object {
+	var objs []client.Object
+	for _, cluster := range b.accessToken.Spec.TargetClusters {
+		objs = append(objs, b.remoteTokenSecret(cluster.Name))
+	}
+	return objs
+}
+
+// provisionRemoteTokens applies the objects built for the AccessToken, given with references resolved, in every target
+// cluster and collects the tokens the clusters issue into local Secrets. Clusters removed from `spec.targetClusters` are
+// cleaned up.
+func (r *reconciler) provisionRemoteTokens(resolved *v1alpha1.AccessToken, nextState *state) *state {
+	return &state{
+		Name:      "provision-remote-tokens",
+		Condition: conditionRemoteTokensProvisioned,
+		Transition: func(
+			ctx context.Context,
+			accessToken *v1alpha1.AccessToken,
+			out *types.OutputSet,
+		) (*state, types.Result) {
+			inSpec := map[string]bool{}
+			for _, cluster := range accessToken.Spec.TargetClusters {
+				inSpec[cluster.Name] = true
+			}
+
+			// clean up clusters no longer targeted, they're only known from the status
+			var failed []string
+			for _, status := range accessToken.Status.Clusters {
+				if inSpec[status.Name] {
+					continue
+				}
+				if _, err := r.revokeRemoteToken(ctx, accessToken, status.Name, status.KubeconfigSecretRef); err != nil {
+					return nil, types.ErrorResultf("cleaning up removed cluster %q: %s", status.Name, err)
+				}
+			}
+
+			statuses := make([]v1alpha1.ClusterStatus, 0, len(accessToken.Spec.TargetClusters))
+			for _, cluster := range accessToken.Spec.TargetClusters {
+				status := v1alpha1.ClusterStatus{Name: cluster.Name, KubeconfigSecretRef: cluster.KubeconfigSecretRef}
+
+				secret, unconsented, err := r.provisionRemoteToken(ctx, resolved, cluster)
+				if err != nil {
+					status.Message = err.Error()
+					failed = append(failed, fmt.Sprintf("%s: %s", cluster.Name, err))
+				} else {
+					out.Apply(secret)
+					status.Ready = true
+					status.TokenSecretRef = ptr.To(secret.GetName())
+					if len(unconsented) > 0 {
+						status.Message = fmt.Sprintf("Permissions in namespaces %s are not granted, they don't consent in the cluster", strings.Join(unconsented, ", "))
+					}
+				}
+
+				statuses = append(statuses, status)
+			}
+			accessToken.Status.Clusters = statuses
+
+			if len(failed) > 0 {
+				return nil, types.ErrorResultf("provisioning token in target clusters: %s", strings.Join(failed, "; "))
+			}
+
+			return nextState, types.DoneResult()
+		},
+	}
+}
+
+// provisionRemoteToken applies the objects built for the AccessToken in the cluster, deletes the ones no longer
+// desired, and returns the local Secret holding the token the cluster issued. Consent is checked against the
+// namespaces of the cluster, and the deny list applies as it does locally. The namespaces that don't consent are
+// returned, their permissions are skipped.
+func (r *reconciler) provisionRemoteToken(
+	ctx context.Context,
+	resolved *v1alpha1.AccessToken,
+	cluster v1alpha1.TargetCluster,
+) (*corev1.Secret, []string, error) {
+	remote, err := r.remoteCluster(ctx, resolved, cluster.KubeconfigSecretRef)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unconsented, err := r.unconsentedNamespaces(ctx, remote, resolved)
+	if err != nil {
+		return nil, nil, err
+	}
+	b := newBuilder(resolved).withoutNamespaces(unconsented...).withDenyList(r.denyList)
+
+	// NOTE: objects in remote clusters never have owner references, the finalizer deletes them
+	desired := b.build()
+	grants, err := grantRecords(ctx, remote, cluster.Name, resolved, desired)
+	if err != nil {
+		return nil, nil, err
+	}
+	applicator := io.NewAPIPatchingApplicator(remote)
+	for _, o := range desired {
+		if err := applicator.Apply(ctx, o); err != nil {
+			return nil, nil, fmt.Errorf("applying %T %s: %w", o, client.ObjectKeyFromObject(o), err)
+		}
+	}
+	if err := r.logGrants(ctx, grants); err != nil {
+		return nil, nil, err
+	}
+
+	if err := r.deleteRemoteObjects(ctx, remote, cluster.Name, resolved, desired); err != nil {
+		return nil, nil, err
+	}
+
+	remoteSecret := b.secret()
+	if err := remote.Get(ctx, client.ObjectKeyFromObject(remoteSecret), remoteSecret); err != nil {
+		return nil, nil, fmt.Errorf("getting token %T %s: %w", remoteSecret, client.ObjectKeyFromObject(remoteSecret), err)
+	}
+	token, err := kubeconfig.TokenFromSecret(remoteSecret, remote.server, remote.caData)
+	if err != nil {
+		return nil, nil, err
+	}
+	token.Namespace = resolved.GetNamespace()
+
+	kcfg, err := clientcmd.Write(*kubeconfig.New(cluster.Name, token))
+	if err != nil {
+		return nil, nil, fmt.Errorf("writing kubeconfig: %w", err)
+	}
+
+	secret := b.remoteTokenSecret(cluster.Name)
+	secret.Data = map[string][]byte{
+		corev1.ServiceAccountTokenKey:     []byte(token.Token),
+		corev1.ServiceAccountRootCAKey:    token.CAData,
+		corev1.ServiceAccountNamespaceKey: []byte(token.Namespace),
+		defaultKubeconfigKey:              kcfg,
+	}
+	return secret, unconsented, nil
+}
+
+// unconsentedNamespaces returns the namespaces targeted by the AccessToken, given with references resolved, that don't
+// consent to grants from its namespace in the remote cluster. Consent granted locally doesn't carry over to other
+// clusters.
+func (r *reconciler) unconsentedNamespaces(ctx context.Context, remote client.Reader, resolved *v1alpha1.AccessToken) ([]string, error) {
+	if !r.requireConsent {
+		return nil, nil
+	}
+	var missing []string
+	for _, p := range resolved.Spec.NamespacedPermissions {
+		if slices.Contains(missing, p.Namespace) {
+			continue
+		}
+		ok, err := consents(ctx, remote, p.Namespace, resolved.GetNamespace())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			missing = append(missing, p.Namespace)
+		}
+	}
+	return missing, nil
+}
+
+// deleteRemoteObjects deletes the objects labeled as provisioned for the AccessToken in the named remote cluster that
+// aren't desired. The revocation of the rules of deleted roles is audited.
+func (r *reconciler) deleteRemoteObjects(
+	ctx context.Context,
+	remote client.Client,
+	cluster string,
+	accessToken *v1alpha1.AccessToken,
+	desired []client.Object,
+) error {
+	keep := map[string]bool{}
+	for _, o := range desired {
+		keep[remoteObjectKey(o)] = true
+	}
+
+	lists := []client.ObjectList{
+		&corev1.SecretList{},
+		&corev1.ServiceAccountList{},
+		&rbacv1.RoleList{},
+		&rbacv1.RoleBindingList{},
+		&rbacv1.ClusterRoleList{},
+		&rbacv1.ClusterRoleBindingList{},
+	}
+	selector := client.MatchingLabels(newBuilder(accessToken).labels())
+	for _, list := range lists {
+		if err := remote.List(ctx, list, selector); err != nil {
+			return fmt.Errorf("listing %T: %w", list, err)
+		}
+		items, err := apimeta.ExtractList(list)
+		if err != nil {
+			return fmt.Errorf("extracting %T: %w", list, err)
+		}
+		for _, item := range items {
+			o := item.(client.Object)
+			if keep[remoteObjectKey(o)] {
+				continue
+			}
+			if err := remote.Delete(ctx, o); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return fmt.Errorf("deleting %T %s: %w", o, client.ObjectKeyFromObject(o), err)
+			}
+			if err := r.auditRevocation(ctx, cluster, accessToken, o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// revokeRemoteToken deletes everything provisioned for the AccessToken in the named cluster reached through ref. If
+// the kubeconfig Secret is gone the cluster can't be reached anymore, its objects are orphaned and false is returned
+// so the caller can move on instead of retrying forever.
+func (r *reconciler) revokeRemoteToken(
+	ctx context.Context,
+	accessToken *v1alpha1.AccessToken,
+	cluster string,
+	ref v1alpha1.SecretKeyRef,
+) (bool, error) {
+	remote, err := r.remoteCluster(ctx, accessToken, ref)
+	if errors.IsNotFound(err) {
+		r.log.Warnf("orphaning objects provisioned for %T %s in cluster %q: %s",
+			accessToken, client.ObjectKeyFromObject(accessToken), cluster, err)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, r.deleteRemoteObjects(ctx, remote, cluster, accessToken, nil)
+}
+
+// deleteRemoteTokens deletes everything provisioned for the AccessToken in the target clusters. It runs in the
+// finalizer since remote objects can't be garbage collected through owner references. Clusters whose kubeconfig Secret
+// is gone are reported as orphaned on the RemoteTokensProvisioned condition and don't block deletion, clusters that
+// can't be reached do.
+func (r *reconciler) deleteRemoteTokens(nextState *state) *state {
+	return &state{
+		Name: "delete-remote-tokens",
+		Transition: func(
+			ctx context.Context,
+			accessToken *v1alpha1.AccessToken,
+			out *types.OutputSet,
+		) (*state, types.Result) {
+			refs := map[string]v1alpha1.SecretKeyRef{}
+			for _, status := range accessToken.Status.Clusters {
+				refs[status.Name] = status.KubeconfigSecretRef
+			}
+			for _, cluster := range accessToken.Spec.TargetClusters {
+				refs[cluster.Name] = cluster.KubeconfigSecretRef
+			}
+
+			condition := api.Condition{
+				Type:               v1alpha1.TypeRemoteTokensProvisioned,
+				Status:             corev1.ConditionFalse,
+				Reason:             v1alpha1.ReasonRemoteTokensRevoked,
+				Message:            "Access token has been revoked in all target clusters",
+				ObservedGeneration: accessToken.GetGeneration(),
+				LastTransitionTime: metav1.Now(),
+			}
+
+			var orphaned []string
+			for _, name := range slices.Sorted(maps.Keys(refs)) {
+				revoked, err := r.revokeRemoteToken(ctx, accessToken, name, refs[name])
+				if err != nil {
+					condition.Reason = v1alpha1.ReasonRemoteTokensNotRevoked
+					condition.Message = fmt.Sprintf("Revoking token in cluster %q: %s", name, err)
+					accessToken.SetConditions(condition)
+					return nil, types.ErrorResultf("revoking token in cluster %q: %s", name, err)
+				}
+				if !revoked {
+					orphaned = append(orphaned, name)
+				}
+			}
+			if len(orphaned) > 0 {
+				condition.Reason = v1alpha1.ReasonRemoteTokensOrphaned
+				condition.Message = fmt.Sprintf("Objects in clusters %s are left behind, their kubeconfig Secret is gone",
+					strings.Join(orphaned, ", "))
+			}
+			accessToken.SetConditions(condition)
+			accessToken.Status.Clusters = nil
+
+			return nextState, types.DoneResult()
+		},
+	}
+}
+
+func remoteObjectKey(o client.Object) string {
+	return fmt.Sprintf("%T/%s", o, client.ObjectKeyFromObject(o))
+}
@@ -0,0 +1,81 @@
+package accesstoken
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/tokenmetrics"
+	"github.com/reddit/achilles-token-controller/internal/tokentemplate"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// indexTemplateRef indexes AccessTokens by the name of the AccessTokenTemplate they reference.
+const indexTemplateRef = "accesstoken.spec.templateRef.name"
+
+// TemplateGetter returns the AccessTokenTemplate with the name.
+type TemplateGetter func(name string) (*v1alpha1.AccessTokenTemplate, error)
+
+// Resolver looks up the objects referenced by AccessTokens.
+type Resolver struct {
+	// Rules returns the rules of referenced permission sets.
+	Rules RulesGetter
+
+	// Template returns referenced AccessTokenTemplates.
+	Template TemplateGetter
+}
+
+// ClientResolver returns a Resolver reading referenced objects with the client.
+func ClientResolver(ctx context.Context, c client.Reader) Resolver {
+	return Resolver{
+		Rules: clientRulesGetter(ctx, c),
+		Template: func(name string) (*v1alpha1.AccessTokenTemplate, error) {
+			tmpl := &v1alpha1.AccessTokenTemplate{}
+			if err := c.Get(ctx, client.ObjectKey{Name: name}, tmpl); err != nil {
+				if errors.IsNotFound(err) {
+					return nil, fmt.Errorf("referenced AccessTokenTemplate %s not found", name)
+				}
+				return nil, fmt.Errorf("getting AccessTokenTemplate %s: %w", name, err)
+			}
+			return tmpl, nil
+		},
+	}
+}
+
+// ClientResolveFunc returns a tokenmetrics.ResolveFunc resolving AccessTokens with a ClientResolver reading with c.
+func ClientResolveFunc(c client.Reader) tokenmetrics.ResolveFunc {
+	return func(ctx context.Context, accessToken *v1alpha1.AccessToken) (*v1alpha1.AccessToken, error) {
+		return Resolve(accessToken, ClientResolver(ctx, c))
+	}
+}
+
+// Resolve returns a copy of the AccessToken with its references replaced by the permissions they stand for: the
+// expanded permissions of its template are added to its NamespacedPermissions, then the rules of its permission sets
+// are added to the permissions referencing them.
+func Resolve(accessToken *v1alpha1.AccessToken, r Resolver) (*v1alpha1.AccessToken, error) {
+	resolved := accessToken.DeepCopy()
+
+	if ref := resolved.Spec.TemplateRef; ref != nil {
+		tmpl, err := r.Template(ref.Name)
+		if err != nil {
+			return nil, err
+		}
+		permissions, err := tokentemplate.Expand(tmpl.Spec, accessToken.GetNamespace(), accessToken.GetName(), ref.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("expanding AccessTokenTemplate %s: %w", ref.Name, err)
+		}
+		resolved.Spec.NamespacedPermissions = append(resolved.Spec.NamespacedPermissions, permissions...)
+	}
+
+	return resolvePermissionSets(resolved, r.Rules)
+}
+
+// referencedTemplate is the index function of indexTemplateRef.
+func referencedTemplate(o client.Object) []string {
+	accessToken, ok := o.(*v1alpha1.AccessToken)
+	if !ok || accessToken.Spec.TemplateRef == nil {
+		return nil
+	}
+	return []string{accessToken.Spec.TemplateRef.Name}
+}
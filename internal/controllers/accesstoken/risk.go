@@ -0,0 +1,88 @@
+package accesstoken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/risk"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// riskFinding is the representation of a risk.Finding in the AnnotationRiskFindings annotation.
+type riskFinding struct {
+	ID       string        `json:"id"`
+	Severity risk.Severity `json:"severity"`
+	Path     string        `json:"path"`
+}
+
+// classifyRisk surfaces the rules of the AccessToken, given by spec with permission sets resolved, matching the risk
+// catalog through the HighRiskPermissions condition and the AnnotationRiskFindings annotation. The classification is
+// informational and never blocks provisioning.
+func (r *reconciler) classifyRisk(ctx context.Context, accessToken *v1alpha1.AccessToken, spec v1alpha1.AccessTokenSpec) error {
+	findings := risk.Classify(spec)
+
+	condition := api.Condition{
+		Type:               v1alpha1.TypeHighRiskPermissions,
+		Status:             corev1.ConditionFalse,
+		Reason:             v1alpha1.ReasonNoHighRiskPermissions,
+		ObservedGeneration: accessToken.GetGeneration(),
+		LastTransitionTime: metav1.Now(),
+	}
+	var highRisk []string
+	for _, f := range findings {
+		if f.Severity >= risk.SeverityHigh {
+			highRisk = append(highRisk, f.String())
+		}
+	}
+	if len(highRisk) > 0 {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = v1alpha1.ReasonHighRiskPermissionsGranted
+		condition.Message = fmt.Sprintf("Token is granted high risk permissions (see the %s annotation): %s",
+			v1alpha1.AnnotationRiskFindings, strings.Join(highRisk, "; "))
+	}
+	accessToken.SetConditions(condition)
+
+	var annotation string
+	if len(findings) > 0 {
+		summary := make([]riskFinding, 0, len(findings))
+		for _, f := range findings {
+			summary = append(summary, riskFinding{ID: f.ID, Severity: f.Severity, Path: f.Path})
+		}
+		b, err := json.Marshal(summary)
+		if err != nil {
+			return fmt.Errorf("marshalling risk findings: %w", err)
+		}
+		annotation = string(b)
+	}
+
+	if accessToken.GetAnnotations()[v1alpha1.AnnotationRiskFindings] == annotation {
+		return nil
+	}
+
+	// patch a copy so that the in-memory status, which the FSM persists after the transition, is left untouched
+	patched := accessToken.DeepCopy()
+	annotations := patched.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if annotation == "" {
+		delete(annotations, v1alpha1.AnnotationRiskFindings)
+	} else {
+		annotations[v1alpha1.AnnotationRiskFindings] = annotation
+	}
+	patched.SetAnnotations(annotations)
+
+	if err := r.c.Patch(ctx, patched, client.MergeFrom(accessToken)); err != nil {
+		return fmt.Errorf("annotating %T %s with risk findings: %w", accessToken, client.ObjectKeyFromObject(accessToken), err)
+	}
+	accessToken.SetAnnotations(patched.GetAnnotations())
+	accessToken.SetResourceVersion(patched.GetResourceVersion())
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package accesstoken
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-sdk/pkg/fsm/types"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/accessreview"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// verifyRetryDelay is how long to wait before verifying permissions the API server didn't grant again. Denials right
+// after the bindings are applied are usually caused by the authorizer's RBAC cache lagging behind.
+const verifyRetryDelay = 30 * time.Second
+
+// verifyPermissions runs SubjectAccessReviews as the token's ServiceAccount for a sample of every desired rule and
+// reports the rules the API server doesn't grant through the PermissionsVerified condition. Denials don't block the
+// following states, they're verified again after verifyRetryDelay, see verificationHandler.
+func (r *reconciler) verifyPermissions(desiredObjs []client.Object, nextState *state) *state {
+	return &state{
+		Name: "verify-permissions",
+		Transition: func(
+			ctx context.Context,
+			accessToken *v1alpha1.AccessToken,
+			out *types.OutputSet,
+		) (*state, types.Result) {
+			var checks []accessreview.Check
+			for _, o := range desiredObjs {
+				for _, rule := range rulesOf(o) {
+					checks = append(checks, accessreview.SampleRule(rule, o.GetNamespace())...)
+				}
+			}
+
+			sa := newBuilder(accessToken).serviceAccount()
+			user := accessreview.ServiceAccountUser{Namespace: sa.GetNamespace(), Name: sa.GetName()}
+
+			results, err := r.reviewer.Review(ctx, user, checks)
+			if err != nil {
+				return nil, types.ErrorResult(err)
+			}
+
+			var denied []string
+			for _, result := range results {
+				if !result.Allowed {
+					denied = append(denied, result.String())
+				}
+			}
+
+			condition := api.Condition{
+				Type:               v1alpha1.TypePermissionsVerified,
+				Status:             corev1.ConditionTrue,
+				Reason:             v1alpha1.ReasonPermissionsGranted,
+				Message:            "All declared permissions are granted by the API server",
+				ObservedGeneration: accessToken.GetGeneration(),
+				LastTransitionTime: metav1.Now(),
+			}
+			if len(denied) > 0 {
+				condition.Status = corev1.ConditionFalse
+				condition.Reason = v1alpha1.ReasonPermissionsNotGranted
+				condition.Message = fmt.Sprintf("Permissions not granted by the API server, verifying again in %s: %s",
+					verifyRetryDelay, strings.Join(denied, "; "))
+			}
+			accessToken.SetConditions(condition)
+
+			return nextState, types.DoneResult()
+		},
+	}
+}
+
+// requeueVerification requeues AccessTokens whose permissions weren't granted when last verified.
+func requeueVerification(o client.Object, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	accessToken, ok := o.(*v1alpha1.AccessToken)
+	if !ok {
+		return
+	}
+	if accessToken.GetCondition(v1alpha1.TypePermissionsVerified).Reason == v1alpha1.ReasonPermissionsNotGranted {
+		q.AddAfter(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(accessToken)}, verifyRetryDelay)
+	}
+}
+
+// verificationHandler calls requeueVerification whenever an AccessToken is created, e.g. on startup, or updated, e.g.
+// once permissions were found not to be granted.
+var verificationHandler = handler.Funcs{
+	CreateFunc: func(_ context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		requeueVerification(e.Object, q)
+	},
+	UpdateFunc: func(_ context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		requeueVerification(e.ObjectNew, q)
+	},
+}
@@ -0,0 +1,19 @@
+package accesstokengenerator
+
+import (
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var conditionChildrenProvisioned = api.Condition{
+	Type:    v1alpha1.TypeChildrenProvisioned,
+	Status:  corev1.ConditionTrue,
+	Message: "An AccessToken has been applied in every selected namespace (see `status.children`)",
+}
+
+var conditionStaleChildrenRemoved = api.Condition{
+	Type:    v1alpha1.TypeStaleChildrenRemoved,
+	Status:  corev1.ConditionTrue,
+	Message: "AccessTokens of namespaces that are no longer selected have been removed",
+}
@@ -0,0 +1,240 @@
+// Package accesstokengenerator generates an AccessToken in every namespace selected by an AccessTokenGenerator.
+package accesstokengenerator
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-sdk/pkg/fsm"
+	"github.com/reddit/achilles-sdk/pkg/fsm/types"
+	"github.com/reddit/achilles-sdk/pkg/io"
+	"github.com/reddit/achilles-sdk/pkg/logging"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/controlplane"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// +kubebuilder:rbac:groups=group.example.com,resources=accesstokengenerators;accesstokengenerators/status,verbs=*
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+const (
+	controllerName = "AccessTokenGenerator"
+
+	// variableNamespace is replaced with the namespace of the generated AccessToken.
+	variableNamespace = "${namespace}"
+)
+
+type state = types.State[*v1alpha1.AccessTokenGenerator]
+
+type reconciler struct {
+	c   *io.ClientApplicator
+	log *zap.SugaredLogger
+}
+
+func (r *reconciler) provisionChildren() *state {
+	return &state{
+		Name:      "provision-children",
+		Condition: conditionChildrenProvisioned,
+		Transition: func(
+			ctx context.Context,
+			generator *v1alpha1.AccessTokenGenerator,
+			out *types.OutputSet,
+		) (*state, types.Result) {
+			selector, err := metav1.LabelSelectorAsSelector(&generator.Spec.NamespaceSelector)
+			if err != nil {
+				return nil, types.ErrorResultf("parsing namespace selector: %s", err)
+			}
+
+			namespaces := &corev1.NamespaceList{}
+			if err := r.c.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+				return nil, types.ErrorResultf("listing namespaces: %s", err)
+			}
+
+			existing, err := r.children(ctx, generator)
+			if err != nil {
+				return nil, types.ErrorResult(err)
+			}
+
+			desired := map[client.ObjectKey]bool{}
+			var ready int32
+			var conflicts []string
+			for _, ns := range namespaces.Items {
+				// AccessTokens can't be created in terminating namespaces
+				if ns.Status.Phase == corev1.NamespaceTerminating {
+					continue
+				}
+
+				child := newChild(generator, ns.GetName())
+
+				// an AccessToken of the same name that wasn't generated by this generator is never taken over
+				if _, ok := existing[client.ObjectKeyFromObject(child)]; !ok {
+					conflict, err := r.conflicts(ctx, child)
+					if err != nil {
+						return nil, types.ErrorResult(err)
+					}
+					if conflict {
+						conflicts = append(conflicts, client.ObjectKeyFromObject(child).String())
+						continue
+					}
+				}
+
+				out.Apply(child)
+				desired[client.ObjectKeyFromObject(child)] = true
+
+				if at, ok := existing[client.ObjectKeyFromObject(child)]; ok && at.GetCondition(api.TypeReady).Status == corev1.ConditionTrue {
+					ready++
+				}
+			}
+
+			generator.Status.Children = int32(len(desired))
+			generator.Status.ReadyChildren = ready
+			generator.Status.Conflicts = conflicts
+
+			return r.deleteStaleChildren(desired), types.DoneResult()
+		},
+	}
+}
+
+func (r *reconciler) deleteStaleChildren(desired map[client.ObjectKey]bool) *state {
+	return &state{
+		Name:      "delete-stale-children",
+		Condition: conditionStaleChildrenRemoved,
+		Transition: func(
+			ctx context.Context,
+			generator *v1alpha1.AccessTokenGenerator,
+			out *types.OutputSet,
+		) (*state, types.Result) {
+			existing, err := r.children(ctx, generator)
+			if err != nil {
+				return nil, types.ErrorResult(err)
+			}
+
+			for key, child := range existing {
+				if !desired[key] {
+					out.Delete(child)
+				}
+			}
+
+			return nil, types.DoneResult()
+		},
+	}
+}
+
+// children returns the AccessTokens generated by the generator, by key.
+func (r *reconciler) children(
+	ctx context.Context,
+	generator *v1alpha1.AccessTokenGenerator,
+) (map[client.ObjectKey]*v1alpha1.AccessToken, error) {
+	accessTokens := &v1alpha1.AccessTokenList{}
+	if err := r.c.List(ctx, accessTokens, client.MatchingLabels{v1alpha1.LabelAccessTokenGenerator: generator.GetName()}); err != nil {
+		return nil, fmt.Errorf("listing AccessTokens generated by %s: %w", generator.GetName(), err)
+	}
+
+	children := make(map[client.ObjectKey]*v1alpha1.AccessToken, len(accessTokens.Items))
+	for i := range accessTokens.Items {
+		children[client.ObjectKeyFromObject(&accessTokens.Items[i])] = &accessTokens.Items[i]
+	}
+	return children, nil
+}
+
+// conflicts returns whether an AccessToken exists under the child's key that isn't labeled as generated by the child's
+// generator, i.e. one created by hand or generated by another generator.
+func (r *reconciler) conflicts(ctx context.Context, child *v1alpha1.AccessToken) (bool, error) {
+	actual := &v1alpha1.AccessToken{}
+	if err := r.c.Get(ctx, client.ObjectKeyFromObject(child), actual); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting %T %s: %w", actual, client.ObjectKeyFromObject(child), err)
+	}
+	return actual.GetLabels()[v1alpha1.LabelAccessTokenGenerator] != child.GetLabels()[v1alpha1.LabelAccessTokenGenerator], nil
+}
+
+// newChild returns the AccessToken generated in the namespace.
+func newChild(generator *v1alpha1.AccessTokenGenerator, namespace string) *v1alpha1.AccessToken {
+	template := generator.Spec.Template
+
+	name := template.Metadata.Name
+	if name == "" {
+		name = generator.GetName()
+	}
+
+	labels := maps.Clone(template.Metadata.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[v1alpha1.LabelAccessTokenGenerator] = generator.GetName()
+
+	spec := template.Spec.DeepCopy()
+	for i := range spec.NamespacedPermissions {
+		p := &spec.NamespacedPermissions[i]
+		p.Namespace = strings.ReplaceAll(p.Namespace, variableNamespace, namespace)
+	}
+
+	return &v1alpha1.AccessToken{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: maps.Clone(template.Metadata.Annotations),
+		},
+		Spec: *spec,
+	}
+}
+
+// allGenerators enqueues every AccessTokenGenerator, since a namespace may start or stop matching any of their
+// selectors.
+func (r *reconciler) allGenerators(ctx context.Context, _ client.Object) []reconcile.Request {
+	generators := &v1alpha1.AccessTokenGeneratorList{}
+	if err := r.c.List(ctx, generators); err != nil {
+		r.log.Errorf("listing AccessTokenGenerators: %s", err)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(generators.Items))
+	for _, g := range generators.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&g)})
+	}
+	return requests
+}
+
+func SetupController(
+	ctx context.Context,
+	cpCtx controlplane.Context,
+	mgr ctrl.Manager,
+	rl workqueue.TypedRateLimiter[reconcile.Request],
+	c *io.ClientApplicator,
+) error {
+	_, log, err := logging.ControllerCtx(ctx, controllerName)
+	if err != nil {
+		return err
+	}
+
+	r := &reconciler{
+		c:   c,
+		log: log,
+	}
+
+	builder := fsm.NewBuilder(
+		&v1alpha1.AccessTokenGenerator{},
+		r.provisionChildren(),
+		mgr.GetScheme(),
+	).Manages(
+		v1alpha1.GroupVersion.WithKind("AccessToken"),
+	).Watches(
+		&corev1.Namespace{},
+		handler.EnqueueRequestsFromMapFunc(r.allGenerators),
+	)
+
+	return builder.Build()(mgr, log, rl, cpCtx.Metrics)
+}
@@ -0,0 +1,170 @@
+package accesstokengenerator_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("AccessTokenGenerator", func() {
+	It("should generate an AccessToken in every selected namespace", func() {
+		tenant := &corev1.Namespace{
+			ObjectMeta: v1.ObjectMeta{
+				Name:   "tenant-a",
+				Labels: map[string]string{"tenant": "true"},
+			},
+		}
+		Expect(c.Create(ctx, tenant)).To(Succeed())
+
+		generator := &v1alpha1.AccessTokenGenerator{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "namespace-deployer",
+			},
+			Spec: v1alpha1.AccessTokenGeneratorSpec{
+				NamespaceSelector: v1.LabelSelector{
+					MatchLabels: map[string]string{"tenant": "true"},
+				},
+				Template: v1alpha1.GeneratedAccessToken{
+					Spec: v1alpha1.AccessTokenSpec{
+						NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+							{
+								Namespace: "${namespace}",
+								Rules: []rbacv1.PolicyRule{
+									{
+										APIGroups: []string{"apps"},
+										Resources: []string{"deployments"},
+										Verbs:     []string{"*"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, generator)).To(Succeed())
+
+		child := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      generator.Name,
+				Namespace: tenant.Name,
+			},
+		}
+		Eventually(func(g Gomega) {
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(child), child)).To(Succeed())
+			g.Expect(child.Labels).To(HaveKeyWithValue(v1alpha1.LabelAccessTokenGenerator, generator.Name))
+			g.Expect(child.Spec.NamespacedPermissions[0].Namespace).To(Equal(tenant.Name))
+			g.Expect(child.OwnerReferences).To(ContainElement(HaveField("Name", generator.Name)))
+
+			actual := &v1alpha1.AccessTokenGenerator{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(generator), actual)).To(Succeed())
+			g.Expect(actual.Status.Children).To(Equal(int32(1)))
+		}).Should(Succeed())
+
+		By("deleting the AccessToken once the namespace is no longer selected")
+		patched := tenant.DeepCopy()
+		patched.Labels = nil
+		Expect(c.Patch(ctx, patched, client.MergeFrom(tenant))).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(child), &v1alpha1.AccessToken{}))).To(BeTrue())
+
+			actual := &v1alpha1.AccessTokenGenerator{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(generator), actual)).To(Succeed())
+			g.Expect(actual.Status.Children).To(BeZero())
+		}).Should(Succeed())
+
+		Expect(c.Delete(ctx, generator)).To(Succeed())
+	})
+
+	It("should not take over AccessTokens it didn't generate", func() {
+		tenant := &corev1.Namespace{
+			ObjectMeta: v1.ObjectMeta{
+				Name:   "tenant-b",
+				Labels: map[string]string{"conflict": "true"},
+			},
+		}
+		Expect(c.Create(ctx, tenant)).To(Succeed())
+
+		handmade := &v1alpha1.AccessToken{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "handmade",
+				Namespace: tenant.Name,
+			},
+		}
+		Expect(c.Create(ctx, handmade)).To(Succeed())
+
+		newGenerator := func(name, childName string) *v1alpha1.AccessTokenGenerator {
+			return &v1alpha1.AccessTokenGenerator{
+				ObjectMeta: v1.ObjectMeta{
+					Name: name,
+				},
+				Spec: v1alpha1.AccessTokenGeneratorSpec{
+					NamespaceSelector: v1.LabelSelector{
+						MatchLabels: map[string]string{"conflict": "true"},
+					},
+					Template: v1alpha1.GeneratedAccessToken{
+						Metadata: v1alpha1.GeneratedObjectMeta{Name: childName},
+						Spec: v1alpha1.AccessTokenSpec{
+							NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+								{
+									Namespace: "${namespace}",
+									Rules: []rbacv1.PolicyRule{
+										{
+											APIGroups: []string{""},
+											Resources: []string{"configmaps"},
+											Verbs:     []string{"get"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+		}
+
+		first := newGenerator("first", "shared")
+		Expect(c.Create(ctx, first)).To(Succeed())
+		Eventually(func(g Gomega) {
+			actual := &v1alpha1.AccessTokenGenerator{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(first), actual)).To(Succeed())
+			g.Expect(actual.Status.Children).To(Equal(int32(1)))
+		}).Should(Succeed())
+
+		By("reporting AccessTokens created by hand as conflicts")
+		byHand := newGenerator("by-hand", handmade.Name)
+		Expect(c.Create(ctx, byHand)).To(Succeed())
+
+		By("reporting AccessTokens generated by another generator as conflicts")
+		second := newGenerator("second", "shared")
+		Expect(c.Create(ctx, second)).To(Succeed())
+
+		for _, generator := range []*v1alpha1.AccessTokenGenerator{byHand, second} {
+			Eventually(func(g Gomega) {
+				actual := &v1alpha1.AccessTokenGenerator{}
+				g.Expect(c.Get(ctx, client.ObjectKeyFromObject(generator), actual)).To(Succeed())
+				g.Expect(actual.Status.Children).To(BeZero())
+				g.Expect(actual.Status.Conflicts).To(ConsistOf(tenant.Name + "/" + generator.Spec.Template.Metadata.Name))
+			}).Should(Succeed())
+		}
+
+		By("leaving the existing AccessTokens untouched")
+		actual := &v1alpha1.AccessToken{}
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(handmade), actual)).To(Succeed())
+		Expect(actual.Labels).ToNot(HaveKey(v1alpha1.LabelAccessTokenGenerator))
+		Expect(actual.Spec.NamespacedPermissions).To(BeEmpty())
+
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: tenant.Name, Name: "shared"}, actual)).To(Succeed())
+		Expect(actual.Labels).To(HaveKeyWithValue(v1alpha1.LabelAccessTokenGenerator, first.Name))
+
+		for _, generator := range []*v1alpha1.AccessTokenGenerator{first, byHand, second} {
+			Expect(c.Delete(ctx, generator)).To(Succeed())
+		}
+	})
+})
@@ -1,7 +1,12 @@
 // Package controlplane contains state shared across all reconcilers.
 package controlplane
 
-import "github.com/reddit/achilles-sdk/pkg/fsm/metrics"
+import (
+	"github.com/reddit/achilles-sdk/pkg/fsm/metrics"
+	"github.com/reddit/achilles-token-controller/internal/audit"
+	"github.com/reddit/achilles-token-controller/internal/denylist"
+	"github.com/reddit/achilles-token-controller/internal/tokenmetrics"
+)
 
 // Context holds information on how the controller should run. These values may
 // be referenced during the execution of transition functions.
@@ -11,4 +16,17 @@ type Context struct {
 
 	// Metrics is the prometheus metrics sink for this controller binary.
 	Metrics *metrics.Metrics
+
+	// TokenMetrics is the sink for token specific metrics, served by the manager's metrics endpoint.
+	TokenMetrics *tokenmetrics.Metrics
+
+	// Auditor records every permission granted or revoked by the controller.
+	Auditor *audit.Logger
+
+	// RequireNamespaceConsent, if true, only grants an AccessToken's NamespacedPermissions in namespaces that consent to
+	// grants from the AccessToken's namespace. Otherwise namespaces that don't consent are only reported.
+	RequireNamespaceConsent bool
+
+	// DenyList refuses namespaces and resources no AccessToken may be granted, nil to deny nothing.
+	DenyList *denylist.DenyList
 }
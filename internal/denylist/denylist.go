@@ -0,0 +1,192 @@
+// Package denylist refuses grants of namespaces and resources that no AccessToken may ever be granted, regardless of
+// who created it.
+package denylist
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DenyList lists the namespaces and resources no AccessToken may be granted. A nil DenyList denies nothing.
+type DenyList struct {
+	namespaces []string
+	resources  []schema.GroupResource
+
+	// mapper looks up whether resources are namespaced, if nil every resource is assumed to be
+	mapper apimeta.RESTMapper
+}
+
+// New returns a DenyList of the namespaces and resources. Resources are given as "resource" for the core API group, or
+// "resource.group" otherwise, e.g. "secrets" or "deployments.apps".
+func New(namespaces, resources []string) *DenyList {
+	d := &DenyList{namespaces: namespaces}
+	for _, r := range resources {
+		d.resources = append(d.resources, schema.ParseGroupResource(r))
+	}
+	return d
+}
+
+// WithRESTMapper returns a copy of the DenyList looking up whether resources granted by cluster permissions are
+// namespaced with the mapper.
+func (d *DenyList) WithRESTMapper(mapper apimeta.RESTMapper) *DenyList {
+	if d == nil {
+		return nil
+	}
+	copied := *d
+	copied.mapper = mapper
+	return &copied
+}
+
+// Violation is a part of an AccessToken refused by the DenyList.
+type Violation struct {
+	// Path locates the refused namespace or rule within the AccessToken, e.g. "spec.namespacedPermissions[0].rules[1]".
+	Path string `json:"path"`
+
+	// Message explains why it's refused.
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// DeniesNamespace returns true if no permissions may be granted in the namespace.
+func (d *DenyList) DeniesNamespace(namespace string) bool {
+	return d != nil && slices.Contains(d.namespaces, namespace)
+}
+
+// DeniedResource returns the denied resource the rule grants access to, if any. Rules granting a denied resource
+// through wildcards or subresources are denied too, since they grant as much access.
+func (d *DenyList) DeniedResource(rule rbacv1.PolicyRule) (schema.GroupResource, bool) {
+	if d == nil {
+		return schema.GroupResource{}, false
+	}
+	for _, denied := range d.resources {
+		if !slices.Contains(rule.APIGroups, denied.Group) && !slices.Contains(rule.APIGroups, rbacv1.APIGroupAll) {
+			continue
+		}
+		for _, r := range rule.Resources {
+			base, _, _ := strings.Cut(r, "/")
+			if r == rbacv1.ResourceAll || base == denied.Resource {
+				return denied, true
+			}
+		}
+	}
+	return schema.GroupResource{}, false
+}
+
+// GrantsDeniedNamespaces returns true if the rule, granted cluster-wide, grants access to namespaced resources while
+// namespaces are denied, since it grants the same access in the denied namespaces. Resources given through wildcards,
+// or whose scope can't be looked up, are assumed to be namespaced.
+func (d *DenyList) GrantsDeniedNamespaces(rule rbacv1.PolicyRule) bool {
+	if d == nil || len(d.namespaces) == 0 {
+		return false
+	}
+	for _, group := range rule.APIGroups {
+		for _, r := range rule.Resources {
+			if d.namespaced(group, r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *DenyList) namespaced(group, resource string) bool {
+	base, _, _ := strings.Cut(resource, "/")
+	if d.mapper == nil || group == rbacv1.APIGroupAll || base == rbacv1.ResourceAll {
+		return true
+	}
+	gvk, err := d.mapper.KindFor(schema.GroupVersionResource{Group: group, Resource: base})
+	if err != nil {
+		return true
+	}
+	mapping, err := d.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return true
+	}
+	return mapping.Scope.Name() != apimeta.RESTScopeNameRoot
+}
+
+// AllowedRules returns the rules that don't grant a denied resource.
+func (d *DenyList) AllowedRules(rules []rbacv1.PolicyRule) []rbacv1.PolicyRule {
+	if d == nil {
+		return rules
+	}
+	var allowed []rbacv1.PolicyRule
+	for _, rule := range rules {
+		if _, denied := d.DeniedResource(rule); !denied {
+			allowed = append(allowed, rule)
+		}
+	}
+	return allowed
+}
+
+// AllowedClusterRules returns the rules that may be granted cluster-wide, they don't grant a denied resource or
+// namespaced resources while namespaces are denied.
+func (d *DenyList) AllowedClusterRules(rules []rbacv1.PolicyRule) []rbacv1.PolicyRule {
+	var allowed []rbacv1.PolicyRule
+	for _, rule := range d.AllowedRules(rules) {
+		if !d.GrantsDeniedNamespaces(rule) {
+			allowed = append(allowed, rule)
+		}
+	}
+	return allowed
+}
+
+// Check returns the parts of the AccessToken spec refused by the DenyList.
+func (d *DenyList) Check(spec v1alpha1.AccessTokenSpec) []Violation {
+	var violations []Violation
+	for i, p := range spec.NamespacedPermissions {
+		path := fmt.Sprintf("spec.namespacedPermissions[%d]", i)
+		if d.DeniesNamespace(p.Namespace) {
+			violations = append(violations, Violation{
+				Path:    path,
+				Message: fmt.Sprintf("namespace %s is denied", p.Namespace),
+			})
+			continue
+		}
+		violations = append(violations, d.checkRules(p.Rules, path+".rules")...)
+	}
+	if spec.ClusterPermissions != nil {
+		violations = append(violations, d.checkClusterRules(spec.ClusterPermissions.Rules, "spec.clusterPermissions.rules")...)
+	}
+	return violations
+}
+
+func (d *DenyList) checkClusterRules(rules []rbacv1.PolicyRule, path string) []Violation {
+	var violations []Violation
+	for i, rule := range rules {
+		if denied, ok := d.DeniedResource(rule); ok {
+			violations = append(violations, Violation{
+				Path:    fmt.Sprintf("%s[%d]", path, i),
+				Message: fmt.Sprintf("grants denied resource %s", denied),
+			})
+		} else if d.GrantsDeniedNamespaces(rule) {
+			violations = append(violations, Violation{
+				Path:    fmt.Sprintf("%s[%d]", path, i),
+				Message: fmt.Sprintf("grants namespaced resources cluster-wide, including in denied namespaces %s", strings.Join(d.namespaces, ", ")),
+			})
+		}
+	}
+	return violations
+}
+
+func (d *DenyList) checkRules(rules []rbacv1.PolicyRule, path string) []Violation {
+	var violations []Violation
+	for i, rule := range rules {
+		if denied, ok := d.DeniedResource(rule); ok {
+			violations = append(violations, Violation{
+				Path:    fmt.Sprintf("%s[%d]", path, i),
+				Message: fmt.Sprintf("grants denied resource %s", denied),
+			})
+		}
+	}
+	return violations
+}
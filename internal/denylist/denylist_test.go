@@ -0,0 +1,130 @@
+package denylist_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/denylist"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var _ = Describe("DenyList", func() {
+	d := denylist.New([]string{"kube-system"}, []string{"secrets", "nodes", "deployments.apps"})
+
+	DescribeTable("should deny rules granting denied resources",
+		func(rule rbacv1.PolicyRule, denied bool) {
+			_, ok := d.DeniedResource(rule)
+			Expect(ok).To(Equal(denied))
+		},
+		Entry("configmaps",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}, false),
+		Entry("secrets",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps", "secrets"}, Verbs: []string{"get"}}, true),
+		Entry("secrets in another API group",
+			rbacv1.PolicyRule{APIGroups: []string{"example.com"}, Resources: []string{"secrets"}, Verbs: []string{"get"}}, false),
+		Entry("a subresource of nodes",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"nodes/proxy"}, Verbs: []string{"get"}}, true),
+		Entry("all resources",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"*"}, Verbs: []string{"get"}}, true),
+		Entry("all API groups",
+			rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"deployments"}, Verbs: []string{"get"}}, true),
+		Entry("deployments in the apps API group",
+			rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}}, true),
+		Entry("deployments in the core API group",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"deployments"}, Verbs: []string{"get"}}, false),
+	)
+
+	It("should report refused namespaces and rules", func() {
+		spec := v1alpha1.AccessTokenSpec{
+			NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+				{
+					Namespace: "default",
+					Rules: []rbacv1.PolicyRule{
+						{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+						{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+					},
+				},
+				{
+					Namespace: "kube-system",
+					Rules: []rbacv1.PolicyRule{
+						{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+					},
+				},
+			},
+			ClusterPermissions: &v1alpha1.ClusterPermissions{
+				Rules: []rbacv1.PolicyRule{
+					{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+				},
+			},
+		}
+
+		Expect(d.Check(spec)).To(Equal([]denylist.Violation{
+			{Path: "spec.namespacedPermissions[0].rules[1]", Message: "grants denied resource secrets"},
+			{Path: "spec.namespacedPermissions[1]", Message: "namespace kube-system is denied"},
+			{Path: "spec.clusterPermissions.rules[0]", Message: "grants denied resource nodes"},
+		}))
+		Expect(d.AllowedRules(spec.NamespacedPermissions[0].Rules)).To(Equal(spec.NamespacedPermissions[0].Rules[:1]))
+	})
+
+	Describe("cluster permissions", func() {
+		mapper := apimeta.NewDefaultRESTMapper(nil)
+		mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, apimeta.RESTScopeNamespace)
+		mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, apimeta.RESTScopeRoot)
+		mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "PersistentVolume"}, apimeta.RESTScopeRoot)
+		scoped := d.WithRESTMapper(mapper)
+
+		DescribeTable("should refuse namespaced resources while namespaces are denied",
+			func(d *denylist.DenyList, rule rbacv1.PolicyRule, refused bool) {
+				Expect(d.GrantsDeniedNamespaces(rule)).To(Equal(refused))
+				if refused {
+					Expect(d.AllowedClusterRules([]rbacv1.PolicyRule{rule})).To(BeEmpty())
+				} else {
+					Expect(d.AllowedClusterRules([]rbacv1.PolicyRule{rule})).To(Equal([]rbacv1.PolicyRule{rule}))
+				}
+			},
+			Entry("namespaced resources",
+				scoped, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}, true),
+			Entry("cluster scoped resources",
+				scoped, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces", "persistentvolumes/status"}, Verbs: []string{"get"}}, false),
+			Entry("namespaced and cluster scoped resources",
+				scoped, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces", "configmaps"}, Verbs: []string{"get"}}, true),
+			Entry("all API groups",
+				scoped, rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"namespaces"}, Verbs: []string{"get"}}, true),
+			Entry("unknown resources",
+				scoped, rbacv1.PolicyRule{APIGroups: []string{"example.com"}, Resources: []string{"widgets"}, Verbs: []string{"get"}}, true),
+			Entry("non-resource URLs",
+				scoped, rbacv1.PolicyRule{NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}}, false),
+			Entry("any resource without a mapper",
+				d, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"get"}}, true),
+			Entry("namespaced resources without denied namespaces",
+				denylist.New(nil, []string{"secrets"}).WithRESTMapper(mapper),
+				rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}, false),
+		)
+
+		It("should report cluster rules reaching denied namespaces", func() {
+			Expect(scoped.Check(v1alpha1.AccessTokenSpec{
+				ClusterPermissions: &v1alpha1.ClusterPermissions{
+					Rules: []rbacv1.PolicyRule{
+						{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"list"}},
+						{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"list"}},
+					},
+				},
+			})).To(Equal([]denylist.Violation{
+				{
+					Path:    "spec.clusterPermissions.rules[1]",
+					Message: "grants namespaced resources cluster-wide, including in denied namespaces kube-system",
+				},
+			}))
+		})
+	})
+
+	It("should deny nothing if nil", func() {
+		var nilList *denylist.DenyList
+		Expect(nilList.DeniesNamespace("kube-system")).To(BeFalse())
+		Expect(nilList.Check(v1alpha1.AccessTokenSpec{
+			NamespacedPermissions: []v1alpha1.NamespacedPermissions{{Namespace: "kube-system"}},
+		})).To(BeEmpty())
+	})
+})
@@ -0,0 +1,485 @@
+// Package doctor diagnoses AccessTokens whose provisioned objects don't grant the expected access.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/accessreview"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Check identifies the kind of check that found a problem.
+type Check string
+
+const (
+	CheckAccessToken Check = "accesstoken"
+	CheckCondition   Check = "condition"
+	CheckReference   Check = "reference"
+	CheckNamespace   Check = "namespace"
+	CheckObject      Check = "object"
+	CheckSecret      Check = "secret"
+	CheckBinding     Check = "binding"
+	CheckOrphan      Check = "orphan"
+	CheckAccess      Check = "access"
+)
+
+// Problem is a diagnosed problem along with a suggested fix.
+type Problem struct {
+	// Check that found the problem.
+	Check Check `json:"check"`
+
+	// Object the problem was found on, e.g. "RoleBinding team-a/my-token", empty if not specific to an object.
+	Object string `json:"object,omitempty"`
+
+	// Message describes the problem.
+	Message string `json:"message"`
+
+	// Fix suggests how to resolve the problem.
+	Fix string `json:"fix"`
+}
+
+// conditionFixes are the suggested fixes for conditions of the AccessToken that aren't True.
+var conditionFixes = map[api.ConditionType]string{
+	v1alpha1.TypeTokenProvisioned:        "check the controller logs for errors applying the managed objects",
+	v1alpha1.TypeStalePermissionsRemoved: "check the controller logs for errors deleting stale objects, and delete them manually if needed",
+	v1alpha1.TypePermissionsVerified:     "ensure the controller holds every permission it grants, RBAC forbids granting permissions the granter doesn't hold",
+	v1alpha1.TypeNamespacesConsented:     "create an AccessTokenGrant in the listed namespaces allowing the AccessToken's namespace, or annotate them with " + v1alpha1.AnnotationAllowedSourceNamespaces,
+	v1alpha1.TypePermissionsAllowed:      "remove the namespaces and rules refused by the controller's --denied-namespaces and --denied-resources from the AccessToken",
+	v1alpha1.TypeRemoteTokensProvisioned: "check status.clusters, the kubeconfig Secret must reach the cluster with permissions to manage ServiceAccounts, Secrets and RBAC",
+	v1alpha1.TypeSecretsMirrored:         "annotate the namespaces of the listed secret targets with " + v1alpha1.AnnotationAllowedSecretSourceNamespaces + ", or wait for the token to be issued",
+	v1alpha1.TypeTokenDelivered:          "ensure spec.delivery.publicKey is a base64 encoded X25519 public key, see the keygen command",
+	v1alpha1.TypeAssertionsSatisfied:     "adjust the token's permissions or spec.assertions, see status.assertionResults for the failing assertions",
+}
+
+// informationalConditions are conditions that don't indicate a problem when not True.
+var informationalConditions = map[api.ConditionType]bool{
+	api.TypeReady:                    true, // aggregates the other conditions
+	v1alpha1.TypeHighRiskPermissions: true,
+}
+
+// Doctor diagnoses AccessTokens.
+type Doctor struct {
+	c        client.Client
+	reviewer *accessreview.Reviewer
+}
+
+// New returns a Doctor using the provided client.
+func New(c client.Client) *Doctor {
+	return &Doctor{
+		c:        c,
+		reviewer: accessreview.NewReviewer(c),
+	}
+}
+
+// Diagnose returns the problems found with the AccessToken. If the AccessToken doesn't exist, objects leaked on its
+// behalf are still reported.
+func (d *Doctor) Diagnose(ctx context.Context, key client.ObjectKey) ([]Problem, error) {
+	var problems []Problem
+
+	accessToken := &v1alpha1.AccessToken{}
+	if err := d.c.Get(ctx, key, accessToken); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("getting %T %s: %w", accessToken, key, err)
+		}
+		problems = append(problems, Problem{
+			Check:   CheckAccessToken,
+			Message: fmt.Sprintf("AccessToken %s not found", key),
+			Fix:     "check the namespace and name of the AccessToken",
+		})
+		orphans, err := d.checkOrphans(ctx, key, nil)
+		if err != nil {
+			return nil, err
+		}
+		return append(problems, orphans...), nil
+	}
+
+	// the remaining checks expect the permissions of the referenced template and permission sets, unresolvable
+	// references are reported and the token's own permissions are checked
+	resolved, err := accesstoken.Resolve(accessToken, accesstoken.ClientResolver(ctx, d.c))
+	if err != nil {
+		problems = append(problems, Problem{
+			Check:   CheckReference,
+			Message: err.Error(),
+			Fix:     "create the referenced AccessTokenTemplate or permission set, fix the template parameters, or remove the reference from the AccessToken",
+		})
+		resolved = accessToken
+	}
+
+	desired := accesstoken.Render(resolved)
+
+	checks := []func(context.Context, *v1alpha1.AccessToken, []client.Object) ([]Problem, error){
+		d.checkConditions,
+		d.checkNamespaces,
+		d.checkObjects,
+		d.checkAccess,
+	}
+	for _, check := range checks {
+		p, err := check(ctx, resolved, desired)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, p...)
+	}
+
+	// objects referenced by the status but no longer desired are reported by checkObjects
+	known := map[string]bool{}
+	for _, o := range desired {
+		name, err := d.objectName(o)
+		if err != nil {
+			return nil, err
+		}
+		known[name] = true
+	}
+	for _, ref := range accessToken.Status.ResourceRefs {
+		known[describe(ref.Kind, ref.ObjectKey())] = true
+	}
+
+	orphans, err := d.checkOrphans(ctx, key, known)
+	if err != nil {
+		return nil, err
+	}
+	return append(problems, orphans...), nil
+}
+
+func (d *Doctor) checkConditions(_ context.Context, accessToken *v1alpha1.AccessToken, _ []client.Object) ([]Problem, error) {
+	var problems []Problem
+	for _, condition := range accessToken.Status.Conditions {
+		if informationalConditions[condition.Type] || condition.Status == corev1.ConditionTrue {
+			continue
+		}
+		fix, ok := conditionFixes[condition.Type]
+		if !ok {
+			fix = "see the condition's message"
+		}
+		problems = append(problems, Problem{
+			Check:   CheckCondition,
+			Message: fmt.Sprintf("condition %s is %s: %s", condition.Type, condition.Status, condition.Message),
+			Fix:     fix,
+		})
+	}
+	if len(accessToken.Status.Conditions) == 0 {
+		problems = append(problems, Problem{
+			Check:   CheckCondition,
+			Message: "the AccessToken has not been reconciled yet",
+			Fix:     "check that the controller is running and its logs for errors",
+		})
+	} else if ready := accessToken.GetCondition(api.TypeReady); ready.ObservedGeneration != 0 && ready.ObservedGeneration < accessToken.GetGeneration() {
+		problems = append(problems, Problem{
+			Check:   CheckCondition,
+			Message: "the controller hasn't reconciled the latest generation of the AccessToken",
+			Fix:     "check that the controller is running and its logs for errors",
+		})
+	}
+	return problems, nil
+}
+
+func (d *Doctor) checkNamespaces(ctx context.Context, accessToken *v1alpha1.AccessToken, _ []client.Object) ([]Problem, error) {
+	var problems []Problem
+	for i, p := range accessToken.Spec.NamespacedPermissions {
+		ns := &corev1.Namespace{}
+		if err := d.c.Get(ctx, client.ObjectKey{Name: p.Namespace}, ns); err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, fmt.Errorf("getting %T %s: %w", ns, p.Namespace, err)
+			}
+			problems = append(problems, Problem{
+				Check:   CheckNamespace,
+				Object:  fmt.Sprintf("Namespace %s", p.Namespace),
+				Message: fmt.Sprintf("namespace %q of spec.namespacedPermissions[%d] does not exist", p.Namespace, i),
+				Fix:     fmt.Sprintf("create namespace %q or remove it from spec.namespacedPermissions", p.Namespace),
+			})
+			continue
+		}
+		if ns.Status.Phase == corev1.NamespaceTerminating {
+			problems = append(problems, Problem{
+				Check:   CheckNamespace,
+				Object:  fmt.Sprintf("Namespace %s", p.Namespace),
+				Message: fmt.Sprintf("namespace %q of spec.namespacedPermissions[%d] is terminating", p.Namespace, i),
+				Fix:     fmt.Sprintf("remove namespace %q from spec.namespacedPermissions", p.Namespace),
+			})
+		}
+	}
+	return problems, nil
+}
+
+// checkObjects checks the desired objects and the objects listed in status.resourceRefs.
+func (d *Doctor) checkObjects(ctx context.Context, accessToken *v1alpha1.AccessToken, desired []client.Object) ([]Problem, error) {
+	var problems []Problem
+
+	type ref struct {
+		gvk schema.GroupVersionKind
+		key client.ObjectKey
+	}
+	desiredByRef := map[ref]client.Object{}
+	var refs []ref
+	for _, o := range desired {
+		gvk, err := apiutil.GVKForObject(o, d.c.Scheme())
+		if err != nil {
+			return nil, err
+		}
+		r := ref{gvk: gvk, key: client.ObjectKeyFromObject(o)}
+		desiredByRef[r] = o
+		refs = append(refs, r)
+	}
+	for _, r := range accessToken.Status.ResourceRefs {
+		r := ref{gvk: r.GroupVersionKind(), key: r.ObjectKey()}
+		if _, ok := desiredByRef[r]; !ok {
+			refs = append(refs, r)
+		}
+	}
+
+	for _, r := range refs {
+		obj, err := d.c.Scheme().New(r.gvk)
+		if err != nil {
+			return nil, fmt.Errorf("constructing %s: %w", r.gvk, err)
+		}
+		actual, ok := obj.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an object", r.gvk)
+		}
+		name := describe(r.gvk.Kind, r.key)
+
+		wanted, isDesired := desiredByRef[r]
+		if err := d.c.Get(ctx, r.key, actual); err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, fmt.Errorf("getting %s: %w", name, err)
+			}
+			if isDesired {
+				problems = append(problems, Problem{
+					Check:   CheckObject,
+					Object:  name,
+					Message: fmt.Sprintf("%s does not exist", name),
+					Fix:     "the controller creates it on its next reconcile, if it doesn't see the TokenProvisioned condition and the controller logs",
+				})
+			} else {
+				problems = append(problems, Problem{
+					Check:   CheckObject,
+					Object:  name,
+					Message: fmt.Sprintf("status.resourceRefs references %s, which does not exist", name),
+					Fix:     "the controller prunes the reference on its next reconcile",
+				})
+			}
+			continue
+		}
+
+		if !isDesired {
+			problems = append(problems, Problem{
+				Check:   CheckOrphan,
+				Object:  name,
+				Message: fmt.Sprintf("%s is no longer desired but still exists", name),
+				Fix:     "check the StalePermissionsRemoved condition, or delete the object manually",
+			})
+			continue
+		}
+
+		problems = append(problems, checkObject(name, wanted, actual)...)
+	}
+
+	return problems, nil
+}
+
+// checkObject compares an object that exists with its desired state.
+func checkObject(name string, desired, actual client.Object) []Problem {
+	var problems []Problem
+
+	switch actual := actual.(type) {
+	case *corev1.Secret:
+		desiredSA := desired.GetAnnotations()[corev1.ServiceAccountNameKey]
+		if sa := actual.GetAnnotations()[corev1.ServiceAccountNameKey]; sa != desiredSA {
+			problems = append(problems, Problem{
+				Check:   CheckSecret,
+				Object:  name,
+				Message: fmt.Sprintf("%s is issued for ServiceAccount %q instead of %q", name, sa, desiredSA),
+				Fix:     "delete the Secret, the controller recreates it for the right ServiceAccount",
+			})
+		} else if len(actual.Data[corev1.ServiceAccountTokenKey]) == 0 {
+			problems = append(problems, Problem{
+				Check:   CheckSecret,
+				Object:  name,
+				Message: fmt.Sprintf("%s has not been populated with a token", name),
+				Fix:     "ensure the ServiceAccount exists and the token controller of kube-controller-manager is running",
+			})
+		}
+	case *rbacv1.RoleBinding:
+		problems = append(problems, checkBinding(name, desired.(*rbacv1.RoleBinding).Subjects, actual.Subjects,
+			desired.(*rbacv1.RoleBinding).RoleRef, actual.RoleRef)...)
+	case *rbacv1.ClusterRoleBinding:
+		problems = append(problems, checkBinding(name, desired.(*rbacv1.ClusterRoleBinding).Subjects, actual.Subjects,
+			desired.(*rbacv1.ClusterRoleBinding).RoleRef, actual.RoleRef)...)
+	case *rbacv1.Role:
+		if !equality.Semantic.DeepEqual(desired.(*rbacv1.Role).Rules, actual.Rules) {
+			problems = append(problems, rulesDrifted(name))
+		}
+	case *rbacv1.ClusterRole:
+		if !equality.Semantic.DeepEqual(desired.(*rbacv1.ClusterRole).Rules, actual.Rules) {
+			problems = append(problems, rulesDrifted(name))
+		}
+	}
+
+	return problems
+}
+
+func checkBinding(name string, desiredSubjects, actualSubjects []rbacv1.Subject, desiredRoleRef, actualRoleRef rbacv1.RoleRef) []Problem {
+	var problems []Problem
+	if !equality.Semantic.DeepEqual(desiredSubjects, actualSubjects) {
+		problems = append(problems, Problem{
+			Check:   CheckBinding,
+			Object:  name,
+			Message: fmt.Sprintf("%s binds %s instead of %s", name, subjectsString(actualSubjects), subjectsString(desiredSubjects)),
+			Fix:     "the controller restores the subjects on its next reconcile, check who modified them in the API server audit log",
+		})
+	}
+	if desiredRoleRef != actualRoleRef {
+		problems = append(problems, Problem{
+			Check:   CheckBinding,
+			Object:  name,
+			Message: fmt.Sprintf("%s references %s %s instead of %s %s", name, actualRoleRef.Kind, actualRoleRef.Name, desiredRoleRef.Kind, desiredRoleRef.Name),
+			Fix:     "delete the binding, the role reference of a binding is immutable and the controller recreates it",
+		})
+	}
+	return problems
+}
+
+func rulesDrifted(name string) Problem {
+	return Problem{
+		Check:   CheckObject,
+		Object:  name,
+		Message: fmt.Sprintf("the rules of %s differ from the AccessToken's", name),
+		Fix:     "the controller restores the rules on its next reconcile, check who modified them in the API server audit log",
+	}
+}
+
+func subjectsString(subjects []rbacv1.Subject) string {
+	if len(subjects) == 0 {
+		return "no subjects"
+	}
+	var s []string
+	for _, subject := range subjects {
+		s = append(s, describe(subject.Kind, client.ObjectKey{Namespace: subject.Namespace, Name: subject.Name}))
+	}
+	return strings.Join(s, ", ")
+}
+
+// checkOrphans reports RBAC objects labeled as managed for the AccessToken that are neither desired nor referenced by
+// its status, e.g. leaked by a controller that was stopped before it could clean up. known holds the names of the
+// desired and referenced objects as returned by objectName.
+func (d *Doctor) checkOrphans(ctx context.Context, key client.ObjectKey, known map[string]bool) ([]Problem, error) {
+	selector := client.MatchingLabels{
+		v1alpha1.LabelAccessTokenName:      key.Name,
+		v1alpha1.LabelAccessTokenNamespace: key.Namespace,
+	}
+
+	var problems []Problem
+	lists := []client.ObjectList{
+		&rbacv1.RoleList{},
+		&rbacv1.RoleBindingList{},
+		&rbacv1.ClusterRoleList{},
+		&rbacv1.ClusterRoleBindingList{},
+	}
+	for _, list := range lists {
+		if err := d.c.List(ctx, list, selector); err != nil {
+			return nil, fmt.Errorf("listing %T: %w", list, err)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			o, ok := item.(client.Object)
+			if !ok {
+				continue
+			}
+			name, err := d.objectName(o)
+			if err != nil {
+				return nil, err
+			}
+			if known[name] {
+				continue
+			}
+			problems = append(problems, Problem{
+				Check:   CheckOrphan,
+				Object:  name,
+				Message: fmt.Sprintf("%s is labeled as managed for AccessToken %s but is not part of its desired state", name, key),
+				Fix:     fmt.Sprintf("delete the leaked object: %s", kubectlDelete(o, name)),
+			})
+		}
+	}
+
+	return problems, nil
+}
+
+// objectName describes the object, e.g. "RoleBinding team-a/my-token".
+func (d *Doctor) objectName(o client.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(o, d.c.Scheme())
+	if err != nil {
+		return "", err
+	}
+	return describe(gvk.Kind, client.ObjectKeyFromObject(o)), nil
+}
+
+// describe returns the kind and key of an object, omitting the namespace of cluster scoped objects.
+func describe(kind string, key client.ObjectKey) string {
+	if key.Namespace == "" {
+		return fmt.Sprintf("%s %s", kind, key.Name)
+	}
+	return fmt.Sprintf("%s %s", kind, key)
+}
+
+func kubectlDelete(o client.Object, name string) string {
+	kind, _, _ := strings.Cut(name, " ")
+	cmd := fmt.Sprintf("kubectl delete %s.%s %s", strings.ToLower(kind), rbacv1.GroupName, o.GetName())
+	if o.GetNamespace() != "" {
+		cmd += fmt.Sprintf(" -n %s", o.GetNamespace())
+	}
+	return cmd
+}
+
+// checkAccess runs SubjectAccessReviews for a sample of the desired rules as the token's ServiceAccount.
+func (d *Doctor) checkAccess(ctx context.Context, accessToken *v1alpha1.AccessToken, desired []client.Object) ([]Problem, error) {
+	var checks []accessreview.Check
+	var user accessreview.ServiceAccountUser
+	for _, o := range desired {
+		switch o := o.(type) {
+		case *corev1.ServiceAccount:
+			user = accessreview.ServiceAccountUser{Namespace: o.GetNamespace(), Name: o.GetName()}
+		case *rbacv1.Role:
+			for _, rule := range o.Rules {
+				checks = append(checks, accessreview.SampleRule(rule, o.GetNamespace())...)
+			}
+		case *rbacv1.ClusterRole:
+			for _, rule := range o.Rules {
+				checks = append(checks, accessreview.SampleRule(rule, "")...)
+			}
+		}
+	}
+
+	results, err := d.reviewer.Review(ctx, user, checks)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	for _, result := range results {
+		if result.Allowed {
+			continue
+		}
+		problems = append(problems, Problem{
+			Check:   CheckAccess,
+			Message: fmt.Sprintf("ServiceAccount %s is not allowed to %s", user.Username(), result.Check),
+			Fix:     "fix the problems with the token's Roles and bindings reported above, the grant may also be denied by an admission webhook or authorizer",
+		})
+	}
+	return problems, nil
+}
@@ -0,0 +1,135 @@
+package doctor_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
+	"github.com/reddit/achilles-token-controller/internal/doctor"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+var _ = Describe("Doctor", func() {
+	var (
+		ctx         context.Context
+		accessToken *v1alpha1.AccessToken
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		rules := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}}
+		accessToken = &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "foobar", Namespace: "default"},
+			Spec: v1alpha1.AccessTokenSpec{
+				NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+					{Namespace: "default", Rules: rules},
+					{Namespace: "missing", Rules: rules},
+				},
+			},
+		}
+	})
+
+	// newClient returns a fake client holding the objects, which allows every SubjectAccessReview for the "default"
+	// namespace
+	newClient := func(objs ...client.Object) client.Client {
+		return fake.NewClientBuilder().
+			WithScheme(intscheme.MustNewScheme()).
+			WithObjects(objs...).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					if sar, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+						sar.Status.Allowed = sar.Spec.ResourceAttributes.Namespace == "default"
+						return nil
+					}
+					return c.Create(ctx, obj, opts...)
+				},
+			}).
+			Build()
+	}
+
+	problemsOf := func(problems []doctor.Problem, check doctor.Check) []doctor.Problem {
+		var out []doctor.Problem
+		for _, p := range problems {
+			if p.Check == check {
+				out = append(out, p)
+			}
+		}
+		return out
+	}
+
+	It("should diagnose a broken AccessToken", func() {
+		objs := []client.Object{
+			accessToken,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			// leaked by a previous generation of the token
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{
+				Name: "foobar-default",
+				Labels: map[string]string{
+					v1alpha1.LabelAccessTokenName:      "foobar",
+					v1alpha1.LabelAccessTokenNamespace: "default",
+				},
+			}},
+		}
+		for _, o := range accesstoken.Render(accessToken) {
+			if o.GetNamespace() == "missing" {
+				continue
+			}
+			if binding, ok := o.(*rbacv1.RoleBinding); ok {
+				binding.Subjects[0].Name = "someone-else"
+			}
+			objs = append(objs, o)
+		}
+
+		problems, err := doctor.New(newClient(objs...)).Diagnose(ctx, client.ObjectKeyFromObject(accessToken))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(problemsOf(problems, doctor.CheckNamespace)).To(ConsistOf(
+			HaveField("Object", "Namespace missing"),
+		))
+		Expect(problemsOf(problems, doctor.CheckObject)).To(ConsistOf(
+			HaveField("Object", "Role missing/foobar"),
+			HaveField("Object", "RoleBinding missing/foobar"),
+		))
+		Expect(problemsOf(problems, doctor.CheckSecret)).To(ConsistOf(
+			HaveField("Message", ContainSubstring("has not been populated with a token")),
+		))
+		Expect(problemsOf(problems, doctor.CheckBinding)).To(ConsistOf(
+			HaveField("Message", ContainSubstring("binds ServiceAccount default/someone-else instead of ServiceAccount default/foobar")),
+		))
+		Expect(problemsOf(problems, doctor.CheckAccess)).To(ConsistOf(
+			HaveField("Message", ContainSubstring(`get pods in namespace "missing"`)),
+		))
+		Expect(problemsOf(problems, doctor.CheckOrphan)).To(ConsistOf(
+			HaveField("Fix", ContainSubstring("kubectl delete clusterrole.rbac.authorization.k8s.io foobar-default")),
+		))
+		for _, p := range problems {
+			Expect(p.Fix).ToNot(BeEmpty())
+		}
+	})
+
+	It("should report leaked objects of a deleted AccessToken", func() {
+		objs := []client.Object{}
+		for _, o := range accesstoken.Render(accessToken) {
+			if _, ok := o.(*rbacv1.Role); ok {
+				objs = append(objs, o)
+			}
+		}
+
+		problems, err := doctor.New(newClient(objs...)).Diagnose(ctx, client.ObjectKeyFromObject(accessToken))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(problemsOf(problems, doctor.CheckAccessToken)).To(HaveLen(1))
+		Expect(problemsOf(problems, doctor.CheckOrphan)).To(ConsistOf(
+			HaveField("Object", "Role default/foobar"),
+			HaveField("Object", "Role missing/foobar"),
+		))
+	})
+})
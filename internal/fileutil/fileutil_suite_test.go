@@ -0,0 +1,13 @@
+package fileutil_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestFileUtil(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "FileUtil Suite")
+}
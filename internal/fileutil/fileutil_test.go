@@ -0,0 +1,31 @@
+package fileutil_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/internal/fileutil"
+)
+
+var _ = Describe("WriteFileAtomic", func() {
+	It("should replace the file and leave no temporary files behind", func() {
+		dir := GinkgoT().TempDir()
+		path := filepath.Join(dir, "token")
+		Expect(os.WriteFile(path, []byte("old"), 0o644)).To(Succeed())
+
+		Expect(fileutil.WriteFileAtomic(path, []byte("new"), 0o600)).To(Succeed())
+
+		Expect(os.ReadFile(path)).To(Equal([]byte("new")))
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+		Expect(os.ReadDir(dir)).To(HaveLen(1))
+	})
+
+	It("should fail if the directory doesn't exist", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "missing", "token")
+		Expect(fileutil.WriteFileAtomic(path, []byte("new"), 0o600)).To(MatchError(ContainSubstring("creating temporary file")))
+	})
+})
@@ -0,0 +1,88 @@
+// Package inventory summarizes which AccessTokens can do what, and where.
+package inventory
+
+import (
+	"slices"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
+	"github.com/reddit/achilles-token-controller/internal/risk"
+	"github.com/reddit/achilles-token-controller/internal/tokenmetrics"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Entry describes the reach of an AccessToken.
+type Entry struct {
+	// Namespace of the AccessToken.
+	Namespace string `json:"namespace"`
+
+	// Name of the AccessToken.
+	Name string `json:"name"`
+
+	// ServiceAccount the token authenticates as, in "namespace/name" form.
+	ServiceAccount string `json:"serviceAccount"`
+
+	// State of the AccessToken, one of the tokenmetrics states.
+	State string `json:"state"`
+
+	// Namespaces the token is granted namespaced permissions in.
+	Namespaces []string `json:"namespaces"`
+
+	// ClusterScoped is true if the token is granted cluster scoped permissions.
+	ClusterScoped bool `json:"clusterScoped"`
+
+	// MaxSeverity is the highest severity of the risk findings.
+	MaxSeverity risk.Severity `json:"maxSeverity"`
+
+	// Findings are the rules of the token matching the risk catalog.
+	Findings []risk.Finding `json:"findings,omitempty"`
+
+	// Created is the creation time of the AccessToken.
+	Created metav1.Time `json:"created"`
+
+	// Expires is the time the token expires. It's nil for long-lived ServiceAccount tokens, which are valid until their
+	// AccessToken is deleted, and set for tokens delivered encrypted (see `spec.delivery`), which are reissued before
+	// they expire.
+	Expires *metav1.Time `json:"expires"`
+}
+
+// Build returns an entry for every AccessToken. The reach of a token is derived from the objects the controller
+// provisions for it rather than from the RBAC objects in the cluster.
+func Build(accessTokens []v1alpha1.AccessToken) []Entry {
+	entries := make([]Entry, 0, len(accessTokens))
+	for i := range accessTokens {
+		at := &accessTokens[i]
+
+		entry := Entry{
+			Namespace: at.GetNamespace(),
+			Name:      at.GetName(),
+			State:     tokenmetrics.State(at),
+			Findings:  risk.Classify(at.Spec),
+			Created:   at.GetCreationTimestamp(),
+		}
+		if at.Spec.Delivery != nil {
+			entry.Expires = at.Status.EncryptedTokenExpirationTimestamp
+		}
+		entry.MaxSeverity = risk.MaxSeverity(entry.Findings)
+
+		for _, o := range accesstoken.Render(at) {
+			switch o := o.(type) {
+			case *corev1.ServiceAccount:
+				entry.ServiceAccount = client.ObjectKeyFromObject(o).String()
+			case *rbacv1.Role:
+				if !slices.Contains(entry.Namespaces, o.GetNamespace()) {
+					entry.Namespaces = append(entry.Namespaces, o.GetNamespace())
+				}
+			case *rbacv1.ClusterRole:
+				entry.ClusterScoped = true
+			}
+		}
+		slices.Sort(entry.Namespaces)
+
+		entries = append(entries, entry)
+	}
+	return entries
+}
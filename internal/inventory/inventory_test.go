@@ -0,0 +1,65 @@
+package inventory_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/inventory"
+	"github.com/reddit/achilles-token-controller/internal/risk"
+	"github.com/reddit/achilles-token-controller/internal/tokenmetrics"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Build", func() {
+	It("should describe the reach of every AccessToken", func() {
+		expires := metav1.NewTime(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+		accessTokens := []v1alpha1.AccessToken{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "team-a"},
+				Spec: v1alpha1.AccessTokenSpec{
+					NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+						{
+							Namespace: "team-b",
+							Rules:     []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}},
+						},
+						{
+							Namespace: "team-a",
+							Rules:     []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+						},
+					},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "platform"},
+				Spec: v1alpha1.AccessTokenSpec{
+					ClusterPermissions: &v1alpha1.ClusterPermissions{
+						Rules: []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+					},
+					Delivery: &v1alpha1.Delivery{PublicKey: "key"},
+				},
+				Status: v1alpha1.AccessTokenStatus{
+					EncryptedTokenExpirationTimestamp: &expires,
+				},
+			},
+		}
+
+		entries := inventory.Build(accessTokens)
+		Expect(entries).To(HaveLen(2))
+
+		Expect(entries[0].ServiceAccount).To(Equal("team-a/reader"))
+		Expect(entries[0].State).To(Equal(tokenmetrics.StateNotReady))
+		Expect(entries[0].Namespaces).To(Equal([]string{"team-a", "team-b"}))
+		Expect(entries[0].ClusterScoped).To(BeFalse())
+		Expect(entries[0].MaxSeverity).To(Equal(risk.SeverityHigh))
+		Expect(entries[0].Expires).To(BeNil())
+
+		Expect(entries[1].ServiceAccount).To(Equal("platform/admin"))
+		Expect(entries[1].Expires).To(Equal(&expires))
+		Expect(entries[1].Namespaces).To(BeEmpty())
+		Expect(entries[1].ClusterScoped).To(BeTrue())
+		Expect(entries[1].MaxSeverity).To(Equal(risk.SeverityCritical))
+	})
+})
@@ -0,0 +1,143 @@
+// Package kubeconfig assembles kubeconfigs that authenticate with AccessToken ServiceAccount tokens.
+package kubeconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Token is a ServiceAccount token along with the cluster it authenticates against.
+type Token struct {
+	// Server is the URL of the API server.
+	Server string
+
+	// CAData is the PEM encoded CA bundle used to verify the API server's certificate.
+	CAData []byte
+
+	// Token is the bearer token.
+	Token string
+
+	// Namespace is the default namespace of the context, may be empty.
+	Namespace string
+}
+
+// TokenFromSecret reads the token and CA bundle from a ServiceAccount token Secret. caData is used if the Secret doesn't
+// hold a CA bundle.
+func TokenFromSecret(secret *corev1.Secret, server string, caData []byte) (Token, error) {
+	token := secret.Data[corev1.ServiceAccountTokenKey]
+	if len(token) == 0 {
+		return Token{}, fmt.Errorf("%T %s has no %q key, the token may not have been issued yet",
+			secret, client.ObjectKeyFromObject(secret), corev1.ServiceAccountTokenKey)
+	}
+	if ca := secret.Data[corev1.ServiceAccountRootCAKey]; len(ca) > 0 {
+		caData = ca
+	}
+	return Token{
+		Server: server,
+		CAData: caData,
+		Token:  string(token),
+	}, nil
+}
+
+// New returns a kubeconfig whose cluster, user and context are all named name, with the context selected.
+func New(name string, t Token) *clientcmdapi.Config {
+	cfg := clientcmdapi.NewConfig()
+
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = t.Server
+	cluster.CertificateAuthorityData = t.CAData
+	cfg.Clusters[name] = cluster
+
+	user := clientcmdapi.NewAuthInfo()
+	user.Token = t.Token
+	cfg.AuthInfos[name] = user
+
+	context := clientcmdapi.NewContext()
+	context.Cluster = name
+	context.AuthInfo = name
+	context.Namespace = t.Namespace
+	cfg.Contexts[name] = context
+
+	cfg.CurrentContext = name
+	return cfg
+}
+
+// Merge adds the clusters, users and contexts of src to dst, replacing entries of the same name. dst's current context
+// is replaced by src's if setCurrent is true or dst has none.
+func Merge(dst, src *clientcmdapi.Config, setCurrent bool) {
+	for name, cluster := range src.Clusters {
+		dst.Clusters[name] = cluster
+	}
+	for name, user := range src.AuthInfos {
+		dst.AuthInfos[name] = user
+	}
+	for name, context := range src.Contexts {
+		dst.Contexts[name] = context
+	}
+	if setCurrent || dst.CurrentContext == "" {
+		dst.CurrentContext = src.CurrentContext
+	}
+}
+
+// MergeIntoFile merges cfg into the kubeconfig file at path, creating the file if it doesn't exist.
+func MergeIntoFile(path string, cfg *clientcmdapi.Config, setCurrent bool) error {
+	existing, err := clientcmd.LoadFromFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		existing = clientcmdapi.NewConfig()
+	} else if err != nil {
+		return fmt.Errorf("loading kubeconfig %s: %w", path, err)
+	}
+
+	Merge(existing, cfg, setCurrent)
+
+	if err := clientcmd.WriteToFile(*existing, path); err != nil {
+		return fmt.Errorf("writing kubeconfig %s: %w", path, err)
+	}
+	return nil
+}
+
+// InlineRESTConfig parses an untrusted kubeconfig into a rest.Config. Only credentials and CA bundles given inline are
+// accepted: exec plugins and auth providers would run or load code in the reading process, and file paths would read
+// its local files, e.g. its own ServiceAccount token, and send them to a server chosen by the kubeconfig's author.
+func InlineRESTConfig(data []byte) (*rest.Config, error) {
+	cfg, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, cluster := range cfg.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return nil, fmt.Errorf("cluster %q: certificate-authority files aren't allowed, use certificate-authority-data", name)
+		}
+	}
+	for name, user := range cfg.AuthInfos {
+		switch {
+		case user.Exec != nil:
+			return nil, fmt.Errorf("user %q: exec plugins aren't allowed", name)
+		case user.AuthProvider != nil:
+			return nil, fmt.Errorf("user %q: auth providers aren't allowed", name)
+		case user.TokenFile != "":
+			return nil, fmt.Errorf("user %q: tokenFile isn't allowed, use token", name)
+		case user.ClientCertificate != "":
+			return nil, fmt.Errorf("user %q: client-certificate files aren't allowed, use client-certificate-data", name)
+		case user.ClientKey != "":
+			return nil, fmt.Errorf("user %q: client-key files aren't allowed, use client-key-data", name)
+		}
+	}
+
+	restConfig, err := clientcmd.NewDefaultClientConfig(*cfg, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if restConfig.Host == "" {
+		return nil, errors.New("no server is set")
+	}
+	return restConfig, nil
+}
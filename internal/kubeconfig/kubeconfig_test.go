@@ -0,0 +1,118 @@
+package kubeconfig_test
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/internal/kubeconfig"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+var _ = Describe("Kubeconfig", func() {
+	It("should read the token and CA bundle from a token Secret", func() {
+		secret := &corev1.Secret{Data: map[string][]byte{
+			corev1.ServiceAccountTokenKey:  []byte("token"),
+			corev1.ServiceAccountRootCAKey: []byte("secret-ca"),
+		}}
+
+		token, err := kubeconfig.TokenFromSecret(secret, "https://example.com", []byte("fallback-ca"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.Token).To(Equal("token"))
+		Expect(token.CAData).To(Equal([]byte("secret-ca")))
+
+		delete(secret.Data, corev1.ServiceAccountRootCAKey)
+		token, err = kubeconfig.TokenFromSecret(secret, "https://example.com", []byte("fallback-ca"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.CAData).To(Equal([]byte("fallback-ca")))
+
+		delete(secret.Data, corev1.ServiceAccountTokenKey)
+		_, err = kubeconfig.TokenFromSecret(secret, "https://example.com", nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should merge contexts into a kubeconfig file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "config")
+
+		Expect(kubeconfig.MergeIntoFile(path, kubeconfig.New("first", kubeconfig.Token{
+			Server:    "https://first.example.com",
+			Token:     "first-token",
+			Namespace: "default",
+		}), false)).To(Succeed())
+		Expect(kubeconfig.MergeIntoFile(path, kubeconfig.New("second", kubeconfig.Token{
+			Server: "https://second.example.com",
+			Token:  "second-token",
+		}), false)).To(Succeed())
+
+		cfg, err := clientcmd.LoadFromFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Contexts).To(HaveLen(2))
+		Expect(cfg.CurrentContext).To(Equal("first"))
+		Expect(cfg.Contexts["first"].Namespace).To(Equal("default"))
+		Expect(cfg.Clusters["second"].Server).To(Equal("https://second.example.com"))
+		Expect(cfg.AuthInfos["second"].Token).To(Equal("second-token"))
+
+		Expect(kubeconfig.MergeIntoFile(path, kubeconfig.New("second", kubeconfig.Token{
+			Server: "https://second.example.com",
+			Token:  "rotated-token",
+		}), true)).To(Succeed())
+
+		cfg, err = clientcmd.LoadFromFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.CurrentContext).To(Equal("second"))
+		Expect(cfg.AuthInfos["second"].Token).To(Equal("rotated-token"))
+	})
+
+	Describe("InlineRESTConfig", func() {
+		inline := func(mutate func(cluster *clientcmdapi.Cluster, user *clientcmdapi.AuthInfo)) []byte {
+			cfg := kubeconfig.New("remote", kubeconfig.Token{
+				Server: "https://remote.example.com",
+				CAData: []byte("ca"),
+				Token:  "token",
+			})
+			mutate(cfg.Clusters["remote"], cfg.AuthInfos["remote"])
+			b, err := clientcmd.Write(*cfg)
+			Expect(err).ToNot(HaveOccurred())
+			return b
+		}
+
+		It("should accept inline credentials", func() {
+			cfg, err := kubeconfig.InlineRESTConfig(inline(func(*clientcmdapi.Cluster, *clientcmdapi.AuthInfo) {}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Host).To(Equal("https://remote.example.com"))
+			Expect(cfg.BearerToken).To(Equal("token"))
+			Expect(cfg.CAData).To(Equal([]byte("ca")))
+		})
+
+		DescribeTable("should reject credentials that aren't inline",
+			func(mutate func(cluster *clientcmdapi.Cluster, user *clientcmdapi.AuthInfo), reason string) {
+				_, err := kubeconfig.InlineRESTConfig(inline(mutate))
+				Expect(err).To(MatchError(ContainSubstring(reason)))
+			},
+			Entry("exec plugins", func(_ *clientcmdapi.Cluster, u *clientcmdapi.AuthInfo) {
+				u.Token = ""
+				u.Exec = &clientcmdapi.ExecConfig{Command: "sh", APIVersion: "client.authentication.k8s.io/v1"}
+			}, "exec"),
+			Entry("auth providers", func(_ *clientcmdapi.Cluster, u *clientcmdapi.AuthInfo) {
+				u.Token = ""
+				u.AuthProvider = &clientcmdapi.AuthProviderConfig{Name: "oidc"}
+			}, "auth providers"),
+			Entry("token files", func(_ *clientcmdapi.Cluster, u *clientcmdapi.AuthInfo) {
+				u.Token = ""
+				u.TokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+			}, "tokenFile"),
+			Entry("client certificate files", func(_ *clientcmdapi.Cluster, u *clientcmdapi.AuthInfo) {
+				u.ClientCertificate = "/etc/tls.crt"
+			}, "client-certificate"),
+			Entry("client key files", func(_ *clientcmdapi.Cluster, u *clientcmdapi.AuthInfo) {
+				u.ClientKey = "/etc/tls.key"
+			}, "client-key"),
+			Entry("CA files", func(c *clientcmdapi.Cluster, _ *clientcmdapi.AuthInfo) {
+				c.CertificateAuthorityData = nil
+				c.CertificateAuthority = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+			}, "certificate-authority"),
+		)
+	})
+})
@@ -0,0 +1,169 @@
+// Package manifest reads and writes Kubernetes manifests for the controller's CLI subcommands.
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+// StdinPath is the path that selects stdin for ReadFiles.
+const StdinPath = "-"
+
+// extensions are the file extensions read when walking a directory.
+var extensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// Source is an object read from a manifest along with the file it was read from.
+type Source struct {
+	// Object is the decoded object.
+	Object client.Object
+
+	// Path of the file the object was read from.
+	Path string
+}
+
+// ReadFiles decodes every object of a kind registered in the scheme from the provided files and directories.
+// Directories are walked recursively for YAML and JSON files, and StdinPath reads from stdin.
+// Objects of kinds unknown to the scheme are skipped.
+func ReadFiles(scheme *runtime.Scheme, stdin io.Reader, paths ...string) ([]Source, error) {
+	var sources []Source
+	for _, path := range paths {
+		if path == StdinPath {
+			objs, err := Read(scheme, stdin)
+			if err != nil {
+				return nil, fmt.Errorf("reading stdin: %w", err)
+			}
+			for _, o := range objs {
+				sources = append(sources, Source{Object: o, Path: path})
+			}
+			continue
+		}
+
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// read files passed explicitly regardless of their extension
+			if d.IsDir() || (p != path && !extensions[strings.ToLower(filepath.Ext(p))]) {
+				return nil
+			}
+
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			objs, err := Read(scheme, f)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", p, err)
+			}
+			for _, o := range objs {
+				sources = append(sources, Source{Object: o, Path: p})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+// Read decodes every object of a kind registered in the scheme from a stream of YAML or JSON documents.
+// Objects of kinds unknown to the scheme are skipped.
+func Read(scheme *runtime.Scheme, r io.Reader) ([]client.Object, error) {
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+
+	var objs []client.Object
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		// skip documents consisting only of comments
+		var probe map[string]interface{}
+		if err := yaml.Unmarshal(doc, &probe); err != nil {
+			return nil, err
+		}
+		if len(probe) == 0 {
+			continue
+		}
+
+		obj, _, err := decoder.Decode(doc, nil, nil)
+		if err != nil {
+			if runtime.IsNotRegisteredError(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		cObj, ok := obj.(client.Object)
+		if !ok {
+			continue
+		}
+		objs = append(objs, cObj)
+	}
+}
+
+// WriteYAML writes the objects as a stream of YAML documents, with apiVersion and kind populated from the scheme.
+// Empty creation timestamps and statuses are omitted.
+func WriteYAML(scheme *runtime.Scheme, w io.Writer, objs ...client.Object) error {
+	for i, o := range objs {
+		gvk, err := apiutil.GVKForObject(o, scheme)
+		if err != nil {
+			return err
+		}
+
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+		if err != nil {
+			return fmt.Errorf("converting %T %s: %w", o, client.ObjectKeyFromObject(o), err)
+		}
+		u["apiVersion"], u["kind"] = gvk.ToAPIVersionAndKind()
+		unstructured.RemoveNestedField(u, "metadata", "creationTimestamp")
+		if status, ok := u["status"].(map[string]interface{}); ok && len(status) == 0 {
+			delete(u, "status")
+		}
+
+		b, err := yaml.Marshal(u)
+		if err != nil {
+			return fmt.Errorf("marshalling %T %s: %w", o, client.ObjectKeyFromObject(o), err)
+		}
+
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+// Package risk classifies the permissions granted by AccessTokens against a built-in catalog of dangerous grants.
+package risk
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Severity ranks how dangerous a grant is.
+type Severity int
+
+const (
+	SeverityNone Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityNone:     "none",
+	SeverityLow:      "low",
+	SeverityMedium:   "medium",
+	SeverityHigh:     "high",
+	SeverityCritical: "critical",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText encodes the severity by name.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses a severity by name.
+func ParseSeverity(name string) (Severity, error) {
+	for s, n := range severityNames {
+		if strings.EqualFold(n, name) {
+			return s, nil
+		}
+	}
+	return SeverityNone, fmt.Errorf("unknown severity %q", name)
+}
+
+// Check is an entry of the risk catalog.
+type Check struct {
+	// ID uniquely identifies the check.
+	ID string `json:"id"`
+
+	// Severity of grants matching the check.
+	Severity Severity `json:"severity"`
+
+	// Description of the risk.
+	Description string `json:"description"`
+
+	// matches returns true if the rule, granted cluster-wide if clusterScoped is true, is dangerous.
+	matches func(rule rbacv1.PolicyRule, clusterScoped bool) bool
+}
+
+// Finding is a rule matching a check of the catalog.
+type Finding struct {
+	Check
+
+	// Namespace the rule is granted in, empty for cluster scoped rules.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Path locates the rule within the AccessToken, e.g. "spec.namespacedPermissions[0].rules[1]".
+	Path string `json:"path"`
+
+	// Rule is the offending rule.
+	Rule rbacv1.PolicyRule `json:"rule"`
+}
+
+// String returns a single line description of the finding.
+func (f Finding) String() string {
+	scope := "cluster-wide"
+	if f.Namespace != "" {
+		scope = fmt.Sprintf("in namespace %q", f.Namespace)
+	}
+	return fmt.Sprintf("[%s] %s: %s %s (%s)", f.Severity, f.ID, f.Description, scope, f.Path)
+}
+
+var readVerbs = []string{"get", "list", "watch"}
+
+var writeVerbs = []string{"create", "update", "patch", "delete", "deletecollection"}
+
+// Catalog lists all checks, ordered by descending severity.
+var Catalog = []Check{
+	{
+		ID:          "rbac-escalation",
+		Severity:    SeverityCritical,
+		Description: "allows escalating privileges by binding or editing roles beyond the token's own permissions",
+		matches: func(rule rbacv1.PolicyRule, _ bool) bool {
+			return grants(rule, rbacv1.GroupName, []string{"roles", "clusterroles"}, "escalate", "bind")
+		},
+	},
+	{
+		ID:          "impersonation",
+		Severity:    SeverityCritical,
+		Description: "allows impersonating other users, groups, or ServiceAccounts",
+		matches: func(rule rbacv1.PolicyRule, _ bool) bool {
+			return grants(rule, "", []string{"users", "groups", "serviceaccounts"}, "impersonate") ||
+				grants(rule, "authentication.k8s.io", []string{"userextras", "uids"}, "impersonate")
+		},
+	},
+	{
+		ID:          "nodes-proxy",
+		Severity:    SeverityCritical,
+		Description: "allows proxying requests to the kubelet API, which bypasses admission and audit",
+		matches: func(rule rbacv1.PolicyRule, _ bool) bool {
+			return grants(rule, "", []string{"nodes/proxy"}, readVerbs...) ||
+				grants(rule, "", []string{"nodes/proxy"}, writeVerbs...)
+		},
+	},
+	{
+		ID:          "wildcard-verbs",
+		Severity:    SeverityHigh,
+		Description: "grants all verbs, including verbs added in future Kubernetes versions",
+		matches: func(rule rbacv1.PolicyRule, _ bool) bool {
+			return slices.Contains(rule.Verbs, rbacv1.VerbAll)
+		},
+	},
+	{
+		ID:          "wildcard-resources",
+		Severity:    SeverityHigh,
+		Description: "grants access to all resources, including resources added in the future",
+		matches: func(rule rbacv1.PolicyRule, _ bool) bool {
+			return slices.Contains(rule.Resources, rbacv1.ResourceAll)
+		},
+	},
+	{
+		ID:          "secrets-read",
+		Severity:    SeverityHigh,
+		Description: "allows reading Secrets, which may contain credentials for other identities",
+		matches: func(rule rbacv1.PolicyRule, _ bool) bool {
+			return grants(rule, "", []string{"secrets"}, readVerbs...)
+		},
+	},
+	{
+		ID:          "pods-exec",
+		Severity:    SeverityHigh,
+		Description: "allows executing commands in or attaching to running containers",
+		matches: func(rule rbacv1.PolicyRule, _ bool) bool {
+			return grants(rule, "", []string{"pods/exec", "pods/attach"}, "create", "get")
+		},
+	},
+	{
+		ID:          "serviceaccount-token",
+		Severity:    SeverityHigh,
+		Description: "allows minting tokens for other ServiceAccounts",
+		matches: func(rule rbacv1.PolicyRule, _ bool) bool {
+			return grants(rule, "", []string{"serviceaccounts/token"}, "create")
+		},
+	},
+	{
+		ID:          "cluster-write",
+		Severity:    SeverityMedium,
+		Description: "allows modifying resources across the whole cluster",
+		matches: func(rule rbacv1.PolicyRule, clusterScoped bool) bool {
+			return clusterScoped && len(rule.Resources) > 0 && hasAnyVerb(rule, writeVerbs...)
+		},
+	},
+	{
+		ID:          "non-resource-urls",
+		Severity:    SeverityLow,
+		Description: "grants access to non-resource URLs of the API server",
+		matches: func(rule rbacv1.PolicyRule, _ bool) bool {
+			return len(rule.NonResourceURLs) > 0
+		},
+	},
+}
+
+// ClassifyRules returns the findings for rules granted in the namespace, or cluster-wide if namespace is empty.
+// path locates the rules within their AccessToken.
+func ClassifyRules(rules []rbacv1.PolicyRule, namespace, path string) []Finding {
+	var findings []Finding
+	for i, rule := range rules {
+		for _, check := range Catalog {
+			if !check.matches(rule, namespace == "") {
+				continue
+			}
+			findings = append(findings, Finding{
+				Check:     check,
+				Namespace: namespace,
+				Path:      fmt.Sprintf("%s[%d]", path, i),
+				Rule:      rule,
+			})
+		}
+	}
+	return findings
+}
+
+// Classify returns the findings for all rules granted by the AccessToken spec.
+func Classify(spec v1alpha1.AccessTokenSpec) []Finding {
+	var findings []Finding
+	for i, p := range spec.NamespacedPermissions {
+		findings = append(findings, ClassifyRules(p.Rules, p.Namespace, fmt.Sprintf("spec.namespacedPermissions[%d].rules", i))...)
+	}
+	if spec.ClusterPermissions != nil {
+		findings = append(findings, ClassifyRules(spec.ClusterPermissions.Rules, "", "spec.clusterPermissions.rules")...)
+	}
+	return findings
+}
+
+// MaxSeverity returns the highest severity among the findings.
+func MaxSeverity(findings []Finding) Severity {
+	highest := SeverityNone
+	for _, f := range findings {
+		if f.Severity > highest {
+			highest = f.Severity
+		}
+	}
+	return highest
+}
+
+// grants returns true if the rule grants any of the verbs on any of the resources in the API group, taking wildcards
+// into account.
+func grants(rule rbacv1.PolicyRule, group string, resources []string, verbs ...string) bool {
+	if !slices.Contains(rule.APIGroups, group) && !slices.Contains(rule.APIGroups, rbacv1.APIGroupAll) {
+		return false
+	}
+	if !hasAnyVerb(rule, verbs...) {
+		return false
+	}
+	for _, resource := range resources {
+		if resourceMatches(rule, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyVerb(rule rbacv1.PolicyRule, verbs ...string) bool {
+	if slices.Contains(rule.Verbs, rbacv1.VerbAll) {
+		return true
+	}
+	for _, verb := range verbs {
+		if slices.Contains(rule.Verbs, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceMatches mirrors RBAC's resource matching, where "*" matches all resources and "<resource>/*" matches all
+// subresources of a resource.
+func resourceMatches(rule rbacv1.PolicyRule, resource string) bool {
+	for _, r := range rule.Resources {
+		if r == rbacv1.ResourceAll || r == resource {
+			return true
+		}
+		if base, sub, ok := strings.Cut(resource, "/"); ok && sub != "" && r == base+"/*" {
+			return true
+		}
+	}
+	return false
+}
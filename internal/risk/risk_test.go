@@ -0,0 +1,84 @@
+package risk_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/risk"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+var _ = Describe("Classify", func() {
+	ids := func(findings []risk.Finding) []string {
+		var out []string
+		for _, f := range findings {
+			out = append(out, f.ID)
+		}
+		return out
+	}
+
+	DescribeTable("should classify namespaced rules",
+		func(rule rbacv1.PolicyRule, expected ...string) {
+			findings := risk.ClassifyRules([]rbacv1.PolicyRule{rule}, "default", "rules")
+			if len(expected) == 0 {
+				Expect(findings).To(BeEmpty())
+				return
+			}
+			Expect(ids(findings)).To(ConsistOf(expected))
+		},
+		Entry("read-only configmaps",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list"}},
+		),
+		Entry("wildcard verbs",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"*"}},
+			"wildcard-verbs",
+		),
+		Entry("reading secrets",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"list"}},
+			"secrets-read",
+		),
+		Entry("all subresources of pods",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods/*"}, Verbs: []string{"create"}},
+			"pods-exec",
+		),
+		Entry("binding roles",
+			rbacv1.PolicyRule{APIGroups: []string{rbacv1.GroupName}, Resources: []string{"roles"}, Verbs: []string{"bind"}},
+			"rbac-escalation",
+		),
+		Entry("minting ServiceAccount tokens",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"serviceaccounts/token"}, Verbs: []string{"create"}},
+			"serviceaccount-token",
+		),
+		Entry("secrets in another API group",
+			rbacv1.PolicyRule{APIGroups: []string{"example.com"}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+		),
+	)
+
+	It("should flag cluster-wide writes only for cluster scoped rules", func() {
+		rule := rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"update"}}
+
+		Expect(risk.ClassifyRules([]rbacv1.PolicyRule{rule}, "default", "rules")).To(BeEmpty())
+		Expect(ids(risk.ClassifyRules([]rbacv1.PolicyRule{rule}, "", "rules"))).To(ConsistOf("cluster-write"))
+	})
+
+	It("should locate findings within the AccessToken", func() {
+		spec := v1alpha1.AccessTokenSpec{
+			NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+				{
+					Namespace: "default",
+					Rules: []rbacv1.PolicyRule{
+						{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+						{APIGroups: []string{""}, Resources: []string{"nodes/proxy"}, Verbs: []string{"get"}},
+					},
+				},
+			},
+		}
+
+		findings := risk.Classify(spec)
+		Expect(findings).To(HaveLen(1))
+		Expect(findings[0].ID).To(Equal("nodes-proxy"))
+		Expect(findings[0].Namespace).To(Equal("default"))
+		Expect(findings[0].Path).To(Equal("spec.namespacedPermissions[0].rules[1]"))
+		Expect(risk.MaxSeverity(findings)).To(Equal(risk.SeverityCritical))
+	})
+})
@@ -0,0 +1,160 @@
+// Package tokencrypt encrypts tokens to X25519 public keys so that only the holder of the matching private key can read
+// them.
+//
+// A token is sealed with an ephemeral X25519 key agreement: the shared secret is expanded with HKDF-SHA256 into an
+// AES-256-GCM key. The sealed token is the base64 encoding of the version byte, the ephemeral public key, the nonce and
+// the ciphertext.
+package tokencrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	version = 1
+
+	// info binds the derived key to this package and version.
+	info = "achilles-token-controller tokencrypt v1"
+
+	keySize   = 32
+	nonceSize = 12
+)
+
+// GenerateKey returns a new base64 encoded X25519 private key and its public key.
+func GenerateKey() (privateKey, publicKey string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("generating X25519 key: %w", err)
+	}
+	return encode(key.Bytes()), encode(key.PublicKey().Bytes()), nil
+}
+
+// ParsePublicKey parses a base64 encoded X25519 public key.
+func ParsePublicKey(s string) (*ecdh.PublicKey, error) {
+	b, err := decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("decoding public key: %w", err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key: %w", err)
+	}
+	return key, nil
+}
+
+// ParsePrivateKey parses a base64 encoded X25519 private key.
+func ParsePrivateKey(s string) (*ecdh.PrivateKey, error) {
+	b, err := decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("decoding private key: %w", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	return key, nil
+}
+
+// Encrypt seals the token to the base64 encoded X25519 public key.
+func Encrypt(publicKey string, token []byte) (string, error) {
+	recipient, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("generating ephemeral key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return "", fmt.Errorf("agreeing on a shared secret: %w", err)
+	}
+	aead, err := newAEAD(shared, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+
+	sealed := []byte{version}
+	sealed = append(sealed, ephemeral.PublicKey().Bytes()...)
+	sealed = append(sealed, nonce...)
+	sealed = aead.Seal(sealed, nonce, token, sealed[:1])
+	return encode(sealed), nil
+}
+
+// Decrypt opens a token sealed by Encrypt with the base64 encoded X25519 private key.
+func Decrypt(privateKey, sealed string) ([]byte, error) {
+	key, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := decode(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("decoding sealed token: %w", err)
+	}
+	if len(b) < 1+keySize+nonceSize {
+		return nil, errors.New("sealed token is truncated")
+	}
+	if b[0] != version {
+		return nil, fmt.Errorf("unsupported sealed token version %d", b[0])
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(b[1 : 1+keySize])
+	if err != nil {
+		return nil, fmt.Errorf("parsing ephemeral key: %w", err)
+	}
+	shared, err := key.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("agreeing on a shared secret: %w", err)
+	}
+	aead, err := newAEAD(shared, ephemeral, key.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := b[1+keySize : 1+keySize+nonceSize]
+	token, err := aead.Open(nil, nonce, b[1+keySize+nonceSize:], b[:1])
+	if err != nil {
+		return nil, errors.New("decrypting sealed token: the private key doesn't match the public key it was sealed to")
+	}
+	return token, nil
+}
+
+// newAEAD derives the AES-GCM cipher from the X25519 shared secret. The ephemeral and recipient public keys are used as
+// the HKDF salt, binding the derived key to both.
+func newAEAD(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := append(bytes.Clone(ephemeral.Bytes()), recipient.Bytes()...)
+	key, err := hkdf.Key(sha256.New, shared, salt, info, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("deriving key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encode(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+}
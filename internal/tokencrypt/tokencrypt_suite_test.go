@@ -0,0 +1,13 @@
+package tokencrypt_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTokenCrypt(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TokenCrypt Suite")
+}
@@ -0,0 +1,69 @@
+package tokencrypt_test
+
+import (
+	"encoding/base64"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/internal/tokencrypt"
+)
+
+var _ = Describe("Encrypt", func() {
+	var privateKey, publicKey string
+
+	BeforeEach(func() {
+		var err error
+		privateKey, publicKey, err = tokencrypt.GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should round trip through Decrypt", func() {
+		sealed, err := tokencrypt.Encrypt(publicKey, []byte("token"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sealed).ToNot(ContainSubstring("token"))
+
+		token, err := tokencrypt.Decrypt(privateKey, sealed)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token).To(Equal([]byte("token")))
+	})
+
+	It("should use a fresh ephemeral key every time", func() {
+		first, err := tokencrypt.Encrypt(publicKey, []byte("token"))
+		Expect(err).ToNot(HaveOccurred())
+		second, err := tokencrypt.Encrypt(publicKey, []byte("token"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(first).ToNot(Equal(second))
+	})
+
+	It("should refuse the wrong private key", func() {
+		sealed, err := tokencrypt.Encrypt(publicKey, []byte("token"))
+		Expect(err).ToNot(HaveOccurred())
+
+		otherKey, _, err := tokencrypt.GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = tokencrypt.Decrypt(otherKey, sealed)
+		Expect(err).To(MatchError(ContainSubstring("doesn't match")))
+	})
+
+	It("should refuse tampered tokens", func() {
+		sealed, err := tokencrypt.Encrypt(publicKey, []byte("token"))
+		Expect(err).ToNot(HaveOccurred())
+
+		b, err := base64.StdEncoding.DecodeString(sealed)
+		Expect(err).ToNot(HaveOccurred())
+		b[len(b)-1] ^= 1
+		_, err = tokencrypt.Decrypt(privateKey, base64.StdEncoding.EncodeToString(b))
+		Expect(err).To(HaveOccurred())
+
+		_, err = tokencrypt.Decrypt(privateKey, base64.StdEncoding.EncodeToString(b[:10]))
+		Expect(err).To(MatchError(ContainSubstring("truncated")))
+	})
+
+	It("should refuse invalid public keys", func() {
+		_, err := tokencrypt.Encrypt("not base64!", []byte("token"))
+		Expect(err).To(HaveOccurred())
+
+		_, err = tokencrypt.Encrypt(base64.StdEncoding.EncodeToString([]byte("short")), []byte("token"))
+		Expect(err).To(MatchError(ContainSubstring("parsing public key")))
+	})
+})
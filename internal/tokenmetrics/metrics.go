@@ -0,0 +1,223 @@
+// Package tokenmetrics contains prometheus metrics describing the inventory and risk of issued access tokens.
+package tokenmetrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	namespace = "achilles_token_controller"
+
+	// collectTimeout bounds how long a single scrape may spend listing AccessTokens.
+	collectTimeout = 10 * time.Second
+)
+
+// Token states reported by the access_tokens metric.
+const (
+	StateReady    = "ready"
+	StateNotReady = "not_ready"
+	StateDeleting = "deleting"
+)
+
+// Metrics holds the token specific metrics of the controller. Gauges describing the token inventory are computed on
+// every scrape from the AccessTokens in the cache, counters are recorded by the reconciler.
+type Metrics struct {
+	staleObjectsDeleted *prometheus.CounterVec
+	revocations         *prometheus.CounterVec
+	rotations           *prometheus.CounterVec
+}
+
+// ResolveFunc returns a copy of the AccessToken with its references replaced by the permissions they stand for.
+type ResolveFunc func(ctx context.Context, at *v1alpha1.AccessToken) (*v1alpha1.AccessToken, error)
+
+// MustMakeMetrics creates the token metrics and registers them with the provided registry, panicking on error.
+// The reader is used to list AccessTokens when the registry is scraped, their rules are counted once resolved with
+// resolve.
+func MustMakeMetrics(c client.Reader, resolve ResolveFunc, reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		staleObjectsDeleted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stale_objects_deleted_total",
+			Help:      "Number of managed objects deleted because they are no longer desired by their AccessToken.",
+		}, []string{"namespace", "kind"}),
+		revocations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "token_revocations_total",
+			Help:      "Number of tokens revoked by deleting their AccessToken.",
+		}, []string{"namespace"}),
+		rotations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "token_rotations_total",
+			Help:      "Number of delivered tokens reissued to replace a previously delivered token.",
+		}, []string{"namespace"}),
+	}
+
+	reg.MustRegister(
+		m.staleObjectsDeleted,
+		m.revocations,
+		m.rotations,
+		newInventoryCollector(c, resolve),
+	)
+
+	return m
+}
+
+// RecordStaleObjectDeleted records the deletion of a stale managed object of the given kind belonging to an
+// AccessToken in the given namespace.
+func (m *Metrics) RecordStaleObjectDeleted(namespace, kind string) {
+	m.staleObjectsDeleted.WithLabelValues(namespace, kind).Inc()
+}
+
+// RecordRevocation records the revocation of a token belonging to an AccessToken in the given namespace.
+func (m *Metrics) RecordRevocation(namespace string) {
+	m.revocations.WithLabelValues(namespace).Inc()
+}
+
+// RecordRotation records the reissue of a delivered token belonging to an AccessToken in the given namespace.
+func (m *Metrics) RecordRotation(namespace string) {
+	m.rotations.WithLabelValues(namespace).Inc()
+}
+
+// inventoryCollector computes gauges over all AccessTokens at scrape time, so values such as token age are always current
+// and deleted AccessTokens never leave stale series behind.
+type inventoryCollector struct {
+	c       client.Reader
+	resolve ResolveFunc
+
+	accessTokens       *prometheus.Desc
+	tokenAge           *prometheus.Desc
+	tokenExpiry        *prometheus.Desc
+	clusterScopedRules *prometheus.Desc
+	wildcardRules      *prometheus.Desc
+}
+
+func newInventoryCollector(c client.Reader, resolve ResolveFunc) *inventoryCollector {
+	return &inventoryCollector{
+		c:       c,
+		resolve: resolve,
+		accessTokens: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "access_tokens"),
+			"Number of AccessTokens by namespace and state.",
+			[]string{"namespace", "state"}, nil,
+		),
+		tokenAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "token_age_seconds"),
+			"Seconds since the AccessToken was created.",
+			[]string{"namespace", "name"}, nil,
+		),
+		tokenExpiry: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "token_expiry_seconds"),
+			"Seconds until the token delivered in the AccessToken's status expires, negative once expired.",
+			[]string{"namespace", "name"}, nil,
+		),
+		clusterScopedRules: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "cluster_scoped_rules"),
+			"Number of cluster scoped rules granted by the AccessToken.",
+			[]string{"namespace", "name"}, nil,
+		),
+		wildcardRules: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "wildcard_rules"),
+			"Number of rules granted by the AccessToken that contain a wildcard, by rule field.",
+			[]string{"namespace", "name", "field"}, nil,
+		),
+	}
+}
+
+func (i *inventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- i.accessTokens
+	ch <- i.tokenAge
+	ch <- i.tokenExpiry
+	ch <- i.clusterScopedRules
+	ch <- i.wildcardRules
+}
+
+func (i *inventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	accessTokens := &v1alpha1.AccessTokenList{}
+	if err := i.c.List(ctx, accessTokens); err != nil {
+		ch <- prometheus.NewInvalidMetric(i.accessTokens, err)
+		return
+	}
+
+	type stateKey struct{ namespace, state string }
+	counts := map[stateKey]int{}
+	now := time.Now()
+
+	for _, at := range accessTokens.Items {
+		counts[stateKey{at.Namespace, State(&at)}]++
+
+		ch <- prometheus.MustNewConstMetric(i.tokenAge, prometheus.GaugeValue,
+			now.Sub(at.CreationTimestamp.Time).Seconds(), at.Namespace, at.Name)
+
+		if expiry := at.Status.EncryptedTokenExpirationTimestamp; expiry != nil {
+			ch <- prometheus.MustNewConstMetric(i.tokenExpiry, prometheus.GaugeValue,
+				expiry.Sub(now).Seconds(), at.Namespace, at.Name)
+		}
+
+		// rules of references that can't be resolved aren't granted, only the inline rules are counted
+		spec := at.Spec
+		if resolved, err := i.resolve(ctx, &at); err == nil {
+			spec = resolved.Spec
+		}
+
+		var clusterRules []rbacv1.PolicyRule
+		if spec.ClusterPermissions != nil {
+			clusterRules = spec.ClusterPermissions.Rules
+		}
+		ch <- prometheus.MustNewConstMetric(i.clusterScopedRules, prometheus.GaugeValue,
+			float64(len(clusterRules)), at.Namespace, at.Name)
+
+		rules := clusterRules
+		for _, p := range spec.NamespacedPermissions {
+			rules = append(rules, p.Rules...)
+		}
+		var wildcardVerbs, wildcardResources int
+		for _, rule := range rules {
+			if containsWildcard(rule.Verbs) {
+				wildcardVerbs++
+			}
+			if containsWildcard(rule.Resources) {
+				wildcardResources++
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(i.wildcardRules, prometheus.GaugeValue,
+			float64(wildcardVerbs), at.Namespace, at.Name, "verbs")
+		ch <- prometheus.MustNewConstMetric(i.wildcardRules, prometheus.GaugeValue,
+			float64(wildcardResources), at.Namespace, at.Name, "resources")
+	}
+
+	for k, count := range counts {
+		ch <- prometheus.MustNewConstMetric(i.accessTokens, prometheus.GaugeValue, float64(count), k.namespace, k.state)
+	}
+}
+
+// State returns the state of the AccessToken as reported by metrics.
+func State(at *v1alpha1.AccessToken) string {
+	switch {
+	case at.GetDeletionTimestamp() != nil:
+		return StateDeleting
+	case at.GetCondition(api.TypeReady).Status == corev1.ConditionTrue:
+		return StateReady
+	default:
+		return StateNotReady
+	}
+}
+
+func containsWildcard(values []string) bool {
+	for _, v := range values {
+		if v == rbacv1.ResourceAll {
+			return true
+		}
+	}
+	return false
+}
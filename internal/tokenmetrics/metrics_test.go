@@ -0,0 +1,125 @@
+package tokenmetrics_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/reddit/achilles-token-controller/internal/tokenmetrics"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Metrics", func() {
+	var (
+		reg     *prometheus.Registry
+		metrics *tokenmetrics.Metrics
+	)
+
+	BeforeEach(func() {
+		admin := &v1alpha1.ClusterPermissionSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+			Spec: v1alpha1.PermissionSetSpec{
+				Rules: []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+			},
+		}
+		expires := metav1.NewTime(time.Now().Add(time.Hour))
+		reader := &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "team-a"},
+			Spec: v1alpha1.AccessTokenSpec{
+				NamespacedPermissions: []v1alpha1.NamespacedPermissions{{
+					Namespace: "team-a",
+					Rules:     []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"*"}}},
+				}},
+			},
+			Status: v1alpha1.AccessTokenStatus{
+				EncryptedTokenExpirationTimestamp: &expires,
+			},
+		}
+		operator := &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "operator", Namespace: "platform"},
+			Spec: v1alpha1.AccessTokenSpec{
+				ClusterPermissions: &v1alpha1.ClusterPermissions{
+					Rules:             []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get"}}},
+					PermissionSetRefs: []v1alpha1.PermissionSetRef{{Kind: v1alpha1.ClusterPermissionSetKind, Name: "admin"}},
+				},
+			},
+		}
+		c := fake.NewClientBuilder().
+			WithScheme(intscheme.MustNewScheme()).
+			WithObjects(admin, reader, operator).
+			Build()
+
+		reg = prometheus.NewPedanticRegistry()
+		metrics = tokenmetrics.MustMakeMetrics(c, accesstoken.ClientResolveFunc(c), reg)
+	})
+
+	It("should count AccessTokens by state", func() {
+		Expect(testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP achilles_token_controller_access_tokens Number of AccessTokens by namespace and state.
+# TYPE achilles_token_controller_access_tokens gauge
+achilles_token_controller_access_tokens{namespace="platform",state="not_ready"} 1
+achilles_token_controller_access_tokens{namespace="team-a",state="not_ready"} 1
+`), "achilles_token_controller_access_tokens")).To(Succeed())
+	})
+
+	It("should count the rules of referenced permission sets", func() {
+		Expect(testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP achilles_token_controller_cluster_scoped_rules Number of cluster scoped rules granted by the AccessToken.
+# TYPE achilles_token_controller_cluster_scoped_rules gauge
+achilles_token_controller_cluster_scoped_rules{name="operator",namespace="platform"} 2
+achilles_token_controller_cluster_scoped_rules{name="reader",namespace="team-a"} 0
+# HELP achilles_token_controller_wildcard_rules Number of rules granted by the AccessToken that contain a wildcard, by rule field.
+# TYPE achilles_token_controller_wildcard_rules gauge
+achilles_token_controller_wildcard_rules{field="resources",name="operator",namespace="platform"} 1
+achilles_token_controller_wildcard_rules{field="resources",name="reader",namespace="team-a"} 0
+achilles_token_controller_wildcard_rules{field="verbs",name="operator",namespace="platform"} 1
+achilles_token_controller_wildcard_rules{field="verbs",name="reader",namespace="team-a"} 1
+`), "achilles_token_controller_cluster_scoped_rules", "achilles_token_controller_wildcard_rules")).To(Succeed())
+	})
+
+	It("should report the seconds until delivered tokens expire", func() {
+		families, err := reg.Gather()
+		Expect(err).NotTo(HaveOccurred())
+
+		var expiry []float64
+		for _, family := range families {
+			if family.GetName() != "achilles_token_controller_token_expiry_seconds" {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				Expect(m.GetLabel()).To(ContainElement(HaveField("GetValue()", "reader")))
+				expiry = append(expiry, m.GetGauge().GetValue())
+			}
+		}
+		Expect(expiry).To(HaveExactElements(BeNumerically("~", time.Hour.Seconds(), 60)))
+	})
+
+	It("should count revocations", func() {
+		metrics.RecordRevocation("team-a")
+		metrics.RecordRevocation("team-a")
+
+		Expect(testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP achilles_token_controller_token_revocations_total Number of tokens revoked by deleting their AccessToken.
+# TYPE achilles_token_controller_token_revocations_total counter
+achilles_token_controller_token_revocations_total{namespace="team-a"} 2
+`), "achilles_token_controller_token_revocations_total")).To(Succeed())
+	})
+
+	It("should count rotations", func() {
+		metrics.RecordRotation("team-a")
+
+		Expect(testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP achilles_token_controller_token_rotations_total Number of delivered tokens reissued to replace a previously delivered token.
+# TYPE achilles_token_controller_token_rotations_total counter
+achilles_token_controller_token_rotations_total{namespace="team-a"} 1
+`), "achilles_token_controller_token_rotations_total")).To(Succeed())
+	})
+})
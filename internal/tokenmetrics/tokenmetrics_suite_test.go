@@ -0,0 +1,13 @@
+package tokenmetrics_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTokenMetrics(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TokenMetrics Suite")
+}
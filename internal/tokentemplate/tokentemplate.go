@@ -0,0 +1,190 @@
+// Package tokentemplate expands AccessTokenTemplates into the concrete permissions of the AccessTokens referencing them.
+package tokentemplate
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// VariableNamespace is the namespace of the AccessToken referencing the template.
+	VariableNamespace = "namespace"
+
+	// VariableName is the name of the AccessToken referencing the template.
+	VariableName = "name"
+)
+
+// variablePattern matches a variable reference, e.g. "${namespace}".
+var variablePattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// parameterNamePattern matches valid parameter names.
+var parameterNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// Validate returns an error if the template is invalid. Parameters must have valid, unique names that don't shadow the
+// built-in variables, their patterns must compile, and every variable must be declared. Parameters used in a namespace
+// must declare a pattern or allowed values, and namespaces can't contain ${name}, so that the template can't be used to
+// reach arbitrary namespaces.
+func Validate(spec v1alpha1.AccessTokenTemplateSpec) error {
+	declared := map[string]v1alpha1.TemplateParameter{}
+	for _, p := range spec.Parameters {
+		if !parameterNamePattern.MatchString(p.Name) {
+			return fmt.Errorf("parameter name %q must match %s", p.Name, parameterNamePattern)
+		}
+		if p.Name == VariableNamespace || p.Name == VariableName {
+			return fmt.Errorf("parameter %q shadows a built-in variable", p.Name)
+		}
+		if _, ok := declared[p.Name]; ok {
+			return fmt.Errorf("parameter %q is declared twice", p.Name)
+		}
+		if _, err := compilePattern(p.Pattern); err != nil {
+			return fmt.Errorf("parameter %q: invalid pattern: %w", p.Name, err)
+		}
+		declared[p.Name] = p
+	}
+
+	for i, perms := range spec.NamespacedPermissions {
+		path := fmt.Sprintf("spec.namespacedPermissions[%d]", i)
+		for _, name := range variables(perms.Namespace) {
+			if name == VariableNamespace {
+				continue
+			}
+			// the AccessToken's author picks its name freely, it's as unconstrained as a parameter without a pattern
+			if name == VariableName {
+				return fmt.Errorf("%s.namespace: ${%s} can't be used in a namespace", path, VariableName)
+			}
+			p, ok := declared[name]
+			if !ok {
+				return fmt.Errorf("%s.namespace: undeclared variable ${%s}", path, name)
+			}
+			if p.Pattern == "" && len(p.AllowedValues) == 0 {
+				return fmt.Errorf("%s.namespace: parameter %q must declare a pattern or allowed values to be used in a namespace", path, name)
+			}
+		}
+		for j, rule := range perms.Rules {
+			for _, s := range ruleStrings(rule) {
+				for _, name := range variables(s) {
+					if _, ok := declared[name]; !ok && name != VariableNamespace && name != VariableName {
+						return fmt.Errorf("%s.rules[%d]: undeclared variable ${%s}", path, j, name)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Expand returns the namespaced permissions of the template for the AccessToken with the namespace and name, given the
+// parameters it supplies. Parameter values must be DNS-1123 labels, so they can't expand to wildcards, lists or paths,
+// and must satisfy the pattern and allowed values of their parameter.
+func Expand(
+	spec v1alpha1.AccessTokenTemplateSpec,
+	namespace, name string,
+	parameters map[string]string,
+) ([]v1alpha1.NamespacedPermissions, error) {
+	if err := Validate(spec); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	values := map[string]string{
+		VariableNamespace: namespace,
+		VariableName:      name,
+	}
+	for _, p := range spec.Parameters {
+		value, ok := parameters[p.Name]
+		if !ok {
+			if p.Default == nil {
+				return nil, fmt.Errorf("parameter %q is required", p.Name)
+			}
+			value = *p.Default
+		}
+		if err := validateValue(p, value); err != nil {
+			return nil, err
+		}
+		values[p.Name] = value
+	}
+	for k := range parameters {
+		if !slices.ContainsFunc(spec.Parameters, func(p v1alpha1.TemplateParameter) bool { return p.Name == k }) {
+			return nil, fmt.Errorf("unknown parameter %q", k)
+		}
+	}
+
+	expand := func(s string) string {
+		return variablePattern.ReplaceAllStringFunc(s, func(v string) string {
+			return values[variablePattern.FindStringSubmatch(v)[1]]
+		})
+	}
+	expandAll := func(ss []string) []string {
+		if ss == nil {
+			return nil
+		}
+		out := make([]string, 0, len(ss))
+		for _, s := range ss {
+			out = append(out, expand(s))
+		}
+		return out
+	}
+
+	permissions := make([]v1alpha1.NamespacedPermissions, 0, len(spec.NamespacedPermissions))
+	for _, perms := range spec.NamespacedPermissions {
+		expanded := v1alpha1.NamespacedPermissions{Namespace: expand(perms.Namespace)}
+		if errs := validation.IsDNS1123Label(expanded.Namespace); len(errs) > 0 {
+			return nil, fmt.Errorf("namespace %q expands to invalid namespace %q: %s", perms.Namespace, expanded.Namespace, strings.Join(errs, ", "))
+		}
+		for _, rule := range perms.Rules {
+			expanded.Rules = append(expanded.Rules, rbacv1.PolicyRule{
+				Verbs:           expandAll(rule.Verbs),
+				APIGroups:       expandAll(rule.APIGroups),
+				Resources:       expandAll(rule.Resources),
+				ResourceNames:   expandAll(rule.ResourceNames),
+				NonResourceURLs: expandAll(rule.NonResourceURLs),
+			})
+		}
+		permissions = append(permissions, expanded)
+	}
+	return permissions, nil
+}
+
+func validateValue(p v1alpha1.TemplateParameter, value string) error {
+	if errs := validation.IsDNS1123Label(value); len(errs) > 0 {
+		return fmt.Errorf("parameter %q: value %q is invalid: %s", p.Name, value, strings.Join(errs, ", "))
+	}
+	if len(p.AllowedValues) > 0 && !slices.Contains(p.AllowedValues, value) {
+		return fmt.Errorf("parameter %q: value %q is not one of %v", p.Name, value, p.AllowedValues)
+	}
+	pattern, err := compilePattern(p.Pattern)
+	if err != nil {
+		return err
+	}
+	if pattern != nil && !pattern.MatchString(value) {
+		return fmt.Errorf("parameter %q: value %q doesn't match %s", p.Name, value, p.Pattern)
+	}
+	return nil
+}
+
+// compilePattern compiles the pattern anchored to the whole value, nil if there's no pattern.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// variables returns the names of the variables referenced by s.
+func variables(s string) []string {
+	var names []string
+	for _, m := range variablePattern.FindAllStringSubmatch(s, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+func ruleStrings(rule rbacv1.PolicyRule) []string {
+	return slices.Concat(rule.Verbs, rule.APIGroups, rule.Resources, rule.ResourceNames, rule.NonResourceURLs)
+}
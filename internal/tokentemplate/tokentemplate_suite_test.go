@@ -0,0 +1,13 @@
+package tokentemplate_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTokenTemplate(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TokenTemplate Suite")
+}
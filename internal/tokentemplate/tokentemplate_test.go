@@ -0,0 +1,106 @@
+package tokentemplate_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/tokentemplate"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/utils/ptr"
+)
+
+var _ = Describe("Expand", func() {
+	var spec v1alpha1.AccessTokenTemplateSpec
+
+	BeforeEach(func() {
+		spec = v1alpha1.AccessTokenTemplateSpec{
+			Parameters: []v1alpha1.TemplateParameter{
+				{Name: "app"},
+				{Name: "env", AllowedValues: []string{"staging", "prod"}, Default: ptr.To("staging")},
+			},
+			NamespacedPermissions: []v1alpha1.TemplatedPermissions{
+				{
+					Namespace: "${namespace}-${env}",
+					Rules: []rbacv1.PolicyRule{
+						{
+							APIGroups:     []string{"apps"},
+							Resources:     []string{"deployments"},
+							ResourceNames: []string{"${app}", "${name}"},
+							Verbs:         []string{"get", "patch"},
+						},
+					},
+				},
+			},
+		}
+	})
+
+	It("should substitute variables and parameters", func() {
+		permissions, err := tokentemplate.Expand(spec, "team-a", "deployer", map[string]string{"app": "web", "env": "prod"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(permissions).To(Equal([]v1alpha1.NamespacedPermissions{
+			{
+				Namespace: "team-a-prod",
+				Rules: []rbacv1.PolicyRule{
+					{
+						APIGroups:     []string{"apps"},
+						Resources:     []string{"deployments"},
+						ResourceNames: []string{"web", "deployer"},
+						Verbs:         []string{"get", "patch"},
+					},
+				},
+			},
+		}))
+	})
+
+	It("should default parameters", func() {
+		permissions, err := tokentemplate.Expand(spec, "team-a", "deployer", map[string]string{"app": "web"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(permissions[0].Namespace).To(Equal("team-a-staging"))
+	})
+
+	DescribeTable("should reject invalid parameters",
+		func(parameters map[string]string, message string) {
+			_, err := tokentemplate.Expand(spec, "team-a", "deployer", parameters)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("missing required parameter", map[string]string{}, `parameter "app" is required`),
+		Entry("unknown parameter", map[string]string{"app": "web", "other": "x"}, `unknown parameter "other"`),
+		Entry("disallowed value", map[string]string{"app": "web", "env": "kube-system"}, "is not one of"),
+		Entry("wildcard", map[string]string{"app": "*"}, `value "*" is invalid`),
+		Entry("list", map[string]string{"app": "web,api"}, `value "web,api" is invalid`),
+	)
+
+	DescribeTable("should reject invalid templates",
+		func(mutate func(*v1alpha1.AccessTokenTemplateSpec), message string) {
+			mutate(&spec)
+			Expect(tokentemplate.Validate(spec)).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("unconstrained parameter in a namespace", func(s *v1alpha1.AccessTokenTemplateSpec) {
+			s.NamespacedPermissions[0].Namespace = "${app}"
+		}, `parameter "app" must declare a pattern or allowed values`),
+		Entry("name in a namespace", func(s *v1alpha1.AccessTokenTemplateSpec) {
+			s.NamespacedPermissions[0].Namespace = "${name}"
+		}, "${name} can't be used in a namespace"),
+		Entry("undeclared variable", func(s *v1alpha1.AccessTokenTemplateSpec) {
+			s.NamespacedPermissions[0].Rules[0].ResourceNames = []string{"${team}"}
+		}, "undeclared variable ${team}"),
+		Entry("parameter shadowing a built-in variable", func(s *v1alpha1.AccessTokenTemplateSpec) {
+			s.Parameters = append(s.Parameters, v1alpha1.TemplateParameter{Name: "namespace"})
+		}, "shadows a built-in variable"),
+		Entry("invalid pattern", func(s *v1alpha1.AccessTokenTemplateSpec) {
+			s.Parameters[0].Pattern = "("
+		}, "invalid pattern"),
+	)
+
+	It("should allow constrained parameters in a namespace", func() {
+		spec.Parameters[0].Pattern = "team-[a-z]+"
+		spec.NamespacedPermissions[0].Namespace = "${app}"
+
+		_, err := tokentemplate.Expand(spec, "team-a", "deployer", map[string]string{"app": "kube-system"})
+		Expect(err).To(MatchError(ContainSubstring("doesn't match")))
+
+		permissions, err := tokentemplate.Expand(spec, "team-a", "deployer", map[string]string{"app": "team-b"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(permissions[0].Namespace).To(Equal("team-b"))
+	})
+})
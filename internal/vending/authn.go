@@ -0,0 +1,77 @@
+package vending
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// serviceAccountUsernamePrefix prefixes the usernames of ServiceAccounts, see
+// https://kubernetes.io/docs/reference/access-authn-authz/service-accounts-admin/.
+const serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+// Audience is the audience consumer tokens must be issued for. Tokens for the API server, e.g. the ServiceAccount token
+// mounted into every Pod, are refused, so that anything a consumer presents its token to can't replay it here.
+const Audience = "achilles-token-vending"
+
+// Authenticator authenticates consumers by their bearer token.
+type Authenticator interface {
+	// Authenticate returns the username the token authenticates as.
+	Authenticate(ctx context.Context, token string) (string, error)
+}
+
+// TokenReviewAuthenticator authenticates ServiceAccount tokens through the TokenReview API.
+type TokenReviewAuthenticator struct {
+	c client.Client
+}
+
+// NewTokenReviewAuthenticator returns a TokenReviewAuthenticator creating TokenReviews with c.
+func NewTokenReviewAuthenticator(c client.Client) *TokenReviewAuthenticator {
+	return &TokenReviewAuthenticator{c: c}
+}
+
+// Authenticate returns the username of the ServiceAccount the token belongs to. Tokens of other users, and tokens not
+// issued for Audience, are refused.
+func (a *TokenReviewAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: []string{Audience},
+		},
+	}
+	if err := a.c.Create(ctx, review); err != nil {
+		return "", fmt.Errorf("reviewing token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return "", fmt.Errorf("token isn't authenticated: %s", review.Status.Error)
+		}
+		return "", errors.New("token isn't authenticated")
+	}
+	// the API server authenticates the token for the intersection of the requested audiences and those it was issued for
+	if !slices.Contains(review.Status.Audiences, Audience) {
+		return "", fmt.Errorf("token isn't issued for audience %s", Audience)
+	}
+
+	username := review.Status.User.Username
+	if !strings.HasPrefix(username, serviceAccountUsernamePrefix) {
+		return "", fmt.Errorf("%s isn't a ServiceAccount", username)
+	}
+	return username, nil
+}
+
+func serviceAccountUsername(namespace, name string) string {
+	return serviceAccountUsernamePrefix + namespace + ":" + name
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	return token, ok && token != ""
+}
@@ -0,0 +1,81 @@
+package vending_test
+
+import (
+	"context"
+	"slices"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/reddit/achilles-token-controller/internal/vending"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+var _ = Describe("TokenReviewAuthenticator", func() {
+	// tokens maps tokens to the username and audiences they're issued for
+	tokens := map[string]authenticationv1.TokenReviewStatus{
+		"vending-token": {
+			User:      authenticationv1.UserInfo{Username: "system:serviceaccount:ci:runner"},
+			Audiences: []string{vending.Audience},
+		},
+		"api-server-token": {
+			User:      authenticationv1.UserInfo{Username: "system:serviceaccount:ci:runner"},
+			Audiences: []string{"https://kubernetes.default.svc"},
+		},
+		"user-token": {
+			User:      authenticationv1.UserInfo{Username: "alice"},
+			Audiences: []string{vending.Audience},
+		},
+	}
+
+	var authn *vending.TokenReviewAuthenticator
+
+	BeforeEach(func() {
+		c := fake.NewClientBuilder().
+			WithScheme(intscheme.MustNewScheme()).
+			WithInterceptorFuncs(interceptor.Funcs{
+				// authenticate like the API server, for the intersection of the requested and issued audiences
+				Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					review, ok := obj.(*authenticationv1.TokenReview)
+					if !ok {
+						return c.Create(ctx, obj, opts...)
+					}
+					status, ok := tokens[review.Spec.Token]
+					if !ok {
+						return nil
+					}
+					for _, audience := range review.Spec.Audiences {
+						if slices.Contains(status.Audiences, audience) {
+							review.Status = authenticationv1.TokenReviewStatus{
+								Authenticated: true,
+								User:          status.User,
+								Audiences:     []string{audience},
+							}
+						}
+					}
+					return nil
+				},
+			}).
+			Build()
+		authn = vending.NewTokenReviewAuthenticator(c)
+	})
+
+	It("should authenticate ServiceAccount tokens issued for the vending audience", func() {
+		username, err := authn.Authenticate(context.Background(), "vending-token")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(username).To(Equal("system:serviceaccount:ci:runner"))
+	})
+
+	DescribeTable("should refuse",
+		func(token, message string) {
+			_, err := authn.Authenticate(context.Background(), token)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("tokens issued for another audience", "api-server-token", "isn't authenticated"),
+		Entry("unknown tokens", "forged", "isn't authenticated"),
+		Entry("tokens of other users", "user-token", "isn't a ServiceAccount"),
+	)
+})
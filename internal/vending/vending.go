@@ -0,0 +1,251 @@
+// Package vending serves the tokens of AccessTokens over HTTPS to the workloads listed in their
+// `spec.allowedConsumers`, which authenticate with their own ServiceAccount tokens. Consumers don't need to be granted
+// `get secrets` on the AccessToken's namespace.
+package vending
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/audit"
+	"github.com/reddit/achilles-token-controller/internal/kubeconfig"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+
+const (
+	// TokenPath serves the bearer token of an AccessToken as plain text.
+	TokenPath = "GET /v1/namespaces/{namespace}/accesstokens/{name}/token"
+
+	// KubeconfigPath serves a kubeconfig authenticating with the bearer token of an AccessToken.
+	KubeconfigPath = "GET /v1/namespaces/{namespace}/accesstokens/{name}/kubeconfig"
+)
+
+// requestTimeout bounds the API requests made to vend a token.
+const requestTimeout = 10 * time.Second
+
+// Options configure the vending server.
+type Options struct {
+	// BindAddress is the address the server listens on.
+	BindAddress string
+
+	// CertFile and KeyFile hold the server's PEM encoded TLS certificate and key.
+	CertFile string
+	KeyFile  string
+
+	// Server is the URL of the API server written to vended kubeconfigs.
+	Server string
+
+	// CAData is the PEM encoded CA bundle written to vended kubeconfigs if the token Secret doesn't hold one.
+	CAData []byte
+}
+
+// Handler serves the vending API.
+type Handler struct {
+	c       client.Reader
+	authn   Authenticator
+	auditor *audit.Logger
+	log     *zap.SugaredLogger
+	server  string
+	caData  []byte
+	mux     *http.ServeMux
+}
+
+// NewHandler returns a Handler reading AccessTokens and their token Secrets with c and authenticating consumers with
+// authn. Every vended token is recorded with auditor.
+func NewHandler(c client.Reader, authn Authenticator, auditor *audit.Logger, log *zap.SugaredLogger, server string, caData []byte) *Handler {
+	h := &Handler{
+		c:       c,
+		authn:   authn,
+		auditor: auditor,
+		log:     log,
+		server:  server,
+		caData:  caData,
+		mux:     http.NewServeMux(),
+	}
+	h.mux.HandleFunc(TokenPath, h.serveToken)
+	h.mux.HandleFunc(KubeconfigPath, h.serveKubeconfig)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveToken(w http.ResponseWriter, r *http.Request) {
+	_, token, ok := h.vend(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(token.Token))
+}
+
+func (h *Handler) serveKubeconfig(w http.ResponseWriter, r *http.Request) {
+	key, token, ok := h.vend(w, r)
+	if !ok {
+		return
+	}
+	token.Namespace = key.Namespace
+
+	b, err := clientcmd.Write(*kubeconfig.New(fmt.Sprintf("%s-%s", key.Namespace, key.Name), token))
+	if err != nil {
+		h.fail(w, http.StatusInternalServerError, fmt.Errorf("writing kubeconfig: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(b)
+}
+
+// vend authenticates the consumer, authorizes it against the AccessToken's allowed consumers and returns the token
+// after auditing it. It writes the error response and returns false if the token can't be vended.
+func (h *Handler) vend(w http.ResponseWriter, r *http.Request) (client.ObjectKey, kubeconfig.Token, bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	key := client.ObjectKey{Namespace: r.PathValue("namespace"), Name: r.PathValue("name")}
+
+	bearer, ok := bearerToken(r)
+	if !ok {
+		h.fail(w, http.StatusUnauthorized, errors.New("a ServiceAccount token is required as bearer token"))
+		return key, kubeconfig.Token{}, false
+	}
+	consumer, err := h.authn.Authenticate(ctx, bearer)
+	if err != nil {
+		h.fail(w, http.StatusUnauthorized, err)
+		return key, kubeconfig.Token{}, false
+	}
+
+	// NOTE: missing AccessTokens are reported as forbidden so that consumers can't probe for their existence
+	forbidden := fmt.Errorf("%s may not fetch the token of AccessToken %s", consumer, key)
+	accessToken := &v1alpha1.AccessToken{}
+	if err := h.c.Get(ctx, key, accessToken); err != nil {
+		if kerrors.IsNotFound(err) {
+			h.fail(w, http.StatusForbidden, forbidden)
+		} else {
+			h.fail(w, http.StatusInternalServerError, fmt.Errorf("getting AccessToken %s: %w", key, err))
+		}
+		return key, kubeconfig.Token{}, false
+	}
+	if !allowed(accessToken, consumer) {
+		h.fail(w, http.StatusForbidden, forbidden)
+		return key, kubeconfig.Token{}, false
+	}
+
+	if accessToken.Spec.Delivery != nil {
+		h.fail(w, http.StatusConflict, fmt.Errorf("AccessToken %s delivers its token encrypted to spec.delivery.publicKey", key))
+		return key, kubeconfig.Token{}, false
+	}
+	if accessToken.Status.TokenSecretRef == nil {
+		h.fail(w, http.StatusServiceUnavailable, fmt.Errorf("AccessToken %s has no token yet", key))
+		return key, kubeconfig.Token{}, false
+	}
+	secret := &corev1.Secret{}
+	secretKey := client.ObjectKey{Namespace: key.Namespace, Name: *accessToken.Status.TokenSecretRef}
+	if err := h.c.Get(ctx, secretKey, secret); err != nil {
+		h.fail(w, http.StatusServiceUnavailable, fmt.Errorf("getting token Secret of AccessToken %s: %w", key, err))
+		return key, kubeconfig.Token{}, false
+	}
+	token, err := kubeconfig.TokenFromSecret(secret, h.server, h.caData)
+	if err != nil {
+		h.fail(w, http.StatusServiceUnavailable, err)
+		return key, kubeconfig.Token{}, false
+	}
+
+	// the token is only vended once it's been recorded
+	if err := h.auditor.Log(ctx, auditRecord(accessToken, consumer)); err != nil {
+		h.fail(w, http.StatusInternalServerError, fmt.Errorf("auditing vend: %w", err))
+		return key, kubeconfig.Token{}, false
+	}
+	h.log.Infow("vended token", "accessToken", key.String(), "consumer", consumer)
+
+	return key, token, true
+}
+
+func (h *Handler) fail(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		h.log.Errorw("vending token", "error", err)
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// allowed returns true if the username is one of the AccessToken's allowed consumers.
+func allowed(accessToken *v1alpha1.AccessToken, username string) bool {
+	for _, consumer := range accessToken.Spec.AllowedConsumers {
+		if username == serviceAccountUsername(consumer.Namespace, consumer.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func auditRecord(accessToken *v1alpha1.AccessToken, consumer string) audit.Record {
+	return audit.Record{
+		Action:               audit.ActionVend,
+		AccessTokenNamespace: accessToken.GetNamespace(),
+		AccessTokenName:      accessToken.GetName(),
+		Generation:           accessToken.GetGeneration(),
+		Subject: rbacv1.Subject{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      accessToken.GetName(),
+			Namespace: accessToken.GetNamespace(),
+		},
+		Consumer: consumer,
+	}
+}
+
+// server runs the Handler as a manager.Runnable.
+type server struct {
+	handler  http.Handler
+	opts     Options
+	shutdown time.Duration
+}
+
+var _ manager.LeaderElectionRunnable = &server{}
+
+// Setup registers the vending server with the manager. It runs on every replica, not only the leader.
+func Setup(mgr manager.Manager, auditor *audit.Logger, log *zap.SugaredLogger, opts Options) error {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return errors.New("the vending server requires a TLS certificate and key, tokens are never served in plaintext")
+	}
+
+	handler := NewHandler(mgr.GetClient(), NewTokenReviewAuthenticator(mgr.GetClient()), auditor, log, opts.Server, opts.CAData)
+	return mgr.Add(&server{handler: handler, opts: opts, shutdown: 10 * time.Second})
+}
+
+func (s *server) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.opts.BindAddress,
+		Handler:           s.handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServeTLS(s.opts.CertFile, s.opts.KeyFile)
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("serving vending endpoint: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdown)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+func (s *server) NeedLeaderElection() bool {
+	return false
+}
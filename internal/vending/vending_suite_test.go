@@ -0,0 +1,13 @@
+package vending_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestVending(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Vending Suite")
+}
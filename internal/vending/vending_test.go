@@ -0,0 +1,127 @@
+package vending_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/audit"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/reddit/achilles-token-controller/internal/vending"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// staticAuthenticator authenticates the tokens it maps to usernames.
+type staticAuthenticator map[string]string
+
+func (a staticAuthenticator) Authenticate(_ context.Context, token string) (string, error) {
+	username, ok := a[token]
+	if !ok {
+		return "", errors.New("token isn't authenticated")
+	}
+	return username, nil
+}
+
+// recordingSink keeps the records written to it.
+type recordingSink struct {
+	records []audit.Record
+}
+
+func (s *recordingSink) Write(_ context.Context, r audit.Record) error {
+	s.records = append(s.records, r)
+	return nil
+}
+
+var _ = Describe("Handler", func() {
+	var (
+		sink    *recordingSink
+		handler *vending.Handler
+	)
+
+	BeforeEach(func() {
+		accessToken := &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "team-a"},
+			Spec: v1alpha1.AccessTokenSpec{
+				AllowedConsumers: []v1alpha1.AllowedConsumer{{Namespace: "ci", Name: "runner"}},
+			},
+			Status: v1alpha1.AccessTokenStatus{
+				TokenSecretRef: ptr.To("deployer"),
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "team-a"},
+			Data: map[string][]byte{
+				corev1.ServiceAccountTokenKey:  []byte("issued-token"),
+				corev1.ServiceAccountRootCAKey: []byte("ca"),
+			},
+		}
+		c := fake.NewClientBuilder().
+			WithScheme(intscheme.MustNewScheme()).
+			WithObjects(accessToken, secret).
+			Build()
+
+		authn := staticAuthenticator{
+			"runner-token":   "system:serviceaccount:ci:runner",
+			"intruder-token": "system:serviceaccount:ci:intruder",
+		}
+		sink = &recordingSink{}
+		handler = vending.NewHandler(c, authn, audit.NewLogger(sink), zap.NewNop().Sugar(), "https://api.example.com", nil)
+	})
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	It("should vend the token to allowed consumers and audit it", func() {
+		rec := get("/v1/namespaces/team-a/accesstokens/deployer/token", "runner-token")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("issued-token"))
+
+		Expect(sink.records).To(HaveLen(1))
+		Expect(sink.records[0].Action).To(Equal(audit.ActionVend))
+		Expect(sink.records[0].AccessTokenName).To(Equal("deployer"))
+		Expect(sink.records[0].Consumer).To(Equal("system:serviceaccount:ci:runner"))
+	})
+
+	It("should vend a kubeconfig", func() {
+		rec := get("/v1/namespaces/team-a/accesstokens/deployer/kubeconfig", "runner-token")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		cfg, err := clientcmd.Load(rec.Body.Bytes())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Clusters["team-a-deployer"].Server).To(Equal("https://api.example.com"))
+		Expect(cfg.Clusters["team-a-deployer"].CertificateAuthorityData).To(Equal([]byte("ca")))
+		Expect(cfg.AuthInfos["team-a-deployer"].Token).To(Equal("issued-token"))
+		Expect(cfg.Contexts["team-a-deployer"].Namespace).To(Equal("team-a"))
+	})
+
+	It("should refuse unauthenticated requests", func() {
+		Expect(get("/v1/namespaces/team-a/accesstokens/deployer/token", "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(get("/v1/namespaces/team-a/accesstokens/deployer/token", "forged").Code).To(Equal(http.StatusUnauthorized))
+		Expect(sink.records).To(BeEmpty())
+	})
+
+	It("should refuse consumers that aren't allowed without revealing whether the AccessToken exists", func() {
+		denied := get("/v1/namespaces/team-a/accesstokens/deployer/token", "intruder-token")
+		Expect(denied.Code).To(Equal(http.StatusForbidden))
+
+		missing := get("/v1/namespaces/team-a/accesstokens/missing/token", "intruder-token")
+		Expect(missing.Code).To(Equal(http.StatusForbidden))
+		Expect(sink.records).To(BeEmpty())
+	})
+})
@@ -0,0 +1,72 @@
+// Package webhook contains the admission webhooks served by the controller manager.
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
+	"github.com/reddit/achilles-token-controller/internal/risk"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:path=/validate-group-example-com-v1alpha1-accesstoken,mutating=false,failurePolicy=ignore,sideEffects=None,groups=group.example.com,resources=accesstokens,verbs=create;update,versions=v1alpha1,name=vaccesstoken.group.example.com,admissionReviewVersions=v1
+
+// AccessTokenValidator warns about AccessToken rules matching the risk catalog, including the rules of referenced
+// templates and permission sets. It only rejects objects that aren't AccessTokens.
+type AccessTokenValidator struct {
+	c client.Reader
+}
+
+var _ admission.CustomValidator = &AccessTokenValidator{}
+
+// NewAccessTokenValidator returns an AccessTokenValidator reading referenced templates and permission sets with c.
+func NewAccessTokenValidator(c client.Reader) *AccessTokenValidator {
+	return &AccessTokenValidator{c: c}
+}
+
+// SetupAccessTokenWebhook registers the AccessToken validating webhook with the manager's webhook server.
+func SetupAccessTokenWebhook(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.AccessToken{}).
+		WithValidator(NewAccessTokenValidator(mgr.GetClient())).
+		Complete()
+}
+
+func (v *AccessTokenValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.warnings(ctx, obj)
+}
+
+func (v *AccessTokenValidator) ValidateUpdate(ctx context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return v.warnings(ctx, newObj)
+}
+
+func (v *AccessTokenValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// warnings classifies the rules of the AccessToken with its references resolved. References that can't be resolved
+// yet, e.g. because the permission set is applied along with the AccessToken, are reported as a warning and only the
+// inline rules are classified.
+func (v *AccessTokenValidator) warnings(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	accessToken, ok := obj.(*v1alpha1.AccessToken)
+	if !ok {
+		return nil, fmt.Errorf("expected %T, got %T", accessToken, obj)
+	}
+
+	var warnings admission.Warnings
+	spec := accessToken.Spec
+	if resolved, err := accesstoken.Resolve(accessToken, accesstoken.ClientResolver(ctx, v.c)); err != nil {
+		warnings = append(warnings, fmt.Sprintf("only the inline rules were checked for risky permissions: %s", err))
+	} else {
+		spec = resolved.Spec
+	}
+	for _, f := range risk.Classify(spec) {
+		warnings = append(warnings, f.String())
+	}
+	return warnings, nil
+}
@@ -0,0 +1,91 @@
+package webhook_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/reddit/achilles-token-controller/internal/webhook"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("AccessTokenValidator", func() {
+	var validator *webhook.AccessTokenValidator
+
+	BeforeEach(func() {
+		secretsReader := &v1alpha1.ClusterPermissionSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "secrets-reader"},
+			Spec: v1alpha1.PermissionSetSpec{
+				Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+			},
+		}
+		c := fake.NewClientBuilder().
+			WithScheme(intscheme.MustNewScheme()).
+			WithObjects(secretsReader).
+			Build()
+		validator = webhook.NewAccessTokenValidator(c)
+	})
+
+	newAccessToken := func(permissions v1alpha1.NamespacedPermissions) *v1alpha1.AccessToken {
+		return &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "team-a"},
+			Spec: v1alpha1.AccessTokenSpec{
+				NamespacedPermissions: []v1alpha1.NamespacedPermissions{permissions},
+			},
+		}
+	}
+
+	DescribeTable("warns",
+		func(permissions v1alpha1.NamespacedPermissions, expected ...string) {
+			warnings, err := validator.ValidateCreate(context.Background(), newAccessToken(permissions))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(HaveLen(len(expected)))
+			for i, e := range expected {
+				Expect(warnings[i]).To(ContainSubstring(e))
+			}
+		},
+		Entry("about risky inline rules",
+			v1alpha1.NamespacedPermissions{
+				Namespace: "team-a",
+				Rules:     []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods/exec"}, Verbs: []string{"create"}}},
+			},
+			"pods-exec"),
+		Entry("about risky rules of referenced permission sets",
+			v1alpha1.NamespacedPermissions{
+				Namespace:         "team-a",
+				PermissionSetRefs: []v1alpha1.PermissionSetRef{{Kind: v1alpha1.ClusterPermissionSetKind, Name: "secrets-reader"}},
+			},
+			"secrets-read"),
+		Entry("about references that can't be resolved",
+			v1alpha1.NamespacedPermissions{
+				Namespace:         "team-a",
+				PermissionSetRefs: []v1alpha1.PermissionSetRef{{Name: "missing"}},
+			},
+			"not found"),
+		Entry("about nothing for harmless rules",
+			v1alpha1.NamespacedPermissions{
+				Namespace: "team-a",
+				Rules:     []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}},
+			}),
+	)
+
+	It("admits risky rules on update", func() {
+		accessToken := newAccessToken(v1alpha1.NamespacedPermissions{
+			Namespace: "team-a",
+			Rules:     []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		})
+		warnings, err := validator.ValidateUpdate(context.Background(), accessToken, accessToken)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(ContainElements(ContainSubstring("wildcard-verbs"), ContainSubstring("wildcard-resources")))
+	})
+
+	It("denies objects that aren't AccessTokens", func() {
+		_, err := validator.ValidateCreate(context.Background(), &corev1.Pod{})
+		Expect(err).To(MatchError(ContainSubstring("expected")))
+	})
+})
@@ -0,0 +1,299 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:path=/mutate--v1-pod,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=mpod.accesstoken.group.example.com,admissionReviewVersions=v1
+
+const (
+	// podInjectionPath is the path the Pod injection webhook is served at.
+	podInjectionPath = "/mutate--v1-pod"
+
+	// injectedVolumeName is the name of the projected volume holding the injected credentials.
+	injectedVolumeName = "accesstoken"
+
+	// InjectedMountPath is the directory the injected token, ca.crt and kubeconfig files are mounted at.
+	InjectedMountPath = "/var/run/secrets/accesstoken.group.example.com"
+
+	// injectedKubeconfigFile is the name of the injected kubeconfig file, projected from the Pod's
+	// AnnotationInjectedKubeconfig annotation.
+	injectedKubeconfigFile = "kubeconfig"
+
+	// injectedServer is the URL of the API server written to injected kubeconfigs.
+	injectedServer = "https://kubernetes.default.svc"
+)
+
+// PodInjector injects the credentials of the AccessToken named by a Pod's AnnotationInject annotation into its
+// containers, as a projected volume holding the token, CA bundle and a kubeconfig, plus a KUBECONFIG env var.
+type PodInjector struct {
+	c       client.Reader
+	decoder admission.Decoder
+}
+
+var _ admission.Handler = &PodInjector{}
+
+// NewPodInjector returns a PodInjector reading AccessTokens with c and decoding Pods with the scheme's decoder.
+func NewPodInjector(c client.Reader, scheme *runtime.Scheme) *PodInjector {
+	return &PodInjector{
+		c:       c,
+		decoder: admission.NewDecoder(scheme),
+	}
+}
+
+// SetupPodInjectionWebhook registers the Pod injection webhook with the manager's webhook server.
+func SetupPodInjectionWebhook(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(podInjectionPath, &webhook.Admission{
+		Handler: NewPodInjector(mgr.GetClient(), mgr.GetScheme()),
+	})
+	return nil
+}
+
+func (p *PodInjector) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := p.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	ref, ok := pod.GetAnnotations()[v1alpha1.AnnotationInject]
+	if !ok {
+		return admission.Allowed("")
+	}
+	// the Pod may be reinvoked, or was created from the spec of an injected Pod
+	if slices.ContainsFunc(pod.Spec.Volumes, func(v corev1.Volume) bool { return v.Name == injectedVolumeName }) {
+		return admission.Allowed("credentials are already injected")
+	}
+
+	// the Pod's namespace isn't set on the object if it's inherited from the request
+	namespace := pod.GetNamespace()
+	if namespace == "" {
+		namespace = req.Namespace
+	}
+
+	key, err := parseInjectRef(namespace, ref)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	accessToken := &v1alpha1.AccessToken{}
+	if err := p.c.Get(ctx, key, accessToken); err != nil {
+		if kerrors.IsNotFound(err) {
+			return admission.Denied(fmt.Sprintf("AccessToken %s doesn't exist", key))
+		}
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("getting AccessToken %s: %w", key, err))
+	}
+
+	secretName, err := injectedSecretName(accessToken, namespace)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	containers, err := injectedContainers(pod)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	cfg, err := injectedKubeconfig(accessToken.GetNamespace())
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	inject(pod, secretName, containers, cfg)
+
+	marshaled, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// parseInjectRef parses the value of the AnnotationInject annotation, either "<name>" for an AccessToken in the Pod's
+// namespace or "<namespace>/<name>".
+func parseInjectRef(namespace, ref string) (client.ObjectKey, error) {
+	key := client.ObjectKey{Namespace: namespace, Name: ref}
+	if ns, name, ok := strings.Cut(ref, "/"); ok {
+		key = client.ObjectKey{Namespace: ns, Name: name}
+	}
+	if key.Namespace == "" || key.Name == "" || strings.Contains(key.Name, "/") {
+		return key, fmt.Errorf("annotation %s must be <name> or <namespace>/<name>, got %q", v1alpha1.AnnotationInject, ref)
+	}
+	return key, nil
+}
+
+// injectedSecretName returns the name of the Secret in the Pod's namespace holding the AccessToken's token. It's the
+// token Secret for AccessTokens in the Pod's namespace, and the mirrored Secret for AccessTokens shared with the
+// namespace through a SecretTarget. The AccessToken must be ready and its token must not have expired. The Secret may
+// not exist yet, the kubelet waits for it before starting the Pod.
+func injectedSecretName(accessToken *v1alpha1.AccessToken, namespace string) (string, error) {
+	key := client.ObjectKeyFromObject(accessToken)
+	if accessToken.GetDeletionTimestamp() != nil {
+		return "", fmt.Errorf("AccessToken %s is being deleted, its token is revoked", key)
+	}
+	if ready := accessToken.GetCondition(api.TypeReady); ready.Status != corev1.ConditionTrue {
+		return "", fmt.Errorf("AccessToken %s is not ready: %s", key, ready.Message)
+	}
+	if expiry := accessToken.Status.EncryptedTokenExpirationTimestamp; expiry != nil && !expiry.After(time.Now()) {
+		return "", fmt.Errorf("AccessToken %s's token expired at %s", key, expiry.UTC().Format(time.RFC3339))
+	}
+	if accessToken.Spec.Delivery != nil {
+		return "", fmt.Errorf("AccessToken %s delivers expiring tokens encrypted to spec.delivery.publicKey, there's no token Secret to inject", key)
+	}
+
+	if accessToken.GetNamespace() == namespace {
+		if ref := accessToken.Status.TokenSecretRef; ref != nil {
+			return *ref, nil
+		}
+		// the token Secret is named after the AccessToken
+		return accessToken.GetName(), nil
+	}
+
+	for _, target := range accessToken.Spec.SecretTargets {
+		if target.Namespace != namespace {
+			continue
+		}
+		if target.Name != "" {
+			return target.Name, nil
+		}
+		return accessToken.GetName(), nil
+	}
+	return "", fmt.Errorf("AccessToken %s isn't shared with namespace %s, it must list it in spec.secretTargets", key, namespace)
+}
+
+// injectedContainers returns the names of the containers named by the AnnotationInjectContainers annotation, or of all
+// containers if it's not set. Init containers are only injected into if they're named.
+func injectedContainers(pod *corev1.Pod) ([]string, error) {
+	value, ok := pod.GetAnnotations()[v1alpha1.AnnotationInjectContainers]
+	if !ok {
+		var names []string
+		for _, c := range pod.Spec.Containers {
+			names = append(names, c.Name)
+		}
+		return names, nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if findContainer(pod, name) == nil {
+			return nil, fmt.Errorf("annotation %s names container %q, which isn't part of the Pod", v1alpha1.AnnotationInjectContainers, name)
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("annotation %s names no containers", v1alpha1.AnnotationInjectContainers)
+	}
+	return names, nil
+}
+
+func findContainer(pod *corev1.Pod, name string) *corev1.Container {
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			if containers[i].Name == name {
+				return &containers[i]
+			}
+		}
+	}
+	return nil
+}
+
+// injectedKubeconfig returns a kubeconfig reading the token and CA bundle from the injected files, so that it stays
+// valid when the token Secret is rotated.
+func injectedKubeconfig(namespace string) (string, error) {
+	cfg := clientcmdapi.NewConfig()
+
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = injectedServer
+	cluster.CertificateAuthority = path.Join(InjectedMountPath, corev1.ServiceAccountRootCAKey)
+	cfg.Clusters[injectedVolumeName] = cluster
+
+	user := clientcmdapi.NewAuthInfo()
+	user.TokenFile = path.Join(InjectedMountPath, corev1.ServiceAccountTokenKey)
+	cfg.AuthInfos[injectedVolumeName] = user
+
+	context := clientcmdapi.NewContext()
+	context.Cluster = injectedVolumeName
+	context.AuthInfo = injectedVolumeName
+	context.Namespace = namespace
+	cfg.Contexts[injectedVolumeName] = context
+	cfg.CurrentContext = injectedVolumeName
+
+	b, err := clientcmd.Write(*cfg)
+	if err != nil {
+		return "", fmt.Errorf("writing kubeconfig: %w", err)
+	}
+	return string(b), nil
+}
+
+// inject adds the projected volume to the Pod and mounts it, along with the KUBECONFIG env var, into the named
+// containers. An existing KUBECONFIG env var is left as is.
+func inject(pod *corev1.Pod, secretName string, containers []string, kubeconfig string) {
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[v1alpha1.AnnotationInjectedKubeconfig] = kubeconfig
+
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: injectedVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{
+						Secret: &corev1.SecretProjection{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+							Items: []corev1.KeyToPath{
+								{Key: corev1.ServiceAccountTokenKey, Path: corev1.ServiceAccountTokenKey},
+								{Key: corev1.ServiceAccountRootCAKey, Path: corev1.ServiceAccountRootCAKey},
+							},
+						},
+					},
+					{
+						DownwardAPI: &corev1.DownwardAPIProjection{
+							Items: []corev1.DownwardAPIVolumeFile{
+								{
+									Path: injectedKubeconfigFile,
+									FieldRef: &corev1.ObjectFieldSelector{
+										FieldPath: fmt.Sprintf("metadata.annotations['%s']", v1alpha1.AnnotationInjectedKubeconfig),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	for _, name := range containers {
+		c := findContainer(pod, name)
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:      injectedVolumeName,
+			MountPath: InjectedMountPath,
+			ReadOnly:  true,
+		})
+		if !slices.ContainsFunc(c.Env, func(e corev1.EnvVar) bool { return e.Name == "KUBECONFIG" }) {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  "KUBECONFIG",
+				Value: path.Join(InjectedMountPath, injectedKubeconfigFile),
+			})
+		}
+	}
+}
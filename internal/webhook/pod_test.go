@@ -0,0 +1,229 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-sdk-api/api"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/reddit/achilles-token-controller/internal/webhook"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("PodInjector", func() {
+	var injector *webhook.PodInjector
+
+	BeforeEach(func() {
+		scheme := intscheme.MustNewScheme()
+		local := &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "team-a"},
+			Status: v1alpha1.AccessTokenStatus{
+				ConditionedStatus: api.ConditionedStatus{
+					Conditions: []api.Condition{{Type: api.TypeReady, Status: corev1.ConditionTrue}},
+				},
+				TokenSecretRef: ptr.To("deployer-token"),
+			},
+		}
+		shared := &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "platform"},
+			Spec: v1alpha1.AccessTokenSpec{
+				SecretTargets: []v1alpha1.SecretTarget{{Namespace: "team-a", Name: "platform-reader"}},
+			},
+			Status: v1alpha1.AccessTokenStatus{
+				ConditionedStatus: api.ConditionedStatus{
+					Conditions: []api.Condition{{Type: api.TypeReady, Status: corev1.ConditionTrue}},
+				},
+			},
+		}
+		unshared := &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "writer", Namespace: "platform"},
+			Status: v1alpha1.AccessTokenStatus{
+				ConditionedStatus: api.ConditionedStatus{
+					Conditions: []api.Condition{{Type: api.TypeReady, Status: corev1.ConditionTrue}},
+				},
+			},
+		}
+		deleting := &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "retired",
+				Namespace:         "team-a",
+				DeletionTimestamp: ptr.To(metav1.Now()),
+				Finalizers:        []string{"group.example.com/test"},
+			},
+		}
+		delivered := &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "ci", Namespace: "team-a"},
+			Spec: v1alpha1.AccessTokenSpec{
+				Delivery: &v1alpha1.Delivery{PublicKey: "key"},
+			},
+			Status: v1alpha1.AccessTokenStatus{
+				ConditionedStatus: api.ConditionedStatus{
+					Conditions: []api.Condition{{Type: api.TypeReady, Status: corev1.ConditionTrue}},
+				},
+			},
+		}
+		pending := &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "team-a"},
+			Status: v1alpha1.AccessTokenStatus{
+				ConditionedStatus: api.ConditionedStatus{
+					Conditions: []api.Condition{{Type: api.TypeReady, Status: corev1.ConditionFalse, Message: "consent missing"}},
+				},
+			},
+		}
+		expired := &v1alpha1.AccessToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "expired", Namespace: "team-a"},
+			Spec: v1alpha1.AccessTokenSpec{
+				Delivery: &v1alpha1.Delivery{PublicKey: "key"},
+			},
+			Status: v1alpha1.AccessTokenStatus{
+				ConditionedStatus: api.ConditionedStatus{
+					Conditions: []api.Condition{{Type: api.TypeReady, Status: corev1.ConditionTrue}},
+				},
+				EncryptedTokenExpirationTimestamp: ptr.To(metav1.NewTime(time.Now().Add(-time.Minute))),
+			},
+		}
+		c := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(local, shared, unshared, deleting, delivered, pending, expired).
+			Build()
+		injector = webhook.NewPodInjector(c, scheme)
+	})
+
+	newPod := func(annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "app",
+				Namespace:   "team-a",
+				Annotations: annotations,
+			},
+			Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "setup"}},
+				Containers:     []corev1.Container{{Name: "app"}, {Name: "proxy"}},
+			},
+		}
+	}
+
+	// handle admits the Pod and returns the response along with the patched Pod.
+	handle := func(pod *corev1.Pod) (admission.Response, *corev1.Pod) {
+		raw, err := json.Marshal(pod)
+		Expect(err).NotTo(HaveOccurred())
+
+		resp := injector.Handle(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Namespace: pod.GetNamespace(),
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+		})
+		if len(resp.Patches) == 0 {
+			return resp, pod
+		}
+
+		patch, err := json.Marshal(resp.Patches)
+		Expect(err).NotTo(HaveOccurred())
+		decoded, err := jsonpatch.DecodePatch(patch)
+		Expect(err).NotTo(HaveOccurred())
+		patched, err := decoded.Apply(raw)
+		Expect(err).NotTo(HaveOccurred())
+
+		out := &corev1.Pod{}
+		Expect(json.Unmarshal(patched, out)).To(Succeed())
+		return resp, out
+	}
+
+	secretName := func(pod *corev1.Pod) string {
+		for _, v := range pod.Spec.Volumes {
+			if v.Projected == nil {
+				continue
+			}
+			for _, s := range v.Projected.Sources {
+				if s.Secret != nil {
+					return s.Secret.Name
+				}
+			}
+		}
+		return ""
+	}
+
+	It("ignores Pods without the inject annotation", func() {
+		resp, _ := handle(newPod(nil))
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(resp.Patches).To(BeEmpty())
+	})
+
+	It("injects the token Secret of an AccessToken in the Pod's namespace into every container", func() {
+		resp, pod := handle(newPod(map[string]string{v1alpha1.AnnotationInject: "deployer"}))
+		Expect(resp.Allowed).To(BeTrue())
+
+		Expect(secretName(pod)).To(Equal("deployer-token"))
+		Expect(pod.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationInjectedKubeconfig, ContainSubstring("namespace: team-a")))
+		for _, c := range pod.Spec.Containers {
+			Expect(c.VolumeMounts).To(ContainElement(HaveField("MountPath", webhook.InjectedMountPath)))
+			Expect(c.Env).To(ContainElement(corev1.EnvVar{Name: "KUBECONFIG", Value: webhook.InjectedMountPath + "/kubeconfig"}))
+		}
+		Expect(pod.Spec.InitContainers[0].VolumeMounts).To(BeEmpty())
+	})
+
+	It("only injects into the named containers", func() {
+		_, pod := handle(newPod(map[string]string{
+			v1alpha1.AnnotationInject:           "deployer",
+			v1alpha1.AnnotationInjectContainers: "setup, proxy",
+		}))
+
+		Expect(pod.Spec.InitContainers[0].VolumeMounts).To(HaveLen(1))
+		Expect(pod.Spec.Containers[0].VolumeMounts).To(BeEmpty())
+		Expect(pod.Spec.Containers[1].VolumeMounts).To(HaveLen(1))
+	})
+
+	It("injects the mirrored Secret of an AccessToken shared with the Pod's namespace", func() {
+		resp, pod := handle(newPod(map[string]string{v1alpha1.AnnotationInject: "platform/reader"}))
+		Expect(resp.Allowed).To(BeTrue())
+
+		Expect(secretName(pod)).To(Equal("platform-reader"))
+		Expect(pod.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationInjectedKubeconfig, ContainSubstring("namespace: platform")))
+	})
+
+	It("doesn't inject twice", func() {
+		_, pod := handle(newPod(map[string]string{v1alpha1.AnnotationInject: "deployer"}))
+
+		resp, _ := handle(pod)
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(resp.Patches).To(BeEmpty())
+	})
+
+	DescribeTable("denies injection",
+		func(annotations map[string]string, reason string) {
+			resp, _ := handle(newPod(annotations))
+			Expect(resp.Allowed).To(BeFalse())
+			Expect(resp.Result.Message).To(ContainSubstring(reason))
+		},
+		Entry("of missing AccessTokens",
+			map[string]string{v1alpha1.AnnotationInject: "missing"}, "doesn't exist"),
+		Entry("of AccessTokens not shared with the Pod's namespace",
+			map[string]string{v1alpha1.AnnotationInject: "platform/writer"}, "spec.secretTargets"),
+		Entry("of AccessTokens being deleted",
+			map[string]string{v1alpha1.AnnotationInject: "retired"}, "revoked"),
+		Entry("of AccessTokens that aren't ready",
+			map[string]string{v1alpha1.AnnotationInject: "pending"}, "not ready: consent missing"),
+		Entry("of expired tokens",
+			map[string]string{v1alpha1.AnnotationInject: "expired"}, "expired"),
+		Entry("of expiring delivered tokens",
+			map[string]string{v1alpha1.AnnotationInject: "ci"}, "spec.delivery"),
+		Entry("into unknown containers",
+			map[string]string{v1alpha1.AnnotationInject: "deployer", v1alpha1.AnnotationInjectContainers: "sidecar"}, "sidecar"),
+		Entry("of malformed references",
+			map[string]string{v1alpha1.AnnotationInject: "a/b/c"}, "must be"),
+	)
+})
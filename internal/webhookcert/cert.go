@@ -0,0 +1,197 @@
+package webhookcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// CABundleKey holds the PEM encoded CA bundle patched into the webhook configurations.
+	CABundleKey = "ca.crt"
+
+	// CAKey holds the PEM encoded private key of the current CA.
+	CAKey = "ca.key"
+
+	// clockSkew backdates certificates so that they're valid on API servers whose clocks are behind.
+	clockSkew = time.Hour
+)
+
+// certificates are the PEM encoded CA and serving certificate stored in the Secret.
+type certificates struct {
+	// caBundle holds the current CA followed by the previous one, so that API servers keep trusting serving
+	// certificates signed by the previous CA until every replica has picked up the new one.
+	caBundle []byte
+	caKey    []byte
+	cert     []byte
+	key      []byte
+}
+
+func certificatesFromSecret(secret *corev1.Secret) certificates {
+	return certificates{
+		caBundle: secret.Data[CABundleKey],
+		caKey:    secret.Data[CAKey],
+		cert:     secret.Data[corev1.TLSCertKey],
+		key:      secret.Data[corev1.TLSPrivateKeyKey],
+	}
+}
+
+func (c certificates) data() map[string][]byte {
+	return map[string][]byte{
+		CABundleKey:             c.caBundle,
+		CAKey:                   c.caKey,
+		corev1.TLSCertKey:       c.cert,
+		corev1.TLSPrivateKeyKey: c.key,
+	}
+}
+
+// generate returns a new self-signed CA and a serving certificate for dnsNames signed by it, both valid for validity.
+// The current CA of previous is kept in the CA bundle while it's still valid.
+func generate(now time.Time, dnsNames []string, validity time.Duration, previous certificates) (certificates, error) {
+	notBefore := now.Add(-clockSkew)
+	notAfter := now.Add(validity)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return certificates{}, fmt.Errorf("generating CA key: %w", err)
+	}
+	caSerial, err := serialNumber()
+	if err != nil {
+		return certificates{}, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          caSerial,
+		Subject:               pkix.Name{CommonName: dnsNames[0] + "-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return certificates{}, fmt.Errorf("creating CA certificate: %w", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return certificates{}, fmt.Errorf("parsing CA certificate: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return certificates{}, fmt.Errorf("generating serving key: %w", err)
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return certificates{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return certificates{}, fmt.Errorf("creating serving certificate: %w", err)
+	}
+
+	caKeyPEM, err := encodeKey(caKey)
+	if err != nil {
+		return certificates{}, err
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return certificates{}, err
+	}
+
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	if previousCA, err := firstCertificate(previous.caBundle); err == nil && now.Before(previousCA.NotAfter) {
+		caBundle = append(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: previousCA.Raw})...)
+	}
+
+	return certificates{
+		caBundle: caBundle,
+		caKey:    caKeyPEM,
+		cert:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:      keyPEM,
+	}, nil
+}
+
+// verify returns an error if the serving certificate doesn't match its key, isn't valid for every DNS name, isn't signed
+// by the current CA or expires before the deadline.
+func (c certificates) verify(now, deadline time.Time, dnsNames []string) error {
+	pair, err := tls.X509KeyPair(c.cert, c.key)
+	if err != nil {
+		return fmt.Errorf("parsing serving certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("parsing serving certificate: %w", err)
+	}
+	ca, err := firstCertificate(c.caBundle)
+	if err != nil {
+		return fmt.Errorf("parsing CA certificate: %w", err)
+	}
+	if _, err := tls.X509KeyPair(c.caBundle, c.caKey); err != nil {
+		return fmt.Errorf("parsing CA key: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, name := range dnsNames {
+		if _, err := cert.Verify(x509.VerifyOptions{
+			DNSName:     name,
+			Roots:       roots,
+			CurrentTime: now,
+		}); err != nil {
+			return fmt.Errorf("verifying serving certificate: %w", err)
+		}
+	}
+
+	if deadline.After(cert.NotAfter) {
+		return fmt.Errorf("serving certificate expires at %s", cert.NotAfter.Format(time.RFC3339))
+	}
+	if deadline.After(ca.NotAfter) {
+		return fmt.Errorf("CA certificate expires at %s", ca.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// firstCertificate parses the first certificate of a PEM bundle.
+func firstCertificate(bundle []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(bundle)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM encoded certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func serialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generating serial number: %w", err)
+	}
+	return serial, nil
+}
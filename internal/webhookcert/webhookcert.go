@@ -0,0 +1,319 @@
+// Package webhookcert provisions the serving certificate of the manager's webhook server without cert-manager. It
+// generates a self-signed CA and a serving certificate signed by it, stores both in a Secret shared by every replica,
+// writes the serving certificate to the webhook server's certificate directory, patches the CA into the caBundle of the
+// webhook configurations and rotates them before they expire.
+package webhookcert
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/reddit/achilles-token-controller/internal/fileutil"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=mutatingwebhookconfigurations;validatingwebhookconfigurations,verbs=get;patch
+
+const (
+	// DefaultValidity is the default lifetime of the CA and serving certificate.
+	DefaultValidity = 365 * 24 * time.Hour
+
+	// DefaultRotateBefore is the default time before expiry at which the certificates are rotated.
+	DefaultRotateBefore = 30 * 24 * time.Hour
+
+	// checkInterval is how often the certificates are checked for rotation and the caBundles for drift.
+	checkInterval = 10 * time.Minute
+)
+
+// Options configure the certificates and where they're stored.
+type Options struct {
+	// Namespace of the Secret and the webhook Service.
+	Namespace string
+
+	// SecretName is the name of the Secret holding the CA and serving certificate.
+	SecretName string
+
+	// ServiceName is the name of the Service in front of the webhook server, the serving certificate is valid for its
+	// DNS names.
+	ServiceName string
+
+	// WebhookConfigurationName is the name of the MutatingWebhookConfiguration and ValidatingWebhookConfiguration whose
+	// caBundle is patched. Either may not exist.
+	WebhookConfigurationName string
+
+	// CertDir, CertName and KeyName locate the files the webhook server reads its serving certificate from.
+	CertDir  string
+	CertName string
+	KeyName  string
+
+	// Validity is the lifetime of the CA and serving certificate, DefaultValidity if zero.
+	Validity time.Duration
+
+	// RotateBefore is the time before expiry at which the certificates are rotated, DefaultRotateBefore if zero.
+	RotateBefore time.Duration
+}
+
+// Rotator keeps the webhook server's serving certificate valid.
+type Rotator struct {
+	c    client.Client
+	r    client.Reader
+	opts Options
+	log  *zap.SugaredLogger
+	now  func() time.Time
+}
+
+var _ manager.LeaderElectionRunnable = &Rotator{}
+
+// NewRotator returns a Rotator reading with r and writing with c. r must not be served from the manager's cache, the
+// certificates are provisioned before the manager starts.
+func NewRotator(c client.Client, r client.Reader, opts Options, log *zap.SugaredLogger) *Rotator {
+	if opts.Validity == 0 {
+		opts.Validity = DefaultValidity
+	}
+	if opts.RotateBefore == 0 {
+		opts.RotateBefore = DefaultRotateBefore
+	}
+	return &Rotator{
+		c:    c,
+		r:    r,
+		opts: opts,
+		log:  log,
+		now:  time.Now,
+	}
+}
+
+// Setup provisions the serving certificate of the manager's webhook server and registers a Rotator keeping it valid.
+// The certificate directory and file names default to those of the webhook server. The certificate is provisioned
+// before Setup returns, since the webhook server fails to start without it.
+func Setup(ctx context.Context, mgr manager.Manager, opts Options, log *zap.SugaredLogger) error {
+	if server, ok := mgr.GetWebhookServer().(*webhook.DefaultServer); ok {
+		if opts.CertDir == "" {
+			opts.CertDir = server.Options.CertDir
+		}
+		if opts.CertName == "" {
+			opts.CertName = server.Options.CertName
+		}
+		if opts.KeyName == "" {
+			opts.KeyName = server.Options.KeyName
+		}
+	}
+	// the webhook server's defaults, which are only applied once it starts
+	if opts.CertDir == "" {
+		opts.CertDir = filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
+	}
+	if opts.CertName == "" {
+		opts.CertName = corev1.TLSCertKey
+	}
+	if opts.KeyName == "" {
+		opts.KeyName = corev1.TLSPrivateKeyKey
+	}
+
+	r := NewRotator(mgr.GetClient(), mgr.GetAPIReader(), opts, log)
+	if err := r.Sync(ctx); err != nil {
+		return err
+	}
+	return mgr.Add(r)
+}
+
+// Start periodically syncs the certificates until the context is cancelled. Failures are logged and retried.
+func (r *Rotator) Start(ctx context.Context) error {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Sync(ctx); err != nil {
+				r.log.Errorw("syncing webhook certificates", "error", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// NeedLeaderElection returns false, every replica serves webhooks and needs the certificate files.
+func (r *Rotator) NeedLeaderElection() bool {
+	return false
+}
+
+// Sync rotates the certificates if they're missing, invalid or about to expire, patches the CA bundle into the webhook
+// configurations and writes the serving certificate to the certificate directory. The CA bundle is patched first, so
+// that API servers already trust a rotated certificate once it's served.
+func (r *Rotator) Sync(ctx context.Context) error {
+	certs, err := r.ensureSecret(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.patchCABundles(ctx, certs.caBundle); err != nil {
+		return err
+	}
+	return r.writeFiles(certs)
+}
+
+// dnsNames returns the DNS names the webhook Service is reachable at.
+func (r *Rotator) dnsNames() []string {
+	svc := r.opts.ServiceName
+	ns := r.opts.Namespace
+	return []string{
+		fmt.Sprintf("%s.%s.svc", svc, ns),
+		fmt.Sprintf("%s.%s.svc.cluster.local", svc, ns),
+		fmt.Sprintf("%s.%s", svc, ns),
+		svc,
+	}
+}
+
+// ensureSecret returns the certificates stored in the Secret, after creating or rotating them if needed. Replicas race
+// to create and rotate the Secret, the loser adopts the winner's certificates.
+func (r *Rotator) ensureSecret(ctx context.Context) (certificates, error) {
+	key := client.ObjectKey{Namespace: r.opts.Namespace, Name: r.opts.SecretName}
+	now := r.now()
+
+	secret := &corev1.Secret{}
+	if err := r.r.Get(ctx, key, secret); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return certificates{}, fmt.Errorf("getting Secret %s: %w", key, err)
+		}
+
+		certs, err := generate(now, r.dnsNames(), r.opts.Validity, certificates{})
+		if err != nil {
+			return certificates{}, err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Type:       corev1.SecretTypeTLS,
+			Data:       certs.data(),
+		}
+		if err := r.c.Create(ctx, secret); err != nil {
+			if kerrors.IsAlreadyExists(err) {
+				return r.adopt(ctx, key)
+			}
+			return certificates{}, fmt.Errorf("creating Secret %s: %w", key, err)
+		}
+		r.log.Infow("generated webhook certificates", "secret", key.String())
+		return certs, nil
+	}
+
+	current := certificatesFromSecret(secret)
+	verifyErr := current.verify(now, now.Add(r.opts.RotateBefore), r.dnsNames())
+	if verifyErr == nil {
+		return current, nil
+	}
+
+	certs, err := generate(now, r.dnsNames(), r.opts.Validity, current)
+	if err != nil {
+		return certificates{}, err
+	}
+	// the update is rejected if another replica rotated the certificates since they were read
+	secret.Data = certs.data()
+	if err := r.c.Update(ctx, secret); err != nil {
+		if kerrors.IsConflict(err) {
+			return r.adopt(ctx, key)
+		}
+		return certificates{}, fmt.Errorf("updating Secret %s: %w", key, err)
+	}
+	r.log.Infow("rotated webhook certificates", "secret", key.String(), "reason", verifyErr.Error())
+	return certs, nil
+}
+
+// adopt returns the certificates another replica wrote to the Secret.
+func (r *Rotator) adopt(ctx context.Context, key client.ObjectKey) (certificates, error) {
+	secret := &corev1.Secret{}
+	if err := r.r.Get(ctx, key, secret); err != nil {
+		return certificates{}, fmt.Errorf("getting Secret %s: %w", key, err)
+	}
+	return certificatesFromSecret(secret), nil
+}
+
+// patchCABundles sets the caBundle of every webhook of the webhook configurations. Missing configurations are skipped,
+// they're patched on a later sync once installed.
+func (r *Rotator) patchCABundles(ctx context.Context, caBundle []byte) error {
+	key := client.ObjectKey{Name: r.opts.WebhookConfigurationName}
+
+	var errs []error
+
+	mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := r.r.Get(ctx, key, mutating); err == nil {
+		patch := client.MergeFromWithOptions(mutating.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		changed := false
+		for i := range mutating.Webhooks {
+			changed = setCABundle(&mutating.Webhooks[i].ClientConfig, caBundle) || changed
+		}
+		if changed {
+			errs = append(errs, r.patch(ctx, mutating, patch))
+		}
+	} else if !kerrors.IsNotFound(err) {
+		errs = append(errs, fmt.Errorf("getting %T %s: %w", mutating, key.Name, err))
+	}
+
+	validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := r.r.Get(ctx, key, validating); err == nil {
+		patch := client.MergeFromWithOptions(validating.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		changed := false
+		for i := range validating.Webhooks {
+			changed = setCABundle(&validating.Webhooks[i].ClientConfig, caBundle) || changed
+		}
+		if changed {
+			errs = append(errs, r.patch(ctx, validating, patch))
+		}
+	} else if !kerrors.IsNotFound(err) {
+		errs = append(errs, fmt.Errorf("getting %T %s: %w", validating, key.Name, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *Rotator) patch(ctx context.Context, obj client.Object, patch client.Patch) error {
+	if err := r.c.Patch(ctx, obj, patch); err != nil {
+		return fmt.Errorf("patching caBundle of %T %s: %w", obj, obj.GetName(), err)
+	}
+	r.log.Infow("patched webhook caBundle", "kind", fmt.Sprintf("%T", obj), "name", obj.GetName())
+	return nil
+}
+
+// setCABundle sets the caBundle of a webhook and returns true if it changed.
+func setCABundle(cfg *admissionregistrationv1.WebhookClientConfig, caBundle []byte) bool {
+	if bytes.Equal(cfg.CABundle, caBundle) {
+		return false
+	}
+	cfg.CABundle = caBundle
+	return true
+}
+
+// writeFiles writes the serving certificate and key to the certificate directory if they changed. The webhook server
+// reloads them on change. The key is written first, so that the certificate it's paired with on reload matches it.
+func (r *Rotator) writeFiles(certs certificates) error {
+	if err := os.MkdirAll(r.opts.CertDir, 0o700); err != nil {
+		return fmt.Errorf("creating %s: %w", r.opts.CertDir, err)
+	}
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{r.opts.KeyName, certs.key},
+		{r.opts.CertName, certs.cert},
+	}
+	for _, f := range files {
+		path := filepath.Join(r.opts.CertDir, f.name)
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, f.data) {
+			continue
+		}
+		if err := fileutil.WriteFileAtomic(path, f.data, 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package webhookcert_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestWebhookCert(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "WebhookCert Suite")
+}
@@ -0,0 +1,172 @@
+package webhookcert_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/reddit/achilles-token-controller/internal/webhookcert"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Rotator", func() {
+	const (
+		namespace  = "achilles-system"
+		secretName = "webhook-cert"
+		configName = "achilles-token-controller"
+		dnsName    = "achilles-token-controller-webhook.achilles-system.svc"
+	)
+
+	var (
+		ctx     context.Context
+		c       client.Client
+		opts    webhookcert.Options
+		rotator *webhookcert.Rotator
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: configName},
+			Webhooks:   []admissionregistrationv1.MutatingWebhook{{Name: "mpod.accesstoken.group.example.com"}},
+		}
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: configName},
+			Webhooks:   []admissionregistrationv1.ValidatingWebhook{{Name: "vaccesstoken.group.example.com"}},
+		}
+		c = fake.NewClientBuilder().
+			WithScheme(intscheme.MustNewScheme()).
+			WithObjects(mutating, validating).
+			Build()
+
+		opts = webhookcert.Options{
+			Namespace:                namespace,
+			SecretName:               secretName,
+			ServiceName:              "achilles-token-controller-webhook",
+			WebhookConfigurationName: configName,
+			CertDir:                  GinkgoT().TempDir(),
+			CertName:                 corev1.TLSCertKey,
+			KeyName:                  corev1.TLSPrivateKeyKey,
+		}
+		rotator = webhookcert.NewRotator(c, c, opts, zap.NewNop().Sugar())
+	})
+
+	getSecret := func() *corev1.Secret {
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: secretName}, secret)).To(Succeed())
+		return secret
+	}
+
+	// expectServing expects the files in the certificate directory to hold a serving certificate trusted by caBundle.
+	expectServing := func(caBundle []byte) {
+		certPEM, err := os.ReadFile(filepath.Join(opts.CertDir, opts.CertName))
+		Expect(err).NotTo(HaveOccurred())
+		keyPEM, err := os.ReadFile(filepath.Join(opts.CertDir, opts.KeyName))
+		Expect(err).NotTo(HaveOccurred())
+
+		pair, err := tls.X509KeyPair(certPEM, keyPEM)
+		Expect(err).NotTo(HaveOccurred())
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		Expect(err).NotTo(HaveOccurred())
+
+		roots := x509.NewCertPool()
+		Expect(roots.AppendCertsFromPEM(caBundle)).To(BeTrue())
+		_, err = cert.Verify(x509.VerifyOptions{DNSName: dnsName, Roots: roots})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	caBundles := func() [][]byte {
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: configName}, mutating)).To(Succeed())
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: configName}, validating)).To(Succeed())
+		return [][]byte{mutating.Webhooks[0].ClientConfig.CABundle, validating.Webhooks[0].ClientConfig.CABundle}
+	}
+
+	countCertificates := func(bundle []byte) int {
+		n := 0
+		for block, rest := pem.Decode(bundle); block != nil; block, rest = pem.Decode(rest) {
+			n++
+		}
+		return n
+	}
+
+	It("provisions the certificates and patches the caBundles", func() {
+		Expect(rotator.Sync(ctx)).To(Succeed())
+
+		secret := getSecret()
+		Expect(secret.Type).To(Equal(corev1.SecretTypeTLS))
+		caBundle := secret.Data[webhookcert.CABundleKey]
+		Expect(caBundles()).To(HaveEach(Equal(caBundle)))
+		expectServing(caBundle)
+	})
+
+	It("keeps valid certificates", func() {
+		Expect(rotator.Sync(ctx)).To(Succeed())
+		before := getSecret()
+
+		Expect(rotator.Sync(ctx)).To(Succeed())
+		Expect(getSecret().Data).To(Equal(before.Data))
+	})
+
+	It("rotates certificates about to expire and keeps trusting the previous CA", func() {
+		opts.Validity = time.Hour
+		opts.RotateBefore = 2 * time.Hour
+		rotator = webhookcert.NewRotator(c, c, opts, zap.NewNop().Sugar())
+
+		Expect(rotator.Sync(ctx)).To(Succeed())
+		before := getSecret()
+
+		Expect(rotator.Sync(ctx)).To(Succeed())
+		after := getSecret()
+		Expect(after.Data[corev1.TLSCertKey]).NotTo(Equal(before.Data[corev1.TLSCertKey]))
+
+		caBundle := after.Data[webhookcert.CABundleKey]
+		Expect(countCertificates(caBundle)).To(Equal(2))
+		Expect(string(caBundle)).To(HaveSuffix(string(before.Data[webhookcert.CABundleKey])))
+		Expect(caBundles()).To(HaveEach(Equal(caBundle)))
+		expectServing(caBundle)
+	})
+
+	It("rotates certificates issued for another Service", func() {
+		Expect(rotator.Sync(ctx)).To(Succeed())
+		before := getSecret()
+
+		opts.ServiceName = "renamed"
+		rotator = webhookcert.NewRotator(c, c, opts, zap.NewNop().Sugar())
+		Expect(rotator.Sync(ctx)).To(Succeed())
+		Expect(getSecret().Data[corev1.TLSCertKey]).NotTo(Equal(before.Data[corev1.TLSCertKey]))
+	})
+
+	It("shares the certificates with other replicas", func() {
+		Expect(rotator.Sync(ctx)).To(Succeed())
+		caBundle := getSecret().Data[webhookcert.CABundleKey]
+
+		opts.CertDir = GinkgoT().TempDir()
+		replica := webhookcert.NewRotator(c, c, opts, zap.NewNop().Sugar())
+		Expect(replica.Sync(ctx)).To(Succeed())
+		Expect(getSecret().Data[webhookcert.CABundleKey]).To(Equal(caBundle))
+		expectServing(caBundle)
+	})
+
+	It("skips missing webhook configurations", func() {
+		c = fake.NewClientBuilder().WithScheme(intscheme.MustNewScheme()).Build()
+		rotator = webhookcert.NewRotator(c, c, opts, zap.NewNop().Sugar())
+
+		Expect(rotator.Sync(ctx)).To(Succeed())
+		expectServing(getSecret().Data[webhookcert.CABundleKey])
+	})
+})
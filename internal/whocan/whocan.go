@@ -0,0 +1,171 @@
+// Package whocan finds the AccessTokens that grant access to a namespace.
+package whocan
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// managed selects the RBAC objects managed for AccessTokens.
+var managed = client.HasLabels{v1alpha1.LabelAccessTokenName}
+
+// Query selects the access to look up.
+type Query struct {
+	// Namespace the access is granted in. Required.
+	Namespace string
+
+	// Verb of the access, any verb if empty.
+	Verb string
+
+	// APIGroup of the resource.
+	APIGroup string
+
+	// Resource of the access, optionally qualified with a subresource, e.g. "pods/exec". Any resource if empty.
+	Resource string
+}
+
+// Grant is an AccessToken granted access matching a Query.
+type Grant struct {
+	// AccessToken is the namespace/name of the AccessToken.
+	AccessToken string `json:"accessToken"`
+
+	// ServiceAccount is the namespace/name of the token's ServiceAccount.
+	ServiceAccount string `json:"serviceAccount"`
+
+	// Binding is the kind and name of the binding granting the access.
+	Binding string `json:"binding"`
+
+	// ClusterScoped is true if the access is granted cluster-wide rather than in the namespace only.
+	ClusterScoped bool `json:"clusterScoped"`
+
+	// Rules are the rules of the bound role matching the query.
+	Rules []rbacv1.PolicyRule `json:"rules"`
+}
+
+// Lookup returns the AccessTokens granted access matching the query, through a RoleBinding in the namespace or a
+// ClusterRoleBinding. Only bindings labeled as managed for an AccessToken are considered.
+func Lookup(ctx context.Context, c client.Reader, q Query) ([]Grant, error) {
+	var grants []Grant
+
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := c.List(ctx, roleBindings,
+		client.InNamespace(q.Namespace),
+		managed,
+	); err != nil {
+		return nil, fmt.Errorf("listing RoleBindings: %w", err)
+	}
+	for _, binding := range roleBindings.Items {
+		rules, err := roleRules(ctx, c, binding.RoleRef, binding.GetNamespace())
+		if err != nil {
+			return nil, err
+		}
+		if grant, ok := newGrant(&binding, binding.Subjects, rules, q); ok {
+			grant.Binding = fmt.Sprintf("RoleBinding %s", client.ObjectKeyFromObject(&binding))
+			grants = append(grants, grant)
+		}
+	}
+
+	clusterRoleBindings := &rbacv1.ClusterRoleBindingList{}
+	if err := c.List(ctx, clusterRoleBindings, managed); err != nil {
+		return nil, fmt.Errorf("listing ClusterRoleBindings: %w", err)
+	}
+	for _, binding := range clusterRoleBindings.Items {
+		rules, err := roleRules(ctx, c, binding.RoleRef, "")
+		if err != nil {
+			return nil, err
+		}
+		if grant, ok := newGrant(&binding, binding.Subjects, rules, q); ok {
+			grant.Binding = fmt.Sprintf("ClusterRoleBinding %s", binding.GetName())
+			grant.ClusterScoped = true
+			grants = append(grants, grant)
+		}
+	}
+
+	return grants, nil
+}
+
+func newGrant(binding client.Object, subjects []rbacv1.Subject, rules []rbacv1.PolicyRule, q Query) (Grant, bool) {
+	var matching []rbacv1.PolicyRule
+	for _, rule := range rules {
+		if Allows(rule, q) {
+			matching = append(matching, rule)
+		}
+	}
+	if len(matching) == 0 {
+		return Grant{}, false
+	}
+
+	grant := Grant{
+		AccessToken: client.ObjectKey{
+			Namespace: binding.GetLabels()[v1alpha1.LabelAccessTokenNamespace],
+			Name:      binding.GetLabels()[v1alpha1.LabelAccessTokenName],
+		}.String(),
+		Rules: matching,
+	}
+	for _, subject := range subjects {
+		if subject.Kind == rbacv1.ServiceAccountKind {
+			grant.ServiceAccount = client.ObjectKey{Namespace: subject.Namespace, Name: subject.Name}.String()
+			break
+		}
+	}
+	return grant, true
+}
+
+// roleRules returns the rules of the role referenced by a binding in the namespace, empty for ClusterRoleBindings.
+// Roles that no longer exist grant nothing.
+func roleRules(ctx context.Context, c client.Reader, ref rbacv1.RoleRef, namespace string) ([]rbacv1.PolicyRule, error) {
+	var rules []rbacv1.PolicyRule
+	var err error
+	switch ref.Kind {
+	case "Role":
+		role := &rbacv1.Role{}
+		err = c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, role)
+		rules = role.Rules
+	case "ClusterRole":
+		clusterRole := &rbacv1.ClusterRole{}
+		err = c.Get(ctx, client.ObjectKey{Name: ref.Name}, clusterRole)
+		rules = clusterRole.Rules
+	default:
+		return nil, nil
+	}
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting %s %s: %w", ref.Kind, ref.Name, err)
+	}
+	return rules, nil
+}
+
+// Allows returns true if the rule grants access matching the query, following RBAC's matching of wildcards and
+// subresources. Rules granting only non-resource URLs never match since they don't grant access to a namespace.
+func Allows(rule rbacv1.PolicyRule, q Query) bool {
+	if len(rule.Resources) == 0 {
+		return false
+	}
+	if q.Verb != "" && !slices.Contains(rule.Verbs, q.Verb) && !slices.Contains(rule.Verbs, rbacv1.VerbAll) {
+		return false
+	}
+	if q.Resource == "" {
+		return true
+	}
+	if !slices.Contains(rule.APIGroups, q.APIGroup) && !slices.Contains(rule.APIGroups, rbacv1.APIGroupAll) {
+		return false
+	}
+	for _, r := range rule.Resources {
+		if r == rbacv1.ResourceAll || r == q.Resource {
+			return true
+		}
+		if base, sub, ok := strings.Cut(q.Resource, "/"); ok && sub != "" && r == base+"/*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+package whocan_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestWhoCan(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "WhoCan Suite")
+}
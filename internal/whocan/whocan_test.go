@@ -0,0 +1,104 @@
+package whocan_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/reddit/achilles-token-controller/api/group.example.com/v1alpha1"
+	"github.com/reddit/achilles-token-controller/internal/controllers/accesstoken"
+	intscheme "github.com/reddit/achilles-token-controller/internal/scheme"
+	"github.com/reddit/achilles-token-controller/internal/whocan"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("WhoCan", func() {
+	DescribeTable("Allows",
+		func(rule rbacv1.PolicyRule, q whocan.Query, expected bool) {
+			Expect(whocan.Allows(rule, q)).To(Equal(expected))
+		},
+		Entry("any access",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			whocan.Query{}, true),
+		Entry("matching verb and resource",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			whocan.Query{Verb: "get", Resource: "pods"}, true),
+		Entry("other verb",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			whocan.Query{Verb: "delete"}, false),
+		Entry("other API group",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"deployments"}, Verbs: []string{"get"}},
+			whocan.Query{Verb: "get", APIGroup: "apps", Resource: "deployments"}, false),
+		Entry("wildcards",
+			rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+			whocan.Query{Verb: "patch", APIGroup: "apps", Resource: "deployments"}, true),
+		Entry("all subresources",
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods/*"}, Verbs: []string{"create"}},
+			whocan.Query{Verb: "create", Resource: "pods/exec"}, true),
+		Entry("non-resource URLs",
+			rbacv1.PolicyRule{NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}},
+			whocan.Query{}, false),
+	)
+
+	It("should look up the AccessTokens granted access to a namespace", func() {
+		readPods := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}}
+		accessTokens := []*v1alpha1.AccessToken{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "namespaced", Namespace: "team-b"},
+				Spec: v1alpha1.AccessTokenSpec{
+					NamespacedPermissions: []v1alpha1.NamespacedPermissions{
+						{Namespace: "team-a", Rules: readPods},
+						{Namespace: "team-c", Rules: readPods},
+					},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "platform"},
+				Spec: v1alpha1.AccessTokenSpec{
+					ClusterPermissions: &v1alpha1.ClusterPermissions{
+						Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"list"}}},
+					},
+				},
+			},
+		}
+
+		var objs []client.Object
+		for _, at := range accessTokens {
+			objs = append(objs, accesstoken.Render(at)...)
+		}
+		// bindings not managed for an AccessToken are ignored
+		objs = append(objs, &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: "team-a"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"},
+		})
+
+		c := fake.NewClientBuilder().WithScheme(intscheme.MustNewScheme()).WithObjects(objs...).Build()
+
+		grants, err := whocan.Lookup(context.Background(), c, whocan.Query{Namespace: "team-a"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grants).To(ConsistOf(
+			And(
+				HaveField("AccessToken", "team-b/namespaced"),
+				HaveField("ServiceAccount", "team-b/namespaced"),
+				HaveField("Binding", "RoleBinding team-a/namespaced"),
+				HaveField("ClusterScoped", false),
+			),
+			And(
+				HaveField("AccessToken", "platform/cluster"),
+				HaveField("Binding", "ClusterRoleBinding cluster-platform"),
+				HaveField("ClusterScoped", true),
+			),
+		))
+
+		grants, err = whocan.Lookup(context.Background(), c, whocan.Query{Namespace: "team-a", Verb: "get", Resource: "pods"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grants).To(ConsistOf(HaveField("AccessToken", "team-b/namespaced")))
+
+		grants, err = whocan.Lookup(context.Background(), c, whocan.Query{Namespace: "team-d", Verb: "get", Resource: "pods"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grants).To(BeEmpty())
+	})
+})